@@ -2,10 +2,10 @@ package main
 
 import (
 	"bytes"
-	"errors"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -13,26 +13,18 @@ import (
 
 type cliSuite struct {
 	suite.Suite
-	binaryPath *string
-}
-
-func (s *cliSuite) SetupSuite() {
-	tmpDir := s.T().TempDir()
-
-	binaryPath := filepath.Join(tmpDir, "golox")
-	cmd := exec.Command("go", "build", "-o", binaryPath, ".")
-	cmd.Dir = "."
-
-	output, err := cmd.CombinedOutput()
-	s.Require().NoError(err, string(output))
-
-	s.binaryPath = &binaryPath
+	// Flags passed before the script, selecting the backend under test.
+	args []string
 }
 
 func TestCLISuite(t *testing.T) {
 	suite.Run(t, new(cliSuite))
 }
 
+func TestCLISuiteVM(t *testing.T) {
+	suite.Run(t, &cliSuite{args: []string{"--vm"}})
+}
+
 type cliResult struct {
 	stdout   string
 	stderr   string
@@ -40,27 +32,37 @@ type cliResult struct {
 }
 
 func (s *cliSuite) runCLI(source string) cliResult {
+	s.T().Helper()
+	return s.runCLIFiles(source, nil)
+}
+
+// runCLIFiles runs source like runCLI, next to the given extra files,
+// keyed by their path relative to the script's directory.
+func (s *cliSuite) runCLIFiles(source string, files map[string]string) cliResult {
+	s.T().Helper()
+	return s.runCLIWith(nil, source, files)
+}
+
+// runCLIWith runs source like runCLIFiles, passing flags before the script.
+func (s *cliSuite) runCLIWith(flags []string, source string, files map[string]string) cliResult {
 	s.T().Helper()
 	r := s.Require()
 
+	// run from the script's directory so diagnostics name it "test.lox"
 	tmpDir := s.T().TempDir()
-	scriptPath := filepath.Join(tmpDir, "test.lox")
-	err := os.WriteFile(scriptPath, []byte(source), 0o644)
+	s.T().Chdir(tmpDir)
+	err := os.WriteFile(filepath.Join(tmpDir, "test.lox"), []byte(source), 0o644)
 	r.NoError(err)
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		r.NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+		r.NoError(os.WriteFile(path, []byte(content), 0o644))
+	}
 
-	cmd := exec.Command(*s.binaryPath, scriptPath)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	var exitCode int
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err = cmd.Run()
-	if err != nil {
-		var exitErr *exec.ExitError
-		r.True(errors.As(err, &exitErr), err.Error())
-		exitCode = exitErr.ExitCode()
-	}
+	args := append(slices.Clone(s.args), flags...)
+	exitCode := run(append(args, "test.lox"), strings.NewReader(""), &stdout, &stderr)
 
 	return cliResult{
 		stdout:   stdout.String(),
@@ -69,6 +71,101 @@ func (s *cliSuite) runCLI(source string) cliResult {
 	}
 }
 
+func (s *cliSuite) TestCLIPromptKeepsStateBetweenLines() {
+	r := s.Require()
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	stdin := strings.NewReader("var a = 1;\nprint a + 1;\nprint b;\n")
+	exitCode := run(s.args, stdin, &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Equal("> > 2\n> > ", stdout.String())
+	r.Equal("Undefined variable 'b'.\n[line 3]\n"+
+		" --> <stdin>:3:7\n"+
+		"  |\n"+
+		"3 | print b;\n"+
+		"  |       ^\n", stderr.String())
+}
+
+func (s *cliSuite) TestCLIPromptMultiLineInput() {
+	tests := []struct {
+		name       string
+		stdin      string
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "declaration spanning several lines",
+			stdin:      "fun add(a, b) {\n  return a + b;\n}\nprint add(1, 2);\n",
+			wantStdout: "> . . > 3\n> ",
+		},
+		{
+			name:       "open parenthesis",
+			stdin:      "print (1 +\n2);\n",
+			wantStdout: "> . 3\n> ",
+		},
+		{
+			name:       "unterminated string",
+			stdin:      "var s = \"a\nb\";\nprint s;\n",
+			wantStdout: "> . > a\nb\n> ",
+		},
+		{
+			name:       "missing semicolon",
+			stdin:      "print 1\n;\n",
+			wantStdout: "> . 1\n> ",
+		},
+		{
+			name:       "bare expressions are echoed",
+			stdin:      "1 + 2\nvar a = \"lox\";\na;\nnil\nclock\n",
+			wantStdout: "> 3\n> > lox\n> > <native fn clock>\n> ",
+		},
+		{
+			name:       "syntax error is reported once complete",
+			stdin:      "{\n}\n}\n",
+			wantStdout: "> . > > ",
+			wantStderr: "[line 3] Error at '}': Expect expression.\n" +
+				" --> <stdin>:3:1\n" +
+				"  |\n" +
+				"3 | }\n" +
+				"  | ^\n",
+		},
+		{
+			name:       "input ends inside a block",
+			stdin:      "{\nprint 1;\n",
+			wantStdout: "> . . \n",
+			wantStderr: "[line 3] Error at end: Expect '}' after block.\n" +
+				" --> <stdin>:3:1\n" +
+				"  |\n" +
+				"3 |\n" +
+				"  | ^\n",
+		},
+		{
+			name:       "errors point at the line a function was typed on",
+			stdin:      "fun f() {\n  return missing;\n}\nf()\n",
+			wantStdout: "> . . > > ",
+			wantStderr: "Undefined variable 'missing'.\n[line 2]\n" +
+				"Traceback (most recent call last):\n" +
+				"  [line 4] in script\n" +
+				"  [line 2] in f()\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			exitCode := run(s.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			r.Equal(0, exitCode)
+			r.Equal(tt.wantStdout, stdout.String())
+			r.Equal(tt.wantStderr, stderr.String())
+		})
+	}
+}
+
 func (s *cliSuite) TestCLIPrintStatementsSuccess() {
 	tests := []struct {
 		name       string
@@ -127,7 +224,12 @@ print 38;
 print x;
 `,
 			wantStdout: "38\n",
-			wantStderr: "Undefined variable 'x'.\n[line 3]\n",
+			wantStderr: "Undefined variable 'x'.\n" +
+				"[line 3]\n" +
+				" --> test.lox:3:7\n" +
+				"  |\n" +
+				"3 | print x;\n" +
+				"  |       ^\n",
 		},
 		{
 			name: "out of scope variable after nested block completes",
@@ -147,7 +249,12 @@ print x;
 print world;
 `,
 			wantStdout: "modified world\ninner quz\nmodified world\nouter quz\n",
-			wantStderr: "Undefined variable 'world'.\n[line 14]\n",
+			wantStderr: "Undefined variable 'world'.\n" +
+				"[line 14]\n" +
+				"  --> test.lox:14:7\n" +
+				"   |\n" +
+				"14 | print world;\n" +
+				"   |       ^~~~~\n",
 		},
 	}
 
@@ -163,7 +270,32 @@ print world;
 	}
 }
 
-func (s *cliSuite) TestCLIParseErrorsReflectCurrentBehavior() {
+func (s *cliSuite) TestCLIStackOverflowExit70() {
+	r := s.Require()
+
+	result := s.runCLI(`fun count(n) {
+  return count(n + 1) + 1;
+}
+count(0);
+`)
+
+	r.Equal(70, result.exitCode)
+	r.Empty(result.stdout)
+	r.Equal("Stack overflow.\n"+
+		"[line 2]\n"+
+		"Traceback (most recent call last):\n"+
+		"  [line 4] in script\n"+
+		"  [line 2] in count()\n"+
+		"  [line 2] in count()\n"+
+		"  [line 2] in count()\n"+
+		"  [Previous line repeated 9997 more times]\n"+
+		" --> test.lox:2:21\n"+
+		"  |\n"+
+		"2 |   return count(n + 1) + 1;\n"+
+		"  |                     ^\n", result.stderr)
+}
+
+func (s *cliSuite) TestCLIParseErrorsExit65() {
 
 	tests := []struct {
 		name       string
@@ -171,14 +303,27 @@ func (s *cliSuite) TestCLIParseErrorsReflectCurrentBehavior() {
 		wantStderr string
 	}{
 		{
-			name: "print without expression writes parser error and exits zero",
+			name: "print without expression writes parser error",
 			source: `
 print;
 `,
-			wantStderr: "[line 2] Error at ';': Expect expression.\n",
+			wantStderr: "[line 2] Error at ';': Expect expression.\n" +
+				" --> test.lox:2:6\n" +
+				"  |\n" +
+				"2 | print;\n" +
+				"  |      ^\n",
+		},
+		{
+			name:   "unterminated multi-line string points at its first line",
+			source: "var a = 1;\nprint \"abc\ndef\n",
+			wantStderr: "[line 4] Error: Unterminated string.\n" +
+				" --> test.lox:2:7\n" +
+				"  |\n" +
+				"2 | print \"abc\n" +
+				"  |       ^~~~\n",
 		},
 		{
-			name: "missing closing brace writes parser error and exits zero",
+			name: "missing closing brace writes parser error",
 			source: `
 {
     var world = 73;
@@ -188,7 +333,31 @@ print;
     // Missing closing curly brace
 }
 `,
-			wantStderr: "[line 9] Error at end: Expect '}' after block.\n",
+			wantStderr: "[line 9] Error at end: Expect '}' after block.\n" +
+				" --> test.lox:9:1\n" +
+				"  |\n" +
+				"9 |\n" +
+				"  | ^\n",
+		},
+		{
+			name: "every syntax error is reported and nothing runs",
+			source: `
+print "before";
+var = 1;
+print "between";
+print (1 + ;
+print "after";
+`,
+			wantStderr: "[line 3] Error at '=': Expect variable name.\n" +
+				" --> test.lox:3:5\n" +
+				"  |\n" +
+				"3 | var = 1;\n" +
+				"  |     ^\n" +
+				"[line 5] Error at ';': Expect expression.\n" +
+				" --> test.lox:5:12\n" +
+				"  |\n" +
+				"5 | print (1 + ;\n" +
+				"  |            ^\n",
 		},
 	}
 
@@ -197,7 +366,7 @@ print;
 			r := s.Require()
 			result := s.runCLI(tt.source)
 
-			r.Equal(0, result.exitCode)
+			r.Equal(65, result.exitCode)
 			r.Empty(result.stdout)
 			r.Equal(tt.wantStderr, result.stderr)
 		})
@@ -916,6 +1085,1008 @@ var baz = "after";
 	}
 }
 
+func (s *cliSuite) TestCLIBreakContinueSuccess() {
+
+	tests := []struct {
+		name       string
+		source     string
+		wantStdout string
+	}{
+		{
+			name: "break leaves a while loop",
+			source: `var i = 0;
+while (true) {
+  if (i == 3) break;
+  print i;
+  i = i + 1;
+}
+print "done";
+`,
+			wantStdout: "0\n1\n2\ndone\n",
+		},
+		{
+			name: "continue skips the rest of a while body",
+			source: `var i = 0;
+while (i < 5) {
+  i = i + 1;
+  if (i == 2 or i == 4) continue;
+  print i;
+}
+`,
+			wantStdout: "1\n3\n5\n",
+		},
+		{
+			name: "continue in a for loop still runs the increment",
+			source: `for (var i = 0; i < 5; i = i + 1) {
+  if (i == 1) continue;
+  if (i == 3) {
+    continue;
+  }
+  print i;
+}
+`,
+			wantStdout: "0\n2\n4\n",
+		},
+		{
+			name: "break in a for loop without condition",
+			source: `for (var i = 0;; i = i + 1) {
+  if (i > 2) break;
+  print i;
+}
+`,
+			wantStdout: "0\n1\n2\n",
+		},
+		{
+			name: "break and continue apply to the innermost loop",
+			source: `for (var i = 0; i < 3; i = i + 1) {
+  for (var j = 0; j < 3; j = j + 1) {
+    if (j == 1) continue;
+    if (j == i) break;
+    print i * 10 + j;
+  }
+}
+`,
+			wantStdout: "10\n12\n20\n",
+		},
+		{
+			name: "return inside a loop leaves the function",
+			source: `fun find(n) {
+  for (var i = 0; i < 10; i = i + 1) {
+    while (true) {
+      if (i == n) return i;
+      break;
+    }
+  }
+  return nil;
+}
+print find(4);
+print find(20);
+`,
+			wantStdout: "4\nnil\n",
+		},
+		{
+			name: "closures created before a break keep the loop variable",
+			source: `var f;
+for (var i = 0; i < 10; i = i + 1) {
+  fun show() { print i; }
+  f = show;
+  if (i == 2) break;
+}
+f();
+`,
+			wantStdout: "2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLI(tt.source)
+
+			r.Equal(0, result.exitCode)
+			r.Equal(tt.wantStdout, result.stdout)
+			r.Empty(result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIInvalidBreakContinueErrorsExit65() {
+
+	tests := []struct {
+		name       string
+		source     string
+		wantStderr string
+	}{
+		{
+			name: "break at top level",
+			source: `print "unreachable";
+break;
+`,
+			wantStderr: "[line 2] Error at 'break': Can't use 'break' outside of a loop.\n" +
+				" --> test.lox:2:1\n" +
+				"  |\n" +
+				"2 | break;\n" +
+				"  | ^~~~~\n",
+		},
+		{
+			name: "continue inside a top-level block",
+			source: `{
+  continue;
+}
+`,
+			wantStderr: "[line 2] Error at 'continue': Can't use 'continue' outside of a loop.\n" +
+				" --> test.lox:2:3\n" +
+				"  |\n" +
+				"2 |   continue;\n" +
+				"  |   ^~~~~~~~\n",
+		},
+		{
+			name: "break inside a function declared in a loop",
+			source: `while (true) {
+  fun leave() {
+    break;
+  }
+}
+`,
+			wantStderr: "[line 3] Error at 'break': Can't use 'break' outside of a loop.\n" +
+				" --> test.lox:3:5\n" +
+				"  |\n" +
+				"3 |     break;\n" +
+				"  |     ^~~~~\n",
+		},
+		{
+			name: "continue inside a method",
+			source: `class Loop {
+  run() {
+    continue;
+  }
+}
+`,
+			wantStderr: "[line 3] Error at 'continue': Can't use 'continue' outside of a loop.\n" +
+				" --> test.lox:3:5\n" +
+				"  |\n" +
+				"3 |     continue;\n" +
+				"  |     ^~~~~~~~\n",
+		},
+		{
+			name: "break without semicolon",
+			source: `while (true) break
+`,
+			wantStderr: "[line 2] Error at end: Expect ';' after 'break'.\n" +
+				" --> test.lox:2:1\n" +
+				"  |\n" +
+				"2 |\n" +
+				"  | ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLI(tt.source)
+
+			r.Equal(65, result.exitCode)
+			r.Empty(result.stdout)
+			r.Equal(tt.wantStderr, result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIListsSuccess() {
+
+	tests := []struct {
+		name       string
+		source     string
+		wantStdout string
+	}{
+		{
+			name: "list literals print their elements",
+			source: `print [];
+print [1, "two", nil, true, [3]];
+var xs = [1, 2];
+print xs;
+`,
+			wantStdout: "[]\n[1, \"two\", nil, true, [3]]\n[1, 2]\n",
+		},
+		{
+			name: "index get and set",
+			source: `var xs = ["a", "b", "c"];
+print xs[0] + xs[2];
+xs[1] = "B";
+print xs;
+print xs[1] = "again";
+var grid = [[1, 2], [3, 4]];
+grid[1][0] = grid[0][1] * 10;
+print grid;
+`,
+			wantStdout: "ac\n[\"a\", \"B\", \"c\"]\nagain\n[[1, 2], [20, 4]]\n",
+		},
+		{
+			name: "native methods",
+			source: `var xs = [];
+xs.push(1);
+xs.push(2);
+xs.push(3);
+print xs.length();
+print xs.pop();
+xs.insert(0, "first");
+xs.insert(xs.length(), "last");
+print xs;
+print xs.remove(1);
+print xs;
+print xs.slice(1);
+print xs.slice(0, 2);
+print xs.slice();
+`,
+			wantStdout: "3\n3\n[\"first\", 1, 2, \"last\"]\n1\n[\"first\", 2, \"last\"]\n[2, \"last\"]\n[\"first\", 2]\n[\"first\", 2, \"last\"]\n",
+		},
+		{
+			name: "lists are shared by reference",
+			source: `fun fill(list, n) {
+  for (var i = 0; i < n; i = i + 1) list.push(i);
+}
+var xs = [];
+var ys = xs;
+fill(xs, 3);
+print ys;
+print xs == ys;
+print [] == [];
+var copy = xs.slice();
+copy.push(99);
+print xs.length();
+`,
+			wantStdout: "[0, 1, 2]\ntrue\nfalse\n3\n",
+		},
+		{
+			name: "list containing itself",
+			source: `var xs = [1];
+xs.push(xs);
+print xs;
+`,
+			wantStdout: "[1, [...]]\n",
+		},
+		{
+			name: "bound methods keep their list",
+			source: `var xs = [];
+var push = xs.push;
+push("a");
+print xs;
+print push;
+`,
+			wantStdout: "[\"a\"]\n<native fn push>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLI(tt.source)
+
+			r.Equal(0, result.exitCode)
+			r.Equal(tt.wantStdout, result.stdout)
+			r.Empty(result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIStringNativesSuccess() {
+	r := s.Require()
+
+	result := s.runCLI(`var words = split("  Hello, Wörld  ", ",");
+print words.length();
+var word = trim(words[1]);
+print word + " has " + join([len(word)], "") + " characters";
+print upper(word) + " " + lower(word);
+print charAt(word, 1) + substr(word, 2, 4) + substr(word, 4);
+print indexOf(word, "r");
+print startsWith(word, "Wö") and endsWith(word, "ld");
+print replace(repeat("ab", 3), "b", "-");
+`)
+
+	r.Equal(0, result.exitCode, result.stderr)
+	r.Equal("2\nWörld has 5 characters\nWÖRLD wörld\nörld\n2\ntrue\na-a-a-\n", result.stdout)
+}
+
+func (s *cliSuite) TestCLIStringNativeErrorsExit70() {
+	r := s.Require()
+
+	result := s.runCLI(`var name = "lox";
+print substr(name, 1, 4);
+`)
+
+	r.Equal(70, result.exitCode)
+	r.Equal("String index out of range.\n"+
+		"[line 2]\n"+
+		" --> test.lox:2:24\n"+
+		"  |\n"+
+		"2 | print substr(name, 1, 4);\n"+
+		"  |                        ^\n", result.stderr)
+}
+
+func (s *cliSuite) TestCLIMathNativesSuccess() {
+	r := s.Require()
+
+	result := s.runCLI(`print floor(2.7) + ceil(0.2) + round(1.5) + abs(-4);
+print sqrt(pow(3, 2) + pow(4, 2));
+print min(4, 1, 3) + max(4, 1, 3);
+print round(sin(PI / 6) * 100) + round(log(exp(2)));
+seed(3);
+var first = randomInt(1, 6);
+var x = random();
+seed(3);
+print first == randomInt(1, 6) and x == random();
+`)
+
+	r.Equal(0, result.exitCode, result.stderr)
+	r.Equal("9\n5\n5\n52\ntrue\n", result.stdout)
+}
+
+func (s *cliSuite) TestCLIMathNativeErrorsExit70() {
+	r := s.Require()
+
+	result := s.runCLI(`print pow(2, "3");
+`)
+
+	r.Equal(70, result.exitCode)
+	r.Equal("Argument 2 to 'pow' must be a number.\n"+
+		"[line 1]\n"+
+		" --> test.lox:1:17\n"+
+		"  |\n"+
+		"1 | print pow(2, \"3\");\n"+
+		"  |                 ^\n", result.stderr)
+}
+
+func (s *cliSuite) TestCLIConversionNativesSuccess() {
+	r := s.Require()
+
+	result := s.runCLI(`print 1.0;
+print 1000000000000000000000 * 10;
+print [0.5, 2.0];
+print num("4") + num(" 0.25 ");
+print num("four");
+print str(12) + "!";
+print toFixed(PI, 3);
+print format("{} has {} items", "cart", 3);
+`)
+
+	r.Equal(0, result.exitCode, result.stderr)
+	r.Equal("1\n10000000000000000000000\n[0.5, 2]\n4.25\nnil\n12!\n3.142\ncart has 3 items\n", result.stdout)
+}
+
+func (s *cliSuite) TestCLIConversionNativeErrorsExit70() {
+	r := s.Require()
+
+	result := s.runCLI(`print format("{} and {}", 1);
+`)
+
+	r.Equal(70, result.exitCode)
+	r.Equal("Too few arguments for the format string.\n"+
+		"[line 1]\n"+
+		" --> test.lox:1:28\n"+
+		"  |\n"+
+		"1 | print format(\"{} and {}\", 1);\n"+
+		"  |                            ^\n", result.stderr)
+}
+
+func (s *cliSuite) TestCLIFileAccessSuccess() {
+	r := s.Require()
+
+	result := s.runCLIWith([]string{"--allow-read=data", "--allow-read=out", "--allow-write=out"}, `var names = listDir("data");
+print names;
+var total = 0;
+for (var i = 0; i < names.length(); i = i + 1) {
+  total = total + num(readFile("data/" + names[i]));
+}
+writeFile("out/total.txt", str(total));
+appendFile("out/total.txt", "!");
+print readFile("out/total.txt");
+removeFile("out/total.txt");
+print exists("out/total.txt");
+`, map[string]string{"data/a.txt": "1.5", "data/b.txt": "2", "out/.keep": ""})
+
+	r.Equal(0, result.exitCode, result.stderr)
+	r.Equal("[\"a.txt\", \"b.txt\"]\n3.5!\nfalse\n", result.stdout)
+}
+
+func (s *cliSuite) TestCLIFileAccessDeniedExit70() {
+	tests := []struct {
+		name       string
+		flags      []string
+		source     string
+		wantStderr string
+	}{
+		{
+			name:   "without flags",
+			source: `print readFile("data/a.txt");` + "\n",
+			wantStderr: "Not allowed to read files.\n" +
+				"[line 1]\n" +
+				" --> test.lox:1:28\n" +
+				"  |\n" +
+				"1 | print readFile(\"data/a.txt\");\n" +
+				"  |                            ^\n",
+		},
+		{
+			name:   "escaping the allowed directory",
+			flags:  []string{"--allow-read=data"},
+			source: `print readFile("data/../test.lox");` + "\n",
+			wantStderr: "Not allowed to read 'data/../test.lox', which is outside of the allowed directories.\n" +
+				"[line 1]\n" +
+				" --> test.lox:1:34\n" +
+				"  |\n" +
+				"1 | print readFile(\"data/../test.lox\");\n" +
+				"  |                                  ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+
+			result := s.runCLIWith(tt.flags, tt.source, map[string]string{"data/a.txt": "a"})
+
+			r.Equal(70, result.exitCode)
+			r.Equal(tt.wantStderr, result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIAllowReadNeedsDirectoryExit64() {
+	r := s.Require()
+
+	result := s.runCLIWith([]string{"--allow-read=test.lox"}, `print 1;`, nil)
+
+	r.Equal(64, result.exitCode)
+	r.Equal("golox: --allow-read: test.lox is not a directory\n", result.stderr)
+	r.Empty(result.stdout)
+}
+
+func (s *cliSuite) TestCLIEqualityComparesObjectsByIdentity() {
+	r := s.Require()
+
+	result := s.runCLI(`class A {
+  m() {}
+}
+var a = A();
+print a == a;
+print a == A();
+print a != a;
+fun f() {}
+fun g() {}
+print f == f;
+print f == g;
+print a.m == a.m;
+print A == A;
+print clock == clock;
+var xs = [1];
+print xs == xs;
+print xs == [1];
+`)
+
+	r.Equal(0, result.exitCode, result.stderr)
+	r.Equal("true\nfalse\nfalse\ntrue\nfalse\nfalse\ntrue\ntrue\ntrue\nfalse\n", result.stdout)
+}
+
+func (s *cliSuite) TestCLIListErrorsExit70() {
+	tests := []struct {
+		name       string
+		source     string
+		wantStderr string
+	}{
+		{
+			name: "index out of range",
+			source: `var xs = [1, 2];
+print xs[2];
+`,
+			wantStderr: "List index out of range.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:11\n" +
+				"  |\n" +
+				"2 | print xs[2];\n" +
+				"  |           ^\n",
+		},
+		{
+			name: "assignment out of range",
+			source: `var xs = [];
+xs[0] = 1;
+`,
+			wantStderr: "List index out of range.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:5\n" +
+				"  |\n" +
+				"2 | xs[0] = 1;\n" +
+				"  |     ^\n",
+		},
+		{
+			name: "index is not an integer",
+			source: `var xs = [1, 2];
+print xs[0.5];
+`,
+			wantStderr: "List index must be an integer.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:13\n" +
+				"  |\n" +
+				"2 | print xs[0.5];\n" +
+				"  |             ^\n",
+		},
+		{
+			name: "index a non-list value",
+			source: `var name = "lox";
+print name[0];
+`,
+			wantStderr: "Only lists and maps can be indexed.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:13\n" +
+				"  |\n" +
+				"2 | print name[0];\n" +
+				"  |             ^\n",
+		},
+		{
+			name: "pop from an empty list",
+			source: `var xs = [];
+xs.pop();
+`,
+			wantStderr: "Can't pop from an empty list.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:8\n" +
+				"  |\n" +
+				"2 | xs.pop();\n" +
+				"  |        ^\n",
+		},
+		{
+			name: "remove out of range",
+			source: `var xs = [1];
+xs.remove(1);
+`,
+			wantStderr: "List index out of range.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:12\n" +
+				"  |\n" +
+				"2 | xs.remove(1);\n" +
+				"  |            ^\n",
+		},
+		{
+			name: "slice bounds reversed",
+			source: `var xs = [1, 2, 3];
+xs.slice(2, 1);
+`,
+			wantStderr: "Slice start must not be after its end.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:14\n" +
+				"  |\n" +
+				"2 | xs.slice(2, 1);\n" +
+				"  |              ^\n",
+		},
+		{
+			name: "unknown list method",
+			source: `var xs = [1];
+xs.size();
+`,
+			wantStderr: "Undefined property 'size'.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:4\n" +
+				"  |\n" +
+				"2 | xs.size();\n" +
+				"  |    ^~~~\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLI(tt.source)
+
+			r.Equal(70, result.exitCode)
+			r.Empty(result.stdout)
+			r.Equal(tt.wantStderr, result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIMapsSuccess() {
+
+	tests := []struct {
+		name       string
+		source     string
+		wantStdout string
+	}{
+		{
+			name: "map literals print entries in insertion order",
+			source: `print {};
+print {"b": 1, "a": 2};
+print {1: "one", true: [1], nil: {"inner": nil}};
+`,
+			wantStdout: "{}\n{\"b\": 1, \"a\": 2}\n{1: \"one\", true: [1], nil: {\"inner\": nil}}\n",
+		},
+		{
+			name: "index get and set",
+			source: `var ages = {"ann": 30};
+ages["bob"] = 25;
+ages["ann"] = ages["ann"] + 1;
+print ages;
+print ages["bob"];
+var key = 1;
+var byNumber = {1: "one"};
+print byNumber[key * 2 - 1];
+`,
+			wantStdout: "{\"ann\": 31, \"bob\": 25}\n25\none\n",
+		},
+		{
+			name: "native methods",
+			source: `var m = {"a": 1, "b": 2, "c": 3};
+print m.size();
+print m.keys();
+print m.values();
+print m.has("b");
+print m.delete("b");
+print m.has("b");
+print m.delete("b");
+m["b"] = 4;
+print m.keys();
+`,
+			wantStdout: "3\n[\"a\", \"b\", \"c\"]\n[1, 2, 3]\ntrue\ntrue\nfalse\nfalse\n[\"a\", \"c\", \"b\"]\n",
+		},
+		{
+			name: "iterate over keys in insertion order",
+			source: `var counts = {};
+var words = ["to", "be", "or", "not", "to", "be"];
+for (var i = 0; i < words.length(); i = i + 1) {
+  var word = words[i];
+  if (counts.has(word)) counts[word] = counts[word] + 1;
+  else counts[word] = 1;
+}
+var keys = counts.keys();
+for (var i = 0; i < keys.length(); i = i + 1) {
+  print keys[i] + " " + (counts[keys[i]] == 2 and "twice" or "once");
+}
+`,
+			wantStdout: "to twice\nbe twice\nor once\nnot once\n",
+		},
+		{
+			name: "maps are shared by reference",
+			source: `fun remember(cache, key) { cache[key] = true; }
+var cache = {};
+remember(cache, "x");
+print cache;
+print cache == cache;
+print {} == {};
+cache["self"] = cache;
+print cache;
+`,
+			wantStdout: "{\"x\": true}\ntrue\nfalse\n{\"x\": true, \"self\": {...}}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLI(tt.source)
+
+			r.Equal(0, result.exitCode)
+			r.Equal(tt.wantStdout, result.stdout)
+			r.Empty(result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIMapErrorsExit70() {
+	tests := []struct {
+		name       string
+		source     string
+		wantStderr string
+	}{
+		{
+			name: "missing key",
+			source: `var m = {"a": 1};
+print m["b"];
+`,
+			wantStderr: "Undefined key \"b\".\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:12\n" +
+				"  |\n" +
+				"2 | print m[\"b\"];\n" +
+				"  |            ^\n",
+		},
+		{
+			name: "list as key in a literal",
+			source: `var m = {"a": 1, []: 2};
+`,
+			wantStderr: "Map key must be a string, number, boolean or nil.\n" +
+				"[line 1]\n" +
+				" --> test.lox:1:23\n" +
+				"  |\n" +
+				"1 | var m = {\"a\": 1, []: 2};\n" +
+				"  |                       ^\n",
+		},
+		{
+			name: "map as key in an assignment",
+			source: `var m = {};
+m[m] = 1;
+`,
+			wantStderr: "Map key must be a string, number, boolean or nil.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:4\n" +
+				"  |\n" +
+				"2 | m[m] = 1;\n" +
+				"  |    ^\n",
+		},
+		{
+			name: "instance as key of a method",
+			source: `class Point {}
+var m = {};
+m.has(Point());
+`,
+			wantStderr: "Map key must be a string, number, boolean or nil.\n" +
+				"[line 3]\n" +
+				" --> test.lox:3:14\n" +
+				"  |\n" +
+				"3 | m.has(Point());\n" +
+				"  |              ^\n",
+		},
+		{
+			name: "fields can't be set on a map",
+			source: `var m = {};
+m.a = 1;
+`,
+			wantStderr: "Only instances have fields.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:3\n" +
+				"  |\n" +
+				"2 | m.a = 1;\n" +
+				"  |   ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLI(tt.source)
+
+			r.Equal(70, result.exitCode)
+			r.Empty(result.stdout)
+			r.Equal(tt.wantStderr, result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIImportsSuccess() {
+	tests := []struct {
+		name       string
+		source     string
+		files      map[string]string
+		wantStdout string
+	}{
+		{
+			name: "import binds the module to its file name",
+			source: `import "util.lox";
+print util.double(21);
+print util;
+`,
+			files: map[string]string{
+				"util.lox": `fun double(n) { return n * 2; }
+`,
+			},
+			wantStdout: "42\n<module util>\n",
+		},
+		{
+			name: "extension can be omitted",
+			source: `import "util";
+print util.name;
+`,
+			files: map[string]string{
+				"util.lox": `var name = "util";
+`,
+			},
+			wantStdout: "util\n",
+		},
+		{
+			name: "import with an alias",
+			source: `import "lib/strings.lox" as s;
+print s.greet("lox");
+`,
+			files: map[string]string{
+				"lib/strings.lox": `fun greet(name) { return "hello, " + name; }
+`,
+			},
+			wantStdout: "hello, lox\n",
+		},
+		{
+			name: "nested imports are relative to the importing file",
+			source: `import "lib/a.lox";
+print a.value;
+`,
+			files: map[string]string{
+				"lib/a.lox": `import "b.lox";
+var value = b.value + 1;
+`,
+				"lib/b.lox": `var value = 1;
+`,
+			},
+			wantStdout: "2\n",
+		},
+		{
+			name: "module runs once",
+			source: `import "counter.lox";
+import "counter.lox" as again;
+counter.bump();
+print again.count;
+`,
+			files: map[string]string{
+				"counter.lox": `print "loading";
+var count = 0;
+fun bump() { count = count + 1; }
+`,
+			},
+			wantStdout: "loading\n1\n",
+		},
+		{
+			name: "module functions see their own globals",
+			source: `var prefix = "main";
+import "log.lox";
+log.say("hi");
+print prefix;
+`,
+			files: map[string]string{
+				"log.lox": `var prefix = "log";
+fun say(message) { print prefix + ": " + message; }
+`,
+			},
+			wantStdout: "log: hi\nmain\n",
+		},
+		{
+			name: "module classes",
+			source: `import "shapes.lox";
+var sq = shapes.Square(3);
+print sq.area();
+`,
+			files: map[string]string{
+				"shapes.lox": `class Square {
+  init(side) { this.side = side; }
+  area() { return this.side * this.side; }
+}
+`,
+			},
+			wantStdout: "9\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLIFiles(tt.source, tt.files)
+
+			r.Equal(0, result.exitCode)
+			r.Equal(tt.wantStdout, result.stdout)
+			r.Empty(result.stderr)
+		})
+	}
+}
+
+func (s *cliSuite) TestCLIImportErrors() {
+	tests := []struct {
+		name         string
+		source       string
+		files        map[string]string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{
+			name: "missing module",
+			source: `import "missing.lox";
+`,
+			wantExitCode: 65,
+			wantStderr: "[line 1] Error at '\"missing.lox\"': Can't find module \"missing.lox\".\n" +
+				" --> test.lox:1:8\n" +
+				"  |\n" +
+				"1 | import \"missing.lox\";\n" +
+				"  |        ^~~~~~~~~~~~~\n",
+		},
+		{
+			name: "circular import",
+			source: `import "a.lox";
+`,
+			files: map[string]string{
+				"a.lox": `import "test.lox";
+`,
+			},
+			wantExitCode: 65,
+			wantStderr: "[line 1] Error at '\"test.lox\"': Circular import of \"test.lox\".\n" +
+				" --> a.lox:1:8\n" +
+				"  |\n" +
+				"1 | import \"test.lox\";\n" +
+				"  |        ^~~~~~~~~~\n",
+		},
+		{
+			name: "import inside a block",
+			source: `{
+  import "util.lox";
+}
+`,
+			files: map[string]string{
+				"util.lox": "",
+			},
+			wantExitCode: 65,
+			wantStderr: "[line 2] Error at 'import': Can't import outside of top-level code.\n" +
+				" --> test.lox:2:3\n" +
+				"  |\n" +
+				"2 |   import \"util.lox\";\n" +
+				"  |   ^~~~~~\n",
+		},
+		{
+			name: "path is not a valid name",
+			source: `import "my-util.lox";
+`,
+			wantExitCode: 65,
+			wantStderr: "[line 1] Error at '\"my-util.lox\"': Module path doesn't end in a valid name, use 'as' to name it.\n" +
+				" --> test.lox:1:8\n" +
+				"  |\n" +
+				"1 | import \"my-util.lox\";\n" +
+				"  |        ^~~~~~~~~~~~~\n",
+		},
+		{
+			name: "syntax error in a module",
+			source: `import "util.lox";
+`,
+			files: map[string]string{
+				"util.lox": `var = 1;
+`,
+			},
+			wantExitCode: 65,
+			wantStderr: "[line 1] Error at '=': Expect variable name.\n" +
+				" --> util.lox:1:5\n" +
+				"  |\n" +
+				"1 | var = 1;\n" +
+				"  |     ^\n",
+		},
+		{
+			name: "runtime error in a module",
+			source: `import "util.lox";
+print "unreachable";
+`,
+			files: map[string]string{
+				"util.lox": `print "loading";
+print -"a";
+`,
+			},
+			wantExitCode: 70,
+			wantStdout:   "loading\n",
+			wantStderr: "Operand must be a number.\n" +
+				"[line 2]\n" +
+				" --> util.lox:2:7\n" +
+				"  |\n" +
+				"2 | print -\"a\";\n" +
+				"  |       ^\n",
+		},
+		{
+			name: "undefined module member",
+			source: `import "util.lox";
+print util.missing;
+`,
+			files: map[string]string{
+				"util.lox": "",
+			},
+			wantExitCode: 70,
+			wantStderr: "Undefined property 'missing'.\n" +
+				"[line 2]\n" +
+				" --> test.lox:2:12\n" +
+				"  |\n" +
+				"2 | print util.missing;\n" +
+				"  |            ^~~~~~~\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLIFiles(tt.source, tt.files)
+
+			r.Equal(tt.wantExitCode, result.exitCode)
+			r.Equal(tt.wantStdout, result.stdout)
+			r.Equal(tt.wantStderr, result.stderr)
+		})
+	}
+}
+
 func (s *cliSuite) TestCLIIdentifierResolutionSuccess() {
 
 	tests := []struct {
@@ -1080,8 +2251,12 @@ var a = "outer";
   var a = a; // expect compile error
 }
 `,
-			wantStderr: "[line 7] Error at 'a': Can't read local variable in its own initializer.\n",
-			wantExit:   65,
+			wantStderr: "[line 7] Error at 'a': Can't read local variable in its own initializer.\n" +
+				" --> test.lox:7:11\n" +
+				"  |\n" +
+				"7 |   var a = a; // expect compile error\n" +
+				"  |           ^\n",
+			wantExit: 65,
 		},
 		{
 			name: "local initializer cannot read itself through call argument",
@@ -1107,8 +2282,12 @@ var b = "global";
 var b = b + " updated";
 print b;
 `,
-			wantStderr: "[line 16] Error at 'b': Can't read local variable in its own initializer.\n",
-			wantExit:   65,
+			wantStderr: "[line 16] Error at 'b': Can't read local variable in its own initializer.\n" +
+				"  --> test.lox:16:21\n" +
+				"   |\n" +
+				"16 |   var b = returnArg(b); // expect compile error\n" +
+				"   |                     ^\n",
+			wantExit: 65,
 		},
 		{
 			name: "function local initializer cannot read itself",
@@ -1129,8 +2308,12 @@ print b;
 
 outer();
 `,
-			wantStderr: "[line 9] Error at 'a': Can't read local variable in its own initializer.\n",
-			wantExit:   65,
+			wantStderr: "[line 9] Error at 'a': Can't read local variable in its own initializer.\n" +
+				" --> test.lox:9:13\n" +
+				"  |\n" +
+				"9 |     var a = a; // expect compile error\n" +
+				"  |             ^\n",
+			wantExit: 65,
 		},
 	}
 
@@ -1162,7 +2345,11 @@ func (s *cliSuite) TestCLIVariableRedeclarationErrorsExit65() {
   var a = "other"; // expect compile error
 }
 `,
-			wantStderr: "[line 5] Error at 'a': Already a variable with this name in this scope.\n",
+			wantStderr: "[line 5] Error at 'a': Already a variable with this name in this scope.\n" +
+				" --> test.lox:5:7\n" +
+				"  |\n" +
+				"5 |   var a = \"other\"; // expect compile error\n" +
+				"  |       ^\n",
 		},
 		{
 			name: "parameter name redeclared as local variable",
@@ -1174,7 +2361,11 @@ fun foo(a) {
   var a; // expect compile error
 }
 `,
-			wantStderr: "[line 6] Error at 'a': Already a variable with this name in this scope.\n",
+			wantStderr: "[line 6] Error at 'a': Already a variable with this name in this scope.\n" +
+				" --> test.lox:6:7\n" +
+				"  |\n" +
+				"6 |   var a; // expect compile error\n" +
+				"  |       ^\n",
 		},
 		{
 			name: "duplicate parameter names",
@@ -1185,7 +2376,11 @@ fun foo(arg, arg) { // expect compile error
   "body";
 }
 `,
-			wantStderr: "[line 2] Error at 'arg': Already a variable with this name in this scope.\n",
+			wantStderr: "[line 2] Error at 'arg': Already a variable with this name in this scope.\n" +
+				" --> test.lox:2:14\n" +
+				"  |\n" +
+				"2 | fun foo(arg, arg) { // expect compile error\n" +
+				"  |              ^~~\n",
 		},
 		{
 			name: "global redeclarations allowed until local redeclaration fails",
@@ -1209,7 +2404,11 @@ print a;
   print a;
 }
 `,
-			wantStderr: "[line 17] Error at 'a': Already a variable with this name in this scope.\n",
+			wantStderr: "[line 17] Error at 'a': Already a variable with this name in this scope.\n" +
+				"  --> test.lox:17:7\n" +
+				"   |\n" +
+				"17 |   var a = \"2\"; // This should be a compile error\n" +
+				"   |       ^\n",
 		},
 	}
 
@@ -1244,7 +2443,11 @@ func (s *cliSuite) TestCLIInvalidReturnErrorsExit65() {
 // top-level
 return; // expect compile error
 `,
-			wantStderr: "[line 9] Error at 'return': Can't return from top-level code.\n",
+			wantStderr: "[line 9] Error at 'return': Can't return from top-level code.\n" +
+				" --> test.lox:9:1\n" +
+				"  |\n" +
+				"9 | return; // expect compile error\n" +
+				"  | ^~~~~~\n",
 		},
 		{
 			name: "return inside top-level conditional",
@@ -1263,7 +2466,11 @@ if (true) {
   // expect compile error
 }
 `,
-			wantStderr: "[line 12] Error at 'return': Can't return from top-level code.\n",
+			wantStderr: "[line 12] Error at 'return': Can't return from top-level code.\n" +
+				"  --> test.lox:12:3\n" +
+				"   |\n" +
+				"12 |   return \"conditional return\";\n" +
+				"   |   ^~~~~~\n",
 		},
 		{
 			name: "return inside top-level block",
@@ -1281,7 +2488,11 @@ fun allowed() {
   return;
 }
 `,
-			wantStderr: "[line 4] Error at 'return': Can't return from top-level code.\n",
+			wantStderr: "[line 4] Error at 'return': Can't return from top-level code.\n" +
+				" --> test.lox:4:3\n" +
+				"  |\n" +
+				"4 |   return \"not allowed in a block either\";\n" +
+				"  |   ^~~~~~\n",
 		},
 		{
 			name: "return inside non-function top-level branch",
@@ -1303,7 +2514,11 @@ if (true) {
   return "not ok"; // expect compile error
 }
 `,
-			wantStderr: "[line 16] Error at 'return': Can't return from top-level code.\n",
+			wantStderr: "[line 16] Error at 'return': Can't return from top-level code.\n" +
+				"  --> test.lox:16:3\n" +
+				"   |\n" +
+				"16 |   return \"not ok\"; // expect compile error\n" +
+				"   |   ^~~~~~\n",
 		},
 	}
 
@@ -1361,8 +2576,13 @@ print "Accessing out-of-scope class:";
 print Dinosaur;  // expect runtime error
 `,
 			wantStdout: "Inside block: Dinosaur exists\nDinosaur\nAccessing out-of-scope class:\n",
-			wantStderr: "Undefined variable 'Dinosaur'.\n[line 8]\n",
-			wantExit:   70,
+			wantStderr: "Undefined variable 'Dinosaur'.\n" +
+				"[line 8]\n" +
+				" --> test.lox:8:7\n" +
+				"  |\n" +
+				"8 | print Dinosaur;  // expect runtime error\n" +
+				"  |       ^~~~~~~~\n",
+			wantExit: 70,
 		},
 		{
 			name: "class declared inside function",
@@ -1830,8 +3050,12 @@ func (s *cliSuite) TestCLIInvalidThisContracts() {
 // should be a compile error
 print this;
 `,
-			wantStderr: "[line 3] Error at 'this': Can't use 'this' outside of a class.\n",
-			wantExit:   65,
+			wantStderr: "[line 3] Error at 'this': Can't use 'this' outside of a class.\n" +
+				" --> test.lox:3:7\n" +
+				"  |\n" +
+				"3 | print this;\n" +
+				"  |       ^~~~\n",
+			wantExit: 65,
 		},
 		{
 			name: "this inside non-method function is compile error",
@@ -1840,8 +3064,12 @@ fun notAMethod() {
   print this; // expect compile error
 }
 `,
-			wantStderr: "[line 3] Error at 'this': Can't use 'this' outside of a class.\n",
-			wantExit:   65,
+			wantStderr: "[line 3] Error at 'this': Can't use 'this' outside of a class.\n" +
+				" --> test.lox:3:9\n" +
+				"  |\n" +
+				"3 |   print this; // expect compile error\n" +
+				"  |         ^~~~\n",
+			wantExit: 65,
 		},
 		{
 			name: "this is not callable",
@@ -1853,8 +3081,16 @@ fun notAMethod() {
 }
 Person().sayName();
 `,
-			wantStderr: "Can only call functions and classes.\n[line 4]\n",
-			wantExit:   70,
+			wantStderr: "Can only call functions and classes.\n" +
+				"[line 4]\n" +
+				"Traceback (most recent call last):\n" +
+				"  [line 7] in script\n" +
+				"  [line 4] in Person.sayName()\n" +
+				" --> test.lox:4:16\n" +
+				"  |\n" +
+				"4 |     print this(); // expect runtime error\n" +
+				"  |                ^\n",
+			wantExit: 70,
 		},
 		{
 			name: "this cannot access unset local variable as property",
@@ -1876,8 +3112,16 @@ var m = instance.method();
 // calling the function returned should work
 m(instance);
 `,
-			wantStderr: "Undefined property 'feeling'.\n[line 8]\n",
-			wantExit:   70,
+			wantStderr: "Undefined property 'feeling'.\n" +
+				"[line 8]\n" +
+				"Traceback (most recent call last):\n" +
+				"  [line 17] in script\n" +
+				"  [line 8] in inner()\n" +
+				" --> test.lox:8:18\n" +
+				"  |\n" +
+				"8 |       print this.feeling; // expect runtime error\n" +
+				"  |                  ^~~~~~~\n",
+			wantExit: 70,
 		},
 	}
 
@@ -2038,8 +3282,12 @@ Person();
 var out = ThingDefault();
 print out;
 `,
-			wantStderr: "[line 6] Error at 'return': Can't return a value from an initializer.\n",
-			wantExit:   65,
+			wantStderr: "[line 6] Error at 'return': Can't return a value from an initializer.\n" +
+				" --> test.lox:6:5\n" +
+				"  |\n" +
+				"6 |     return this; // expect compile error\n" +
+				"  |     ^~~~~~\n",
+			wantExit: 65,
 		},
 		{
 			name: "constructor cannot return literal value",
@@ -2052,8 +3300,12 @@ print out;
 
 Foo();
 `,
-			wantStderr: "[line 4] Error at 'return': Can't return a value from an initializer.\n",
-			wantExit:   65,
+			wantStderr: "[line 4] Error at 'return': Can't return a value from an initializer.\n" +
+				" --> test.lox:4:5\n" +
+				"  |\n" +
+				"4 |     return \"something\"; // expect compile error\n" +
+				"  |     ^~~~~~\n",
+			wantExit: 65,
 		},
 		{
 			name: "constructor cannot return callback result",
@@ -2071,8 +3323,12 @@ Foo();
 
 Foo();
 `,
-			wantStderr: "[line 5] Error at 'return': Can't return a value from an initializer.\n",
-			wantExit:   65,
+			wantStderr: "[line 5] Error at 'return': Can't return a value from an initializer.\n" +
+				" --> test.lox:5:5\n" +
+				"  |\n" +
+				"5 |     return this.callback(); // expect compile error\n" +
+				"  |     ^~~~~~\n",
+			wantExit: 65,
 		},
 	}
 
@@ -2087,3 +3343,87 @@ Foo();
 		})
 	}
 }
+
+func (s *cliSuite) TestCLIInheritanceSuccess() {
+	tests := []struct {
+		name       string
+		source     string
+		wantStdout string
+	}{
+		{
+			name: "subclass inherits and overrides methods",
+			source: `class Animal {
+  init(name) {
+    this.name = name;
+  }
+  speak() {
+    return this.name + " makes a sound";
+  }
+  describe() {
+    return this.name + " is an animal";
+  }
+}
+
+class Dog < Animal {
+  speak() {
+    return this.name + " barks";
+  }
+}
+
+var dog = Dog("Rex");
+print dog.speak();
+print dog.describe();
+`,
+			wantStdout: "Rex barks\nRex is an animal\n",
+		},
+		{
+			name: "super calls the superclass method",
+			source: `class A {
+  method() {
+    return "A";
+  }
+}
+
+class B < A {
+  method() {
+    return super.method() + "B";
+  }
+}
+
+class C < B {
+  method() {
+    return super.method() + "C";
+  }
+}
+
+print C().method();
+`,
+			wantStdout: "ABC\n",
+		},
+		{
+			name: "methods of a subclass refer to the subclass by name",
+			source: `class Base {}
+
+class Derived < Base {
+  make() {
+    return Derived();
+  }
+}
+
+print Derived().make();
+`,
+			wantStdout: "Derived instance\n",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			r := s.Require()
+			result := s.runCLI(tt.source)
+
+			r.Equal(0, result.exitCode)
+			r.Equal(tt.wantStdout, result.stdout)
+			r.Empty(result.stderr)
+		})
+	}
+}
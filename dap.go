@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nt54hamnghi/golox/internal/dap"
+)
+
+const dapUsage = "Usage: glox dap"
+
+// runDAP serves the Debug Adapter Protocol over stdin and stdout until
+// the editor disconnects. Problems with the connection are logged to stderr.
+// Like the programs run by golox itself, the debugged program looks for
+// modules in LOXPATH.
+func runDAP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("golox dap", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, dapUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 64
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintln(stdout, dapUsage)
+		return 64
+	}
+
+	server := dap.NewServer(stdin, stdout, stderr)
+	if path := os.Getenv("LOXPATH"); path != "" {
+		server.SetSearchPath(filepath.SplitList(path)...)
+	}
+	return server.Run()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLIDebug(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	source := "fun f(x) {\n  return x * 2;\n}\nprint f(1);\nbreakpoint;\nprint f(2);\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.lox"), []byte(source), 0o644))
+
+	tests := []struct {
+		name       string
+		args       []string
+		commands   string
+		wantStdout string
+		wantStderr string
+		wantCode   int
+	}{
+		{
+			name:       "breakpoint statements stop the program",
+			args:       []string{"--debug", "test.lox"},
+			commands:   "p f(10)\nc\n",
+			wantStdout: "2\n4\n",
+			wantStderr: "Stopped at test.lox:5 (breakpoint statement)\n=>    5 | breakpoint;\n(golox) 20\n(golox) ",
+		},
+		{
+			name:     "line breakpoints imply --debug",
+			args:     []string{"--break", "test.lox:2", "test.lox"},
+			commands: "p x\nbt\nq\n",
+			wantStderr: "Stopped at test.lox:2 (breakpoint)\n=>    2 |   return x * 2;\n" +
+				"(golox) 1\n(golox) #0 f() at test.lox:2\n#1 script at test.lox:4\n(golox) ",
+		},
+		{
+			name:       "the end of the commands lets the program run",
+			args:       []string{"--debug", "test.lox"},
+			wantStdout: "2\n4\n",
+			wantStderr: "Stopped at test.lox:5 (breakpoint statement)\n=>    5 | breakpoint;\n(golox) \n",
+		},
+		{
+			name:       "the VM can't be debugged",
+			args:       []string{"--vm", "--debug", "test.lox"},
+			wantStderr: "golox: --debug can't be used with --vm\n",
+			wantCode:   64,
+		},
+		{
+			name:       "the prompt can't be debugged",
+			args:       []string{"--debug"},
+			wantStderr: "golox: --debug needs a script\n",
+			wantCode:   64,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			var stdout, stderr bytes.Buffer
+			exitCode := run(tt.args, strings.NewReader(tt.commands), &stdout, &stderr)
+
+			r.Equal(tt.wantCode, exitCode)
+			r.Equal(tt.wantStdout, stdout.String())
+			r.Equal(tt.wantStderr, stderr.String())
+		})
+	}
+}
+
+func TestCLIDebugInvalidBreakpoint(t *testing.T) {
+	r := require.New(t)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"--break", "test.lox", "test.lox"}, strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(64, exitCode)
+	r.Contains(stderr.String(), `invalid location "test.lox", want file:line`)
+}
+
+func TestCLIDAP(t *testing.T) {
+	r := require.New(t)
+
+	var stdin strings.Builder
+	for _, body := range []string{
+		`{"seq": 1, "type": "request", "command": "initialize", "arguments": {"adapterID": "golox"}}`,
+		`{"seq": 2, "type": "request", "command": "disconnect"}`,
+	} {
+		fmt.Fprintf(&stdin, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"dap"}, strings.NewReader(stdin.String()), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Contains(stdout.String(), `"event":"initialized"`)
+	r.Contains(stdout.String(), `"request_seq":2,"success":true,"command":"disconnect"`)
+	r.Empty(stderr.String())
+}
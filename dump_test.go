@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLIDumpAST(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStdout string
+	}{
+		{
+			name:       "sexpr",
+			args:       []string{"--dump-ast=sexpr"},
+			wantStdout: "(var a (+ 1 2))\n(print (call f a))\n",
+		},
+		{
+			name: "json",
+			args: []string{"--dump-ast", "json"},
+			wantStdout: `[
+  {
+    "type": "Var",
+    "name": "a",
+    "initializer": {
+      "type": "Binary",
+      "left": {
+        "type": "Literal",
+        "value": 1
+      },
+      "operator": "+",
+      "right": {
+        "type": "Literal",
+        "value": 2
+      }
+    }
+  },
+  {
+    "type": "Print",
+    "expression": {
+      "type": "Call",
+      "callee": {
+        "type": "Variable",
+        "name": "f"
+      },
+      "arguments": [
+        {
+          "type": "Variable",
+          "name": "a"
+        }
+      ]
+    }
+  }
+]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			// f is undefined: the program is only parsed, never run
+			paths := writeFiles(t, "var a = 1 + 2;\nprint f(a);\n")
+
+			var stdout, stderr bytes.Buffer
+			exitCode := run(append(tt.args, paths...), strings.NewReader(""), &stdout, &stderr)
+
+			r.Equal(0, exitCode)
+			r.Equal(tt.wantStdout, stdout.String())
+			r.Empty(stderr.String())
+		})
+	}
+}
+
+func TestCLIDumpASTReadsStdin(t *testing.T) {
+	r := require.New(t)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"--dump-ast=sexpr"}, strings.NewReader("a.b = !c;"), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Equal("(expr (.= a b (! c)))\n", stdout.String())
+	r.Empty(stderr.String())
+}
+
+func TestCLIDumpASTErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantExitCode int
+		wantStderr   string
+	}{
+		{
+			name:         "syntax errors are reported",
+			args:         []string{"--dump-ast=json"},
+			stdin:        "print 1",
+			wantExitCode: 65,
+			wantStderr: "[line 1] Error at end: Expect ';' after value.\n" +
+				" --> <stdin>:1:8\n" +
+				"  |\n" +
+				"1 | print 1\n" +
+				"  |        ^\n",
+		},
+		{
+			name:         "missing files are reported",
+			args:         []string{"--dump-ast=sexpr", "missing.lox"},
+			wantExitCode: 66,
+			wantStderr:   "open missing.lox: no such file or directory\n",
+		},
+		{
+			name:         "unknown formats are rejected",
+			args:         []string{"--dump-ast=yaml"},
+			wantExitCode: 64,
+			wantStderr:   "golox: --dump-ast must be sexpr or json\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			t.Chdir(t.TempDir())
+
+			var stdout, stderr bytes.Buffer
+			exitCode := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			r.Equal(tt.wantExitCode, exitCode)
+			r.Equal(tt.wantStderr, stderr.String())
+			r.Empty(stdout.String())
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nt54hamnghi/golox/pkg/lox"
+)
+
+const fmtUsage = "Usage: glox fmt [--check | --write] [--indent n] [file ...]"
+
+// runFmt reprints Lox files in the canonical style. Without files,
+// it formats stdin to stdout. It returns the process exit code:
+// 1 when --check finds a file that isn't formatted, 65 when a file
+// doesn't parse and 66 when one can't be read or written.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("golox fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	check := flags.Bool("check", false, "list the files whose formatting differs instead of printing them")
+	write := flags.Bool("write", false, "write the result to the files instead of printing it")
+	indent := flags.Int("indent", lox.DefaultIndent, "number of spaces per nesting level")
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, fmtUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 64
+	}
+
+	if *check && *write {
+		fmt.Fprintln(stderr, "golox fmt: --check and --write can't be used together")
+		return 64
+	}
+	if *indent < 1 {
+		fmt.Fprintln(stderr, "golox fmt: indent width must be positive")
+		return 64
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "golox fmt: --write needs files to write to")
+			return 64
+		}
+		bytes, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 74
+		}
+		return formatFile("<stdin>", string(bytes), *indent, *check, stdout, stderr)
+	}
+
+	code := 0
+	for _, path := range files {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = max(code, 66)
+			continue
+		}
+		source := string(bytes)
+
+		if !*write {
+			code = max(code, formatFile(path, source, *indent, *check, stdout, stderr))
+			continue
+		}
+
+		formatted, err := lox.Format(path, source, *indent)
+		if err != nil {
+			fmt.Fprintln(stderr, render(err))
+			code = max(code, 65)
+			continue
+		}
+		if formatted == source {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+			fmt.Fprintln(stderr, err)
+			code = max(code, 66)
+		}
+	}
+	return code
+}
+
+// formatFile prints source, read from the file called name, formatted.
+// With check, it prints the name instead, and only if the formatting differs.
+func formatFile(name, source string, indent int, check bool, stdout, stderr io.Writer) int {
+	formatted, err := lox.Format(name, source, indent)
+	if err != nil {
+		fmt.Fprintln(stderr, render(err))
+		return 65
+	}
+
+	if !check {
+		fmt.Fprint(stdout, formatted)
+		return 0
+	}
+	if formatted != source {
+		fmt.Fprintln(stdout, name)
+		return 1
+	}
+	return 0
+}
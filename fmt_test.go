@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const unformatted = "fun f(a){if(a){print a;}}// done\n"
+
+const formatted = `fun f(a) {
+  if (a) {
+    print a;
+  }
+} // done
+`
+
+// writeFiles creates the given files in a temporary directory, which
+// becomes the working directory, and returns their paths in order.
+func writeFiles(t *testing.T, contents ...string) []string {
+	t.Helper()
+	r := require.New(t)
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	paths := make([]string, len(contents))
+	for n, content := range contents {
+		paths[n] = filepath.Join("src", string(rune('a'+n))+".lox")
+		r.NoError(os.MkdirAll("src", 0o755))
+		r.NoError(os.WriteFile(paths[n], []byte(content), 0o644))
+	}
+	return paths
+}
+
+func TestCLIFmtPrintsFormattedFiles(t *testing.T) {
+	r := require.New(t)
+	paths := writeFiles(t, unformatted, "var x=1;")
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run(append([]string{"fmt"}, paths...), strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Equal(formatted+"var x = 1;\n", stdout.String())
+	r.Empty(stderr.String())
+
+	// files are left untouched
+	content, err := os.ReadFile(paths[0])
+	r.NoError(err)
+	r.Equal(unformatted, string(content))
+}
+
+func TestCLIFmtReadsStdin(t *testing.T) {
+	r := require.New(t)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"fmt", "--indent", "4"}, strings.NewReader(unformatted), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Equal(strings.ReplaceAll(formatted, "  ", "    "), stdout.String())
+	r.Empty(stderr.String())
+}
+
+func TestCLIFmtCheck(t *testing.T) {
+	r := require.New(t)
+	paths := writeFiles(t, formatted, unformatted)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"fmt", "--check", paths[0], paths[1]}, strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(1, exitCode)
+	r.Equal(paths[1]+"\n", stdout.String())
+	r.Empty(stderr.String())
+
+	stdout.Reset()
+	exitCode = run([]string{"fmt", "--check", paths[0]}, strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Empty(stdout.String())
+}
+
+func TestCLIFmtWrite(t *testing.T) {
+	r := require.New(t)
+	paths := writeFiles(t, unformatted, formatted)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"fmt", "--write", paths[0], paths[1]}, strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Empty(stdout.String())
+	r.Empty(stderr.String())
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		r.NoError(err)
+		r.Equal(formatted, string(content))
+	}
+}
+
+func TestCLIFmtErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		files        []string
+		wantExitCode int
+		wantStderr   string
+	}{
+		{
+			name:         "syntax errors are reported and the file is skipped",
+			args:         []string{"fmt", "--write"},
+			files:        []string{"print 1", unformatted},
+			wantExitCode: 65,
+			wantStderr: "[line 1] Error at end: Expect ';' after value.\n" +
+				" --> src/a.lox:1:8\n" +
+				"  |\n" +
+				"1 | print 1\n" +
+				"  |        ^\n",
+		},
+		{
+			name:         "missing files are reported",
+			args:         []string{"fmt", "missing.lox"},
+			wantExitCode: 66,
+			wantStderr:   "open missing.lox: no such file or directory\n",
+		},
+		{
+			name:         "check and write are exclusive",
+			args:         []string{"fmt", "--check", "--write"},
+			wantExitCode: 64,
+			wantStderr:   "golox fmt: --check and --write can't be used together\n",
+		},
+		{
+			name:         "indent width must be positive",
+			args:         []string{"fmt", "--indent", "0"},
+			wantExitCode: 64,
+			wantStderr:   "golox fmt: indent width must be positive\n",
+		},
+		{
+			name:         "write needs files",
+			args:         []string{"fmt", "--write"},
+			wantExitCode: 64,
+			wantStderr:   "golox fmt: --write needs files to write to\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			paths := writeFiles(t, tt.files...)
+
+			var stdout, stderr bytes.Buffer
+			exitCode := run(append(tt.args, paths...), strings.NewReader(""), &stdout, &stderr)
+
+			r.Equal(tt.wantExitCode, exitCode)
+			r.Equal(tt.wantStderr, stderr.String())
+			if len(paths) > 1 {
+				// the other files are still formatted
+				content, err := os.ReadFile(paths[1])
+				r.NoError(err)
+				r.Equal(formatted, string(content))
+			}
+		})
+	}
+}
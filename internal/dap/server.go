@@ -0,0 +1,380 @@
+// Package dap implements a Debug Adapter Protocol server for Lox, so that
+// editors can drive the debugger over stdio.
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nt54hamnghi/golox/internal/debug"
+	"github.com/nt54hamnghi/golox/pkg/lox"
+)
+
+// The program runs as the only thread.
+const threadID = 1
+
+// References of the variables of the paused program, by scope.
+const (
+	localsReference = iota + 1
+	globalsReference
+)
+
+// Server debugs one program launched by an editor. The program runs on a
+// goroutine of its own, while the server keeps answering requests: the
+// ones inspecting the program are only answered while it is stopped.
+type Server struct {
+	conn *conn
+	// Where problems with the connection itself are logged.
+	log        io.Writer
+	searchPath []string
+	debugger   *debug.Debugger
+
+	// The program to run once the editor is done configuring the
+	// debugger, nil until it is launched.
+	launch     func()
+	configured bool
+	// Closed when the program ends, nil until it starts.
+	done chan struct{}
+	// Closed when the program should be aborted.
+	quitting chan struct{}
+	quitOnce sync.Once
+
+	mu sync.Mutex
+	// The program while it is stopped, nil while it runs.
+	stop *debug.Stop
+	// Receives the action resuming a stopped program.
+	resume chan debug.Action
+	// The action to resume the program with once the request asking
+	// for it has been answered.
+	next *debug.Action
+}
+
+// NewServer returns a server reading requests from in and writing to out.
+func NewServer(in io.Reader, out io.Writer, log io.Writer) *Server {
+	s := &Server{
+		conn:     newConn(in, out),
+		log:      log,
+		resume:   make(chan debug.Action),
+		quitting: make(chan struct{}),
+	}
+	s.debugger = debug.New(s)
+	return s
+}
+
+// SetSearchPath sets the directories searched for modules imported by the
+// program that aren't found next to the importing file.
+func (s *Server) SetSearchPath(dirs ...string) {
+	s.searchPath = dirs
+}
+
+// Run serves requests until the editor disconnects or closes the input,
+// aborting the program if it is still running, and returns the process
+// exit code: 0 if the editor disconnected, 1 otherwise.
+func (s *Server) Run() int {
+	code := 1
+	for {
+		req, err := s.conn.read()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintln(s.log, "golox dap:", err)
+			}
+			s.quit()
+			break
+		}
+
+		if req.Command == "disconnect" {
+			s.quit()
+			if err := s.conn.reply(req, nil, nil); err != nil {
+				fmt.Fprintln(s.log, "golox dap:", err)
+			}
+			code = 0
+			break
+		}
+
+		body, reqErr := s.request(req)
+		if err := s.conn.reply(req, body, reqErr); err != nil {
+			fmt.Fprintln(s.log, "golox dap:", err)
+		}
+		if err := s.after(req); err != nil {
+			fmt.Fprintln(s.log, "golox dap:", err)
+		}
+	}
+	return code
+}
+
+// request handles a request and returns the body of its response.
+func (s *Server) request(req request) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return Capabilities{SupportsConfigurationDoneRequest: true, SupportsTerminateRequest: true}, nil
+	case "launch":
+		var args LaunchArguments
+		if err := decode(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.prepare(args)
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := decode(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "configurationDone":
+		s.configured = true
+		return nil, nil
+	case "threads":
+		return ThreadsResponse{[]Thread{{threadID, "main"}}}, nil
+	case "pause":
+		s.debugger.Pause()
+		return nil, nil
+	case "terminate":
+		s.quit()
+		return nil, nil
+	case "continue":
+		return ContinueResponse{AllThreadsContinued: true}, s.resumeWith(debug.Continue)
+	case "next":
+		return nil, s.resumeWith(debug.StepOver)
+	case "stepIn":
+		return nil, s.resumeWith(debug.StepInto)
+	case "stepOut":
+		return nil, s.resumeWith(debug.StepOut)
+	case "stackTrace", "scopes", "variables", "evaluate":
+		return s.inspect(req)
+	}
+	return nil, fmt.Errorf("unsupported request: %s", req.Command)
+}
+
+// inspect handles a request about the stopped program.
+func (s *Server) inspect(req request) (any, error) {
+	s.mu.Lock()
+	stop := s.stop
+	s.mu.Unlock()
+	if stop == nil {
+		return nil, errors.New("the program isn't stopped")
+	}
+
+	switch req.Command {
+	case "stackTrace":
+		return stackTrace(stop), nil
+	case "scopes":
+		var args ScopesArguments
+		if err := decode(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		scopes := []Scope{{"Globals", globalsReference, false}}
+		// only the environments of the innermost frame are known
+		if args.FrameID == 0 {
+			scopes = append([]Scope{{"Locals", localsReference, false}}, scopes...)
+		}
+		return ScopesResponse{scopes}, nil
+	case "variables":
+		var args VariablesArguments
+		if err := decode(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return variables(stop, args.VariablesReference), nil
+	default:
+		var args EvaluateArguments
+		if err := decode(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		result, err := stop.Evaluate(args.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return EvaluateResponse{Result: result}, nil
+	}
+}
+
+// after does what a request asked for once it has been answered, so that
+// the editor sees the events it causes after the response.
+func (s *Server) after(req request) error {
+	switch req.Command {
+	case "initialize":
+		return s.conn.event("initialized", nil)
+	case "launch", "configurationDone":
+		if s.launch != nil && s.configured && s.done == nil {
+			s.launch()
+		}
+	}
+
+	if s.next != nil {
+		action := *s.next
+		s.next = nil
+		s.resume <- action
+	}
+	return nil
+}
+
+func decode(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// prepare reads the program to launch, which starts once the debugger is configured.
+func (s *Server) prepare(args LaunchArguments) error {
+	if s.launch != nil {
+		return errors.New("a program is already launched")
+	}
+	source, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	engine := lox.NewEngine()
+	engine.SetStdout(output{s.conn, "stdout"})
+	engine.SetSearchPath(s.searchPath...)
+	if err := engine.Debug(s.debugger); err != nil {
+		return err
+	}
+	if args.StopOnEntry {
+		s.debugger.StopOnEntry()
+	}
+
+	s.launch = func() {
+		s.done = make(chan struct{})
+		go s.run(engine, args.Program, string(source))
+	}
+	return nil
+}
+
+// run runs the program and tells the editor when it ends.
+func (s *Server) run(engine *lox.Engine, name string, source string) {
+	defer close(s.done)
+
+	code := 0
+	err := engine.RunScript(name, source)
+	var loxErr *lox.Error
+	switch {
+	case err == nil, errors.Is(err, debug.ErrQuit):
+	case errors.As(err, &loxErr):
+		s.conn.event("output", OutputEvent{"stderr", loxErr.Render() + "\n"})
+		code = 65
+		if loxErr.Stage == lox.RuntimeStage {
+			code = 70
+		}
+	default:
+		s.conn.event("output", OutputEvent{"stderr", err.Error() + "\n"})
+		code = 70
+	}
+
+	s.conn.event("exited", ExitedEvent{code})
+	s.conn.event("terminated", nil)
+}
+
+// quit aborts the program, if it runs, and waits for it to end.
+func (s *Server) quit() {
+	if s.done == nil {
+		return
+	}
+	s.quitOnce.Do(func() {
+		s.debugger.Abort()
+		close(s.quitting)
+	})
+	<-s.done
+}
+
+// Stopped implements [debug.Frontend]. It runs on the goroutine of the
+// program, which stays stopped until a request resumes it.
+func (s *Server) Stopped(stop *debug.Stop) debug.Action {
+	s.mu.Lock()
+	s.stop = stop
+	s.mu.Unlock()
+
+	reason := map[debug.Reason]string{
+		debug.BreakpointStatement: "breakpoint",
+		debug.LineBreakpoint:      "breakpoint",
+		debug.Step:                "step",
+		debug.Entry:               "entry",
+		debug.Pause:               "pause",
+	}[stop.Reason]
+	err := s.conn.event("stopped", StoppedEvent{reason, stop.Reason.String(), threadID, true})
+	if err != nil {
+		fmt.Fprintln(s.log, "golox dap:", err)
+	}
+	select {
+	case action := <-s.resume:
+		return action
+	case <-s.quitting:
+		return debug.Quit
+	}
+}
+
+// resumeWith resumes the stopped program with action once the request
+// asking for it has been answered.
+func (s *Server) resumeWith(action debug.Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return errors.New("the program isn't stopped")
+	}
+	s.stop = nil
+	s.next = &action
+	return nil
+}
+
+// setBreakpoints replaces the breakpoints of a file.
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) SetBreakpointsResponse {
+	file := args.Source.Path
+	s.debugger.ClearBreakpoints(file)
+	breakpoints := make([]Breakpoint, len(args.Breakpoints))
+	for n, bp := range args.Breakpoints {
+		s.debugger.SetBreakpoint(debug.Location{File: file, Line: bp.Line})
+		breakpoints[n] = Breakpoint{Verified: true, Line: bp.Line}
+	}
+	return SetBreakpointsResponse{breakpoints}
+}
+
+func stackTrace(stop *debug.Stop) StackTraceResponse {
+	frames := stop.Frames()
+	stackFrames := make([]StackFrame, len(frames))
+	for n, frame := range frames {
+		path, err := filepath.Abs(frame.Location.File)
+		if err != nil {
+			path = frame.Location.File
+		}
+		stackFrames[n] = StackFrame{
+			ID:     n,
+			Name:   frame.Name,
+			Source: Source{filepath.Base(path), path},
+			Line:   frame.Location.Line,
+			Column: frame.Column,
+		}
+	}
+	return StackTraceResponse{stackFrames, len(stackFrames)}
+}
+
+func variables(stop *debug.Stop, reference int) VariablesResponse {
+	var vars []debug.Variable
+	switch reference {
+	case localsReference:
+		vars = stop.Locals()
+	case globalsReference:
+		vars = stop.Globals()
+	}
+	variables := make([]Variable, len(vars))
+	for n, v := range vars {
+		variables[n] = Variable{Name: v.Name, Value: v.Value}
+	}
+	return VariablesResponse{variables}
+}
+
+// output is where the program writes, sent to the editor as output events.
+type output struct {
+	conn     *conn
+	category string
+}
+
+func (o output) Write(p []byte) (int, error) {
+	if err := o.conn.event("output", OutputEvent{o.category, string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,259 @@
+package dap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// received is a response or event sent by the server.
+type received struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client drives a server running on its own goroutine.
+type client struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	seq int
+	// Messages sent by the server, in order.
+	messages chan received
+	// Exit code of the server, once it returns.
+	code chan int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, messages: make(chan received, 100), code: make(chan int, 1)}
+
+	go func() {
+		c.code <- NewServer(inR, outW, os.Stderr).Run()
+		outW.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		out := newConn(outR, nil)
+		for {
+			header, err := out.in.ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			var length int
+			fmt.Sscan(header.Get("Content-Length"), &length)
+			body := make([]byte, length)
+			if _, err := io.ReadFull(out.in.R, body); err != nil {
+				return
+			}
+			var msg received
+			if err := json.Unmarshal(body, &msg); err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+// next returns the next message sent by the server.
+func (c *client) next() received {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		require.True(c.t, ok, "server closed the connection")
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+		return received{}
+	}
+}
+
+// request sends a request and returns its response, along with the
+// events sent before it.
+func (c *client) request(command string, args any) (received, []received) {
+	c.t.Helper()
+	c.seq++
+	body, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	require.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	require.NoError(c.t, err)
+
+	var events []received
+	for {
+		msg := c.next()
+		if msg.Type == "response" && msg.RequestSeq == c.seq {
+			return msg, events
+		}
+		events = append(events, msg)
+	}
+}
+
+// body returns the body of the response to a successful request.
+func (c *client) body(command string, args any) string {
+	c.t.Helper()
+	resp, _ := c.request(command, args)
+	require.True(c.t, resp.Success, "%s failed: %s", command, resp.Message)
+	return string(resp.Body)
+}
+
+// waitFor returns the body of the next event called name, skipping output
+// events, which it returns separately.
+func (c *client) waitFor(name string) (string, string) {
+	c.t.Helper()
+	var output string
+	for {
+		msg := c.next()
+		if msg.Type == "event" && msg.Event == "output" {
+			var body OutputEvent
+			require.NoError(c.t, json.Unmarshal(msg.Body, &body))
+			output += body.Output
+			continue
+		}
+		require.Equal(c.t, "event", msg.Type)
+		require.Equal(c.t, name, msg.Event)
+		return string(msg.Body), output
+	}
+}
+
+const program = `var total = 0;
+fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+total = add(1, 2);
+print total;
+breakpoint;
+print "done";
+`
+
+func writeProgram(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.lox")
+	require.NoError(t, os.WriteFile(path, []byte(program), 0o644))
+	return path
+}
+
+func TestServerSession(t *testing.T) {
+	r := require.New(t)
+	path := writeProgram(t)
+	c := newClient(t)
+
+	r.JSONEq(`{"supportsConfigurationDoneRequest": true, "supportsTerminateRequest": true}`,
+		c.body("initialize", map[string]any{"adapterID": "golox"}))
+	body, _ := c.waitFor("initialized")
+	r.Empty(body)
+	c.body("launch", map[string]any{"program": path})
+	r.JSONEq(`{"breakpoints": [{"verified": true, "line": 3}]}`, c.body("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []any{map[string]any{"line": 3}},
+	}))
+	c.body("configurationDone", nil)
+
+	body, _ = c.waitFor("stopped")
+	r.JSONEq(`{"reason": "breakpoint", "description": "breakpoint", "threadId": 1, "allThreadsStopped": true}`, body)
+	r.JSONEq(`{"threads": [{"id": 1, "name": "main"}]}`, c.body("threads", nil))
+	r.JSONEq(fmt.Sprintf(`{"stackFrames": [
+		{"id": 0, "name": "add()", "source": {"name": "test.lox", "path": %[1]q}, "line": 3, "column": 3},
+		{"id": 1, "name": "script", "source": {"name": "test.lox", "path": %[1]q}, "line": 6, "column": 17}
+	], "totalFrames": 2}`, path), c.body("stackTrace", map[string]any{"threadId": 1}))
+	r.JSONEq(`{"scopes": [
+		{"name": "Locals", "variablesReference": 1, "expensive": false},
+		{"name": "Globals", "variablesReference": 2, "expensive": false}
+	]}`, c.body("scopes", map[string]any{"frameId": 0}))
+	r.JSONEq(`{"scopes": [{"name": "Globals", "variablesReference": 2, "expensive": false}]}`,
+		c.body("scopes", map[string]any{"frameId": 1}))
+	r.JSONEq(`{"variables": [
+		{"name": "a", "value": "1", "variablesReference": 0},
+		{"name": "b", "value": "2", "variablesReference": 0}
+	]}`, c.body("variables", map[string]any{"variablesReference": 1}))
+	r.JSONEq(`{"result": "20", "variablesReference": 0}`, c.body("evaluate", map[string]any{"expression": "b * 10", "frameId": 0}))
+	resp, _ := c.request("evaluate", map[string]any{"expression": "c"})
+	r.False(resp.Success)
+	r.Equal("Undefined variable 'c'.", resp.Message)
+
+	c.body("next", map[string]any{"threadId": 1})
+	body, _ = c.waitFor("stopped")
+	r.JSONEq(`{"reason": "step", "description": "step", "threadId": 1, "allThreadsStopped": true}`, body)
+	r.JSONEq(`{"result": "3", "variablesReference": 0}`, c.body("evaluate", map[string]any{"expression": "sum"}))
+
+	r.JSONEq(`{"allThreadsContinued": true}`, c.body("continue", map[string]any{"threadId": 1}))
+	body, output := c.waitFor("stopped")
+	r.JSONEq(`{"reason": "breakpoint", "description": "breakpoint statement", "threadId": 1, "allThreadsStopped": true}`, body)
+	r.Equal("3\n", output)
+
+	c.body("continue", map[string]any{"threadId": 1})
+	body, output = c.waitFor("exited")
+	r.JSONEq(`{"exitCode": 0}`, body)
+	r.Equal("done\n", output)
+	c.waitFor("terminated")
+	// a program that ended can't be inspected
+	resp, _ = c.request("stackTrace", map[string]any{"threadId": 1})
+	r.False(resp.Success)
+	r.Equal("the program isn't stopped", resp.Message)
+
+	c.body("disconnect", nil)
+	r.Equal(0, <-c.code)
+}
+
+func TestServerStopOnEntryAndDisconnect(t *testing.T) {
+	r := require.New(t)
+	path := writeProgram(t)
+	c := newClient(t)
+
+	c.body("initialize", nil)
+	c.waitFor("initialized")
+	c.body("configurationDone", nil)
+	c.body("launch", map[string]any{"program": path, "stopOnEntry": true})
+	body, _ := c.waitFor("stopped")
+	r.JSONEq(`{"reason": "entry", "description": "entry", "threadId": 1, "allThreadsStopped": true}`, body)
+
+	// disconnecting aborts the stopped program
+	resp, events := c.request("disconnect", nil)
+	r.True(resp.Success)
+	r.Len(events, 2)
+	r.Equal("exited", events[0].Event)
+	r.JSONEq(`{"exitCode": 0}`, string(events[0].Body))
+	r.Equal("terminated", events[1].Event)
+	r.Equal(0, <-c.code)
+}
+
+func TestServerErrors(t *testing.T) {
+	r := require.New(t)
+	c := newClient(t)
+
+	c.body("initialize", nil)
+	c.waitFor("initialized")
+	resp, _ := c.request("launch", map[string]any{"program": filepath.Join(t.TempDir(), "missing.lox")})
+	r.False(resp.Success)
+	resp, _ = c.request("stepIn", map[string]any{"threadId": 1})
+	r.False(resp.Success)
+	r.Equal("the program isn't stopped", resp.Message)
+	resp, _ = c.request("restart", nil)
+	r.False(resp.Success)
+	r.Equal("unsupported request: restart", resp.Message)
+
+	// runtime errors are reported as output
+	path := filepath.Join(t.TempDir(), "fail.lox")
+	r.NoError(os.WriteFile(path, []byte("print nope;\n"), 0o644))
+	c.body("launch", map[string]any{"program": path})
+	c.body("configurationDone", nil)
+	body, output := c.waitFor("exited")
+	r.JSONEq(`{"exitCode": 70}`, body)
+	r.Contains(output, "Undefined variable 'nope'.")
+
+	// closing the input ends the session
+	c.in.Close()
+	r.Equal(1, <-c.code)
+}
@@ -0,0 +1,151 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const consoleHelp = `Commands:
+  c, continue        run until the next breakpoint
+  s, step            run to the next statement, stepping into calls
+  n, next            run to the next statement, stepping over calls
+  o, out             run until the current function returns
+  b, break [file:]line
+                     stop whenever the program enters a line
+  b, break           list the breakpoints
+  bt, backtrace      print the calls in progress
+  l, list            print the source around the current statement
+  locals             print the local variables
+  globals            print the global variables
+  p, print expr      evaluate expr in the current frame
+  q, quit            abort the program
+  h, help            print this help
+An empty line repeats the last command.`
+
+// contextLines is the number of lines list prints around the current one.
+const contextLines = 5
+
+// Console is a [Frontend] for a person typing commands at a prompt, in the
+// style of gdb. Reaching the end of its input lets the program run to its
+// end without stopping again.
+type Console struct {
+	in  *bufio.Scanner
+	out io.Writer
+	// The last command run, repeated by empty lines.
+	last string
+}
+
+// NewConsole returns a console reading commands from in and writing to out.
+func NewConsole(in io.Reader, out io.Writer) *Console {
+	return &Console{in: bufio.NewScanner(in), out: out}
+}
+
+// Stopped implements [Frontend].
+func (c *Console) Stopped(stop *Stop) Action {
+	fmt.Fprintf(c.out, "Stopped at %s (%s)\n", stop.Location, stop.Reason)
+	c.list(stop, 0)
+
+	for {
+		fmt.Fprint(c.out, "(golox) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return Detach
+		}
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch cmd {
+		case "":
+		case "c", "continue":
+			return Continue
+		case "s", "step":
+			return StepInto
+		case "n", "next":
+			return StepOver
+		case "o", "out":
+			return StepOut
+		case "q", "quit":
+			return Quit
+		case "b", "break":
+			c.breakpoint(stop, arg)
+		case "bt", "backtrace":
+			for n, frame := range stop.Frames() {
+				fmt.Fprintf(c.out, "#%d %s at %s\n", n, frame.Name, frame.Location)
+			}
+		case "l", "list":
+			c.list(stop, contextLines)
+		case "locals":
+			c.variables(stop.Locals())
+		case "globals":
+			c.variables(stop.Globals())
+		case "p", "print":
+			value, err := stop.Evaluate(arg)
+			if err != nil {
+				fmt.Fprintln(c.out, "Error:", err)
+			} else {
+				fmt.Fprintln(c.out, value)
+			}
+		case "h", "help":
+			fmt.Fprintln(c.out, consoleHelp)
+		default:
+			fmt.Fprintf(c.out, "Unknown command '%s'. Type 'help' for a list of commands.\n", cmd)
+		}
+	}
+}
+
+// breakpoint sets the breakpoint written in arg, a line of the current
+// file or a file:line location, or lists the breakpoints without arg.
+func (c *Console) breakpoint(stop *Stop, arg string) {
+	if arg == "" {
+		for _, loc := range stop.debugger.Breakpoints() {
+			fmt.Fprintln(c.out, loc)
+		}
+		return
+	}
+
+	loc, err := ParseLocation(arg)
+	if line, lineErr := strconv.Atoi(arg); lineErr == nil && line > 0 {
+		loc, err = Location{stop.Location.File, line}, nil
+	}
+	if err != nil {
+		fmt.Fprintln(c.out, "Error:", err)
+		return
+	}
+	stop.debugger.SetBreakpoint(loc)
+	fmt.Fprintln(c.out, "Breakpoint at", loc)
+}
+
+// list prints the current line, with context lines before and after it.
+func (c *Console) list(stop *Stop, context int) {
+	source, ok := stop.debugger.Source(stop.Location.File)
+	if !ok {
+		return
+	}
+	lines := strings.Split(source, "\n")
+	first := max(stop.Location.Line-context, 1)
+	last := min(stop.Location.Line+context, len(lines))
+	for n := first; n <= last; n++ {
+		marker := "  "
+		if n == stop.Location.Line {
+			marker = "=>"
+		}
+		fmt.Fprintf(c.out, "%s %4d | %s\n", marker, n, lines[n-1])
+	}
+}
+
+func (c *Console) variables(vars []Variable) {
+	if len(vars) == 0 {
+		fmt.Fprintln(c.out, "No variables.")
+	}
+	for _, v := range vars {
+		fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value)
+	}
+}
@@ -0,0 +1,395 @@
+// Package debug pauses Lox programs run by the tree-walking interpreter
+// at breakpoints, steps through them statement by statement and lets a
+// frontend inspect the paused program.
+package debug
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+)
+
+// ErrQuit is the error a program is aborted with when its debugger quits.
+var ErrQuit = errors.New("debugger quit")
+
+// Location is a line of a source file.
+type Location struct {
+	// File is the name the file was run or imported under.
+	File string
+	Line int
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// ParseLocation parses a location written as file:line.
+func ParseLocation(s string) (Location, error) {
+	// file names may contain colons, line numbers can't
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Location{}, fmt.Errorf("invalid location %q, want file:line", s)
+	}
+	file, line := s[:i], s[i+1:]
+	n, err := strconv.Atoi(line)
+	if file == "" || err != nil || n < 1 {
+		return Location{}, fmt.Errorf("invalid location %q, want file:line", s)
+	}
+	return Location{file, n}, nil
+}
+
+// Reason tells why a program stopped.
+type Reason int
+
+const (
+	// The program reached a breakpoint statement.
+	BreakpointStatement Reason = iota
+	// The program reached a line with a breakpoint.
+	LineBreakpoint
+	// The program finished a step.
+	Step
+	// The program was about to run its first statement.
+	Entry
+	// The frontend asked the program to pause.
+	Pause
+)
+
+func (r Reason) String() string {
+	return [...]string{"breakpoint statement", "breakpoint", "step", "entry", "pause"}[r]
+}
+
+// Action tells a stopped program how to go on.
+type Action int
+
+const (
+	// Run until the next breakpoint.
+	Continue Action = iota
+	// Stop at the next statement, inside the functions it calls if any.
+	StepInto
+	// Stop at the next statement of the current function or of its callers.
+	StepOver
+	// Stop once the current function returns.
+	StepOut
+	// Run to the end without ever stopping again.
+	Detach
+	// Abort the program with ErrQuit.
+	Quit
+)
+
+// Frontend is what the person debugging a program interacts with.
+type Frontend interface {
+	// Stopped is called when the program pauses, and returns once it
+	// should go on. The stop is only valid until then.
+	Stopped(stop *Stop) Action
+}
+
+// Debugger decides where a program stops and hands it to its frontend when
+// it does. The program runs on the goroutine calling the interpreter, and
+// so does the frontend while the program is stopped. Breakpoints may be
+// changed, and the program paused or aborted, from any goroutine.
+type Debugger struct {
+	frontend Frontend
+	interp   *interpreter.Interpreter
+	// The statements of every loaded file, which know their location.
+	spans map[parser.NodeID]parser.Span
+	// The content of every loaded file, by name.
+	sources map[string]string
+
+	mu          sync.Mutex
+	breakpoints map[Location]bool
+
+	// What the frontend asked for at the last stop, and the call depth
+	// of that stop.
+	action Action
+	depth  int
+	entry  bool
+	// The line of the last statement run, and the call depth it ran at.
+	// Breakpoints only stop the program when it enters a line.
+	line      Location
+	lineDepth int
+
+	pause atomic.Bool
+	abort atomic.Bool
+}
+
+// New creates a debugger handing stopped programs to frontend.
+func New(frontend Frontend) *Debugger {
+	return &Debugger{
+		frontend:    frontend,
+		spans:       make(map[parser.NodeID]parser.Span),
+		sources:     make(map[string]string),
+		breakpoints: make(map[Location]bool),
+	}
+}
+
+// Attach makes i report the statements it runs to the debugger.
+func (d *Debugger) Attach(i *interpreter.Interpreter) {
+	d.interp = i
+	i.SetDebugger(d)
+}
+
+// Load tells the debugger about the file called name, along with the
+// spans of the statements parsed from it.
+func (d *Debugger) Load(name string, source string, spans map[parser.NodeID]parser.Span) {
+	d.sources[name] = source
+	maps.Copy(d.spans, spans)
+}
+
+// Source returns the content of a loaded file.
+func (d *Debugger) Source(file string) (string, bool) {
+	if source, ok := d.sources[file]; ok {
+		return source, true
+	}
+	for name, source := range d.sources {
+		if sameFile(name, file) {
+			return source, true
+		}
+	}
+	return "", false
+}
+
+// StopOnEntry makes the program stop before its first statement.
+func (d *Debugger) StopOnEntry() {
+	d.action = StepInto
+	d.entry = true
+}
+
+// SetBreakpoint stops the program whenever it enters the line at loc.
+// The file of loc matches files loaded under a longer path ending with it.
+func (d *Debugger) SetBreakpoint(loc Location) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[loc] = true
+}
+
+// ClearBreakpoints removes the breakpoints set in file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	maps.DeleteFunc(d.breakpoints, func(loc Location, _ bool) bool {
+		return loc.File == file
+	})
+}
+
+// Breakpoints returns the breakpoints set, sorted by file and line.
+func (d *Debugger) Breakpoints() []Location {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.SortedFunc(maps.Keys(d.breakpoints), func(a, b Location) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+}
+
+func (d *Debugger) breakpointAt(loc Location) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for bp := range d.breakpoints {
+		if bp.Line == loc.Line && sameFile(loc.File, bp.File) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameFile reports whether the file loaded as name is the one called file,
+// which may be relative to any directory name is in.
+func sameFile(name string, file string) bool {
+	name, file = filepath.ToSlash(filepath.Clean(name)), filepath.ToSlash(filepath.Clean(file))
+	return name == file || strings.HasSuffix(name, "/"+file) || strings.HasSuffix(file, "/"+name)
+}
+
+// Pause makes the program stop before its next statement.
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// Abort makes the program fail with ErrQuit before its next statement.
+func (d *Debugger) Abort() {
+	d.abort.Store(true)
+}
+
+// Before implements [interpreter.Debugger].
+func (d *Debugger) Before(stmt parser.Stmt) error {
+	if d.abort.Load() {
+		return ErrQuit
+	}
+	span, ok := d.spans[stmt.Id()]
+	if !ok {
+		// the clauses of for loops aren't statements of their own
+		return nil
+	}
+
+	loc := Location{span.First.File, span.First.Line}
+	depth := d.interp.Depth()
+	entered := loc != d.line || depth != d.lineDepth
+	d.line, d.lineDepth = loc, depth
+
+	reason, ok := d.reason(stmt, loc, depth, entered)
+	if !ok {
+		return nil
+	}
+
+	d.action = d.frontend.Stopped(&Stop{reason, loc, span.First.Column, d})
+	d.depth = depth
+	switch d.action {
+	case Detach:
+		d.interp.SetDebugger(nil)
+	case Quit:
+		return ErrQuit
+	}
+	return nil
+}
+
+// reason tells whether the program should stop before stmt, which
+// starts at loc, and why.
+func (d *Debugger) reason(stmt parser.Stmt, loc Location, depth int, entered bool) (Reason, bool) {
+	if d.pause.Swap(false) {
+		return Pause, true
+	}
+	if _, ok := stmt.(parser.Breakpoint); ok {
+		return BreakpointStatement, true
+	}
+	if d.entry {
+		d.entry = false
+		return Entry, true
+	}
+	if entered && d.breakpointAt(loc) {
+		return LineBreakpoint, true
+	}
+
+	switch d.action {
+	case StepInto:
+		return Step, true
+	case StepOver:
+		return Step, depth <= d.depth
+	case StepOut:
+		return Step, depth < d.depth
+	}
+	return 0, false
+}
+
+// Stop is a program paused before running a statement.
+type Stop struct {
+	Reason Reason
+	// Location of the statement about to run.
+	Location Location
+	// Column of the first character of the statement.
+	Column   int
+	debugger *Debugger
+}
+
+// Frame is a function call in progress, or the top-level code of the program.
+type Frame struct {
+	// Name is the called function, such as "add()" or "Point.init()",
+	// or "script" for top-level code.
+	Name string
+	// Location is the line the frame is running, the call site of the
+	// next frame for the frames of callers.
+	Location Location
+	Column   int
+}
+
+// Frames returns the calls in progress, innermost first, followed
+// by the top-level code.
+func (s *Stop) Frames() []Frame {
+	calls := s.debugger.interp.Frames()
+	frames := make([]Frame, 0, len(calls)+1)
+	loc, column := s.Location, s.Column
+	for _, call := range slices.Backward(calls) {
+		frames = append(frames, Frame{call.String(), loc, column})
+		loc, column = Location{call.Call.File, call.Call.Line}, call.Call.Column
+	}
+	return append(frames, Frame{"script", loc, column})
+}
+
+// Variable is a variable of the stopped program, with its value
+// printed the way print statements do.
+type Variable struct {
+	Name  string
+	Value string
+}
+
+// Locals returns the variables of the innermost frame that aren't global,
+// sorted by name. Variables of inner blocks hide the ones they shadow.
+func (s *Stop) Locals() []Variable {
+	scopes := s.debugger.interp.Scopes()
+	seen := make(map[string]bool)
+	var locals []Variable
+	for _, scope := range scopes[:len(scopes)-1] {
+		for name, value := range scope {
+			if !seen[name] {
+				seen[name] = true
+				locals = append(locals, Variable{name, interpreter.Stringify(value)})
+			}
+		}
+	}
+	return sortVariables(locals)
+}
+
+// Globals returns the global variables of the running module, sorted by name.
+func (s *Stop) Globals() []Variable {
+	scopes := s.debugger.interp.Scopes()
+	var globals []Variable
+	for name, value := range scopes[len(scopes)-1] {
+		globals = append(globals, Variable{name, interpreter.Stringify(value)})
+	}
+	return sortVariables(globals)
+}
+
+func sortVariables(vars []Variable) []Variable {
+	slices.SortFunc(vars, func(a, b Variable) int { return strings.Compare(a.Name, b.Name) })
+	return vars
+}
+
+// Evaluate evaluates the Lox expression source as if it appeared in the
+// statement about to run, and returns its value printed the way print
+// statements do. Assignments change the variables of the program.
+func (s *Stop) Evaluate(source string) (string, error) {
+	expr, err := parseExpression(source)
+	if err != nil {
+		return "", err
+	}
+	value, err := s.debugger.interp.Evaluate(expr)
+	if err != nil {
+		return "", withoutLocation(err)
+	}
+	return interpreter.Stringify(value), nil
+}
+
+func parseExpression(source string) (parser.Expr, error) {
+	sc := scanner.NewScanner(source)
+	tokens, err := sc.ScanTokens()
+	if err != nil {
+		return nil, withoutLocation(err)
+	}
+	pa := parser.NewParser(tokens)
+	expr, err := pa.ParseExpression()
+	if err != nil {
+		return nil, withoutLocation(err)
+	}
+	return expr, nil
+}
+
+// withoutLocation returns the message of err without the line it points
+// to, which is meaningless for expressions typed at a prompt, or the
+// message of the first error if err wraps several.
+func withoutLocation(err error) error {
+	if multi, ok := err.(interface{ Unwrap() []error }); ok && len(multi.Unwrap()) > 0 {
+		err = multi.Unwrap()[0]
+	}
+	if m, ok := err.(interface{ Message() string }); ok {
+		return errors.New(m.Message())
+	}
+	return err
+}
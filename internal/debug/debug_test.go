@@ -0,0 +1,294 @@
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/resolver"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/stretchr/testify/require"
+)
+
+// script is a frontend taking the given actions in turn, and recording
+// where the program stopped.
+type script struct {
+	actions []Action
+	stops   []string
+	// Called at every stop, before the action is taken.
+	inspect func(stop *Stop)
+}
+
+func (s *script) Stopped(stop *Stop) Action {
+	s.stops = append(s.stops, fmt.Sprintf("%s %s depth %d", stop.Location, stop.Reason, len(stop.Frames())-1))
+	if s.inspect != nil {
+		s.inspect(stop)
+	}
+	if len(s.actions) == 0 {
+		return Continue
+	}
+	action := s.actions[0]
+	s.actions = s.actions[1:]
+	return action
+}
+
+// debugForTest runs source, as the file test.lox, under a debugger handing
+// stops to frontend, and returns what the program printed.
+func debugForTest(t *testing.T, source string, frontend Frontend, breakpoints ...int) (string, error) {
+	t.Helper()
+	r := require.New(t)
+
+	sc := scanner.NewFileScanner("test.lox", source)
+	tokens, err := sc.ScanTokens()
+	r.NoError(err)
+	pa := parser.NewParser(tokens)
+	prog, err := pa.Parse()
+	r.NoError(err)
+
+	interp := interpreter.NewInterpreter()
+	var stdout bytes.Buffer
+	interp.SetStdout(&stdout)
+	res := resolver.NewResolver(&interp)
+	_, err = res.Resolve(prog)
+	r.NoError(err)
+
+	d := New(frontend)
+	d.Load("test.lox", source, pa.Spans())
+	for _, line := range breakpoints {
+		d.SetBreakpoint(Location{"test.lox", line})
+	}
+	d.Attach(&interp)
+
+	err = interp.Interpret(prog)
+	return stdout.String(), err
+}
+
+const program = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+fun twice(x) {
+  var once = add(x, x);
+  return add(once, once);
+}
+breakpoint;
+print twice(1);
+print "done";
+`
+
+func TestDebuggerStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		actions     []Action
+		stops       []string
+	}{
+		{
+			name:    "breakpoint statements stop the program",
+			actions: []Action{Continue},
+			stops:   []string{"test.lox:9 breakpoint statement depth 0"},
+		},
+		{
+			name:    "step into enters calls",
+			actions: []Action{StepInto, StepInto, StepInto, StepInto, StepOut, StepOut},
+			stops: []string{
+				"test.lox:9 breakpoint statement depth 0",
+				"test.lox:10 step depth 0",
+				"test.lox:6 step depth 1",
+				"test.lox:2 step depth 2",
+				"test.lox:3 step depth 2",
+				"test.lox:7 step depth 1",
+				"test.lox:11 step depth 0",
+			},
+		},
+		{
+			name:    "step over doesn't",
+			actions: []Action{StepInto, StepInto, StepOver, StepOver, StepOver},
+			stops: []string{
+				"test.lox:9 breakpoint statement depth 0",
+				"test.lox:10 step depth 0",
+				"test.lox:6 step depth 1",
+				"test.lox:7 step depth 1",
+				"test.lox:11 step depth 0",
+			},
+		},
+		{
+			name:        "line breakpoints stop every time the line is entered",
+			breakpoints: []int{3},
+			actions:     []Action{Continue, Continue, StepOut},
+			stops: []string{
+				"test.lox:9 breakpoint statement depth 0",
+				"test.lox:3 breakpoint depth 2",
+				"test.lox:3 breakpoint depth 2",
+				"test.lox:11 step depth 0",
+			},
+		},
+		{
+			name:    "detaching lets the program run to its end",
+			actions: []Action{Detach},
+			stops:   []string{"test.lox:9 breakpoint statement depth 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			frontend := &script{actions: tt.actions}
+
+			out, err := debugForTest(t, program, frontend, tt.breakpoints...)
+
+			r.NoError(err)
+			r.Equal("4\ndone\n", out)
+			r.Equal(tt.stops, frontend.stops)
+		})
+	}
+}
+
+func TestDebuggerQuit(t *testing.T) {
+	r := require.New(t)
+	frontend := &script{actions: []Action{StepInto, StepInto, Quit}}
+
+	out, err := debugForTest(t, program, frontend)
+
+	r.ErrorIs(err, ErrQuit)
+	r.Empty(out)
+	r.Len(frontend.stops, 3)
+}
+
+func TestDebuggerInspection(t *testing.T) {
+	r := require.New(t)
+	source := `var greeting = "hi";
+class A {
+  name() { return "A"; }
+}
+class B < A {
+  init(n) { this.n = n; }
+  show(x) {
+    var y = x * 2;
+    {
+      var x = "shadow";
+      breakpoint;
+    }
+    print this.n;
+  }
+}
+B(1).show(3);
+`
+	var stop *Stop
+	frontend := &script{inspect: func(s *Stop) {
+		stop = s
+		r.Equal([]Frame{
+			{"B.show()", Location{"test.lox", 11}, 7},
+			{"script", Location{"test.lox", 16}, 12},
+		}, s.Frames())
+		r.Equal([]Variable{{"super", "A"}, {"this", "B instance"}, {"x", "shadow"}, {"y", "6"}}, s.Locals())
+		r.Equal([]Variable{{"A", "A"}, {"B", "B"}, {"greeting", "hi"}}, s.Globals())
+
+		tests := []struct {
+			expr string
+			want string
+			err  string
+		}{
+			{expr: "y + 1", want: "7"},
+			{expr: `greeting + " " + x`, want: "hi shadow"},
+			{expr: "super.name()", want: "A"},
+			{expr: "clock() > 0", want: "true"},
+			{expr: "this.n = 5", want: "5"},
+			{expr: "missing", err: "Undefined variable 'missing'."},
+			{expr: "y +", err: "Expect expression."},
+			{expr: "y; y", err: "Expect end of expression."},
+			{expr: `"open`, err: "Unterminated string."},
+		}
+		for _, tt := range tests {
+			got, err := s.Evaluate(tt.expr)
+			if tt.err != "" {
+				r.EqualError(err, tt.err, tt.expr)
+			} else {
+				r.NoError(err, tt.expr)
+				r.Equal(tt.want, got, tt.expr)
+			}
+		}
+	}}
+
+	out, err := debugForTest(t, source, frontend)
+
+	r.NoError(err)
+	r.NotNil(stop)
+	// the assignment evaluated at the breakpoint sticks
+	r.Equal("5\n", out)
+}
+
+func TestParseLocation(t *testing.T) {
+	r := require.New(t)
+
+	loc, err := ParseLocation("dir/main.lox:12")
+	r.NoError(err)
+	r.Equal(Location{"dir/main.lox", 12}, loc)
+
+	loc, err = ParseLocation(`C:\lox\main.lox:3`)
+	r.NoError(err)
+	r.Equal(Location{`C:\lox\main.lox`, 3}, loc)
+
+	for _, s := range []string{"main.lox", "main.lox:", ":3", "main.lox:0", "main.lox:x"} {
+		_, err := ParseLocation(s)
+		r.Error(err, s)
+	}
+}
+
+func TestSameFile(t *testing.T) {
+	r := require.New(t)
+
+	r.True(sameFile("main.lox", "main.lox"))
+	r.True(sameFile("/src/lox/main.lox", "lox/main.lox"))
+	r.True(sameFile("./main.lox", "/src/main.lox"))
+	r.False(sameFile("/src/domain.lox", "main.lox"))
+	r.False(sameFile("/src/main.lox", "/other/main.lox"))
+}
+
+func TestConsole(t *testing.T) {
+	r := require.New(t)
+	commands := strings.Join([]string{
+		"s", "", "", "bt", "locals", "p x * 10", "p nope", "b 3", "b", "list", "frob", "c", "globals", "o", "q",
+	}, "\n")
+	var out bytes.Buffer
+
+	stdout, err := debugForTest(t, program, NewConsole(strings.NewReader(commands), &out))
+
+	r.ErrorIs(err, ErrQuit)
+	r.Empty(stdout)
+	r.Equal(`Stopped at test.lox:9 (breakpoint statement)
+=>    9 | breakpoint;
+(golox) Stopped at test.lox:10 (step)
+=>   10 | print twice(1);
+(golox) Stopped at test.lox:6 (step)
+=>    6 |   var once = add(x, x);
+(golox) Stopped at test.lox:2 (step)
+=>    2 |   var sum = a + b;
+(golox) #0 add() at test.lox:2
+#1 twice() at test.lox:6
+#2 script at test.lox:10
+(golox) a = 1
+b = 1
+(golox) Error: Undefined variable 'x'.
+(golox) Error: Undefined variable 'nope'.
+(golox) Breakpoint at test.lox:3
+(golox) test.lox:3
+(golox)       1 | fun add(a, b) {
+=>    2 |   var sum = a + b;
+      3 |   return sum;
+      4 | }
+      5 | fun twice(x) {
+      6 |   var once = add(x, x);
+      7 |   return add(once, once);
+(golox) Unknown command 'frob'. Type 'help' for a list of commands.
+(golox) Stopped at test.lox:3 (breakpoint)
+=>    3 |   return sum;
+(golox) add = <fn add>
+twice = <fn twice>
+(golox) Stopped at test.lox:7 (step)
+=>    7 |   return add(once, once);
+(golox) `, out.String())
+}
@@ -0,0 +1,127 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// spanned is implemented by errors that point at a span of source text.
+// The lexeme, when not empty, is what the span is expected to contain.
+type spanned interface {
+	span() (token.Position, string)
+}
+
+// Render formats err for display to a user. Every error it contains is
+// reported with its message followed, when the error points into source,
+// by the offending line with the lexeme underlined:
+//
+//	[line 2] Error at 'b': Expect ';' after value.
+//	 --> test.lox:2:9
+//	  |
+//	2 | print a b;
+//	  |         ^
+//
+// sources maps the file names recorded in positions to their content.
+// Errors whose span does not match the source of their file, for instance
+// because they come from code that was loaded from another source, are
+// reported without a snippet.
+func Render(err error, sources map[string]string) string {
+	var b strings.Builder
+
+	for i, e := range flatten(err) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(e.Error())
+
+		var s spanned
+		if !errors.As(e, &s) {
+			continue
+		}
+		pos, lexeme := s.span()
+		source, ok := sources[pos.File]
+		if !ok {
+			continue
+		}
+		if snippet, ok := Snippet(source, pos, lexeme); ok {
+			b.WriteString("\n")
+			b.WriteString(snippet)
+		}
+	}
+
+	return b.String()
+}
+
+// flatten expands errors that wrap several errors, such as the ones
+// collected by the scanner and the parser, and drops nil entries.
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	multi, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, e := range multi.Unwrap() {
+		errs = append(errs, flatten(e)...)
+	}
+	return errs
+}
+
+// Snippet renders the source line containing pos with the span underlined
+// by a caret followed by tildes. Only the first line of a multi-line span
+// is shown. It reports false if pos carries no column information, lies
+// outside source, or does not contain lexeme when one is given.
+func Snippet(source string, pos token.Position, lexeme string) (string, bool) {
+	if pos.Column == 0 || pos.Start < 0 || pos.End < pos.Start || pos.End > len(source) {
+		return "", false
+	}
+	if lexeme != "" && source[pos.Start:pos.End] != lexeme {
+		return "", false
+	}
+
+	lineStart := strings.LastIndexByte(source[:pos.Start], '\n') + 1
+	lineEnd := len(source)
+	if n := strings.IndexByte(source[pos.Start:], '\n'); n >= 0 {
+		lineEnd = pos.Start + n
+	}
+	column := utf8.RuneCountInString(source[lineStart:pos.Start]) + 1
+
+	// keep tabs in the indentation so the marker lines up with the text above it
+	var indent strings.Builder
+	for _, char := range source[lineStart:pos.Start] {
+		if char == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	width := max(1, utf8.RuneCountInString(source[pos.Start:min(pos.End, lineEnd)]))
+
+	// the line number comes from pos, as source may continue earlier input,
+	// but pos.Line is where the span ends and the snippet shows where it starts
+	lineNo := pos.Line - strings.Count(source[pos.Start:pos.End], "\n")
+	location := fmt.Sprintf("%d:%d", lineNo, column)
+	if pos.File != "" {
+		location = pos.File + ":" + location
+	}
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(lineNo)))
+	text := strings.TrimRight(source[lineStart:lineEnd], "\r")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s--> %s\n", gutter, location)
+	fmt.Fprintf(&b, "%s |\n", gutter)
+	fmt.Fprintf(&b, "%s\n", strings.TrimRight(fmt.Sprintf("%d | %s", lineNo, text), " "))
+	fmt.Fprintf(&b, "%s | %s^%s", gutter, indent.String(), strings.Repeat("~", width-1))
+
+	return b.String(), true
+}
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/nt54hamnghi/golox/internal/scanner/token"
 )
@@ -11,14 +12,131 @@ import (
 type RuntimeError struct {
 	token   token.Token
 	message string
+	// The calls that were active when the error occurred, outermost first.
+	trace []Frame
+	// The error that caused this one, if any.
+	cause error
 }
 
 // RuntimeErrorAtToken constructs a RuntimeError tied to a token location.
 func RuntimeErrorAtToken(token token.Token, message string) RuntimeError {
-	return RuntimeError{token, message}
+	return RuntimeError{token, message, nil, nil}
 }
 
-// Error returns the runtime error message.
+// Error returns the runtime error message, followed by a traceback
+// when the error occurred inside a function call:
+//
+//	Undefined variable 'x'.
+//	[line 3]
+//	Traceback (most recent call last):
+//	  [line 9] in script
+//	  [line 6] in outer()
+//	  [line 3] in Inner.method()
+//
+// Runs of identical lines, left by recursive calls, are cut short after
+// a few repetitions.
 func (r RuntimeError) Error() string {
-	return fmt.Sprintf("%s\n[line %d]", r.message, r.token.Line)
+	msg := fmt.Sprintf("%s\n[line %d]", r.message, r.token.Line)
+	if len(r.trace) == 0 {
+		return msg
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	b.WriteString("\nTraceback (most recent call last):")
+	fmt.Fprintf(&b, "\n  [line %d] in script", r.trace[0].Call.Line)
+	last, repeated := "", 0
+	for i, frame := range r.trace {
+		// each frame is executing the line of the next call, the innermost
+		// one is executing the line the error occurred on
+		line := r.token.Line
+		if i+1 < len(r.trace) {
+			line = r.trace[i+1].Call.Line
+		}
+		entry := fmt.Sprintf("\n  [line %d] in %s", line, frame)
+		if entry == last {
+			repeated++
+			if repeated >= maxRepeatedFrames {
+				continue
+			}
+		} else {
+			writeRepeated(&b, repeated)
+			last, repeated = entry, 0
+		}
+		b.WriteString(entry)
+	}
+	writeRepeated(&b, repeated)
+	return b.String()
+}
+
+// maxRepeatedFrames is the number of times an identical traceback line
+// is printed in a row.
+const maxRepeatedFrames = 3
+
+// writeRepeated notes how many times a traceback line was repeated beyond
+// the ones printed.
+func writeRepeated(b *strings.Builder, repeated int) {
+	if hidden := repeated - maxRepeatedFrames + 1; hidden > 0 {
+		fmt.Fprintf(b, "\n  [Previous line repeated %d more times]", hidden)
+	}
+}
+
+// Line returns the source line of the token the error is tied to.
+func (r RuntimeError) Line() int {
+	return r.token.Line
+}
+
+// Message returns the error message without location context.
+func (r RuntimeError) Message() string {
+	return r.message
+}
+
+// Position returns the source span of the token the error is tied to.
+func (r RuntimeError) Position() token.Position {
+	return r.token.Position
+}
+
+// Trace returns the calls that were active when the error occurred,
+// outermost first. It is empty for errors raised in top-level code.
+func (r RuntimeError) Trace() []Frame {
+	return r.trace
+}
+
+// Unwrap returns the error that caused this one, such as the error of the
+// context that interrupted the program, or nil.
+func (r RuntimeError) Unwrap() error {
+	return r.cause
+}
+
+// WithCause returns a copy of the error caused by err.
+func (r RuntimeError) WithCause(err error) RuntimeError {
+	r.cause = err
+	return r
+}
+
+// WithTrace returns a copy of the error carrying the given call frames.
+func (r RuntimeError) WithTrace(frames []Frame) RuntimeError {
+	r.trace = frames
+	return r
+}
+
+func (r RuntimeError) span() (token.Position, string) {
+	return r.token.Position, r.token.Lexeme
+}
+
+// Frame is a function call that is in progress.
+type Frame struct {
+	// Name of the called function, "init" for class constructors.
+	Function string
+	// Name of the class declaring the function, empty for plain functions.
+	Class string
+	// The closing parenthesis of the call expression.
+	Call token.Token
+}
+
+func (f Frame) String() string {
+	if f.Class != "" {
+		return fmt.Sprintf("%s.%s()", f.Class, f.Function)
+	}
+	return f.Function + "()"
 }
@@ -9,7 +9,9 @@ import (
 // StaticError represents a scanner/parser error with source location context.
 // It implements the error interface.
 type StaticError struct {
-	line    int
+	pos token.Position
+	// The lexeme the error is about, empty when not tied to a token.
+	lexeme  string
 	where   string
 	message string
 }
@@ -17,12 +19,18 @@ type StaticError struct {
 // Error formats the report as:
 // [line N] Error{where}: {message}
 func (r StaticError) Error() string {
-	return fmt.Sprintf("[line %d] Error%s: %s", r.line, r.where, r.message)
+	return fmt.Sprintf("[line %d] Error%s: %s", r.pos.Line, r.where, r.message)
 }
 
 // StaticErrorAtLine constructs a Report tied to a specific line without token context.
 func StaticErrorAtLine(line int, message string) StaticError {
-	return StaticError{line, "", message}
+	return StaticError{token.Position{Line: line}, "", "", message}
+}
+
+// StaticErrorAt constructs a Report tied to a span of source without token context,
+// such as a character the scanner could not turn into a token.
+func StaticErrorAt(pos token.Position, message string) StaticError {
+	return StaticError{pos, "", "", message}
 }
 
 // StaticErrorAtToken constructs a Report tied to a token location.
@@ -30,9 +38,28 @@ func StaticErrorAtLine(line int, message string) StaticError {
 // otherwise it is reported as "at '<lexeme>'".
 func StaticErrorAtToken(t token.Token, message string) StaticError {
 	if t.Type == token.EOF {
-		return StaticError{t.Line, " at end", message}
+		return StaticError{t.Position, t.Lexeme, " at end", message}
 	} else {
 		at := fmt.Sprintf(" at '%s'", t.Lexeme)
-		return StaticError{t.Line, at, message}
+		return StaticError{t.Position, t.Lexeme, at, message}
 	}
 }
+
+// Line returns the source line the error is tied to.
+func (r StaticError) Line() int {
+	return r.pos.Line
+}
+
+// Position returns the source span the error is tied to.
+func (r StaticError) Position() token.Position {
+	return r.pos
+}
+
+// Message returns the error message without location context.
+func (r StaticError) Message() string {
+	return r.message
+}
+
+func (r StaticError) span() (token.Position, string) {
+	return r.pos, r.lexeme
+}
@@ -0,0 +1,492 @@
+// Package format reprints Lox programs in a canonical style.
+//
+// Statements go on their own line, blocks open on the line of the statement
+// they belong to and close on a line of their own, and binary operators are
+// surrounded by single spaces. Comments are kept, along with single blank
+// lines between statements. Expressions are printed on one line, so the
+// comments inside one go on lines of their own before its statement.
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// DefaultIndent is the number of spaces per nesting level used by default.
+const DefaultIndent = 2
+
+// Options control the output of Source.
+type Options struct {
+	// Indent is the number of spaces per nesting level.
+	Indent int
+}
+
+// Source formats the program src, read from the file called name.
+// Programs with scan or parse errors are not formatted: the error is
+// returned as reported by the scanner or parser.
+func Source(name string, src string, opts Options) (string, error) {
+	sc := scanner.NewFileScanner(name, src)
+	tokens, err := sc.ScanTokens()
+	if err != nil {
+		return "", err
+	}
+
+	pa := parser.NewParser(tokens)
+	prog, err := pa.Parse()
+	if err != nil {
+		return "", err
+	}
+
+	p := printer{
+		src:      src,
+		indent:   strings.Repeat(" ", opts.Indent),
+		spans:    pa.Spans(),
+		comments: sc.Comments(),
+	}
+	p.sequence(prog, math.MaxInt, p.statement)
+	return p.out.String(), nil
+}
+
+// printer writes statements to out. As a [parser.StmtVisitor] it prints a
+// statement from the current position to its last character, leaving the
+// line open for a trailing comment. As a [parser.ExprVisitor] it returns
+// the text of an expression.
+type printer struct {
+	out strings.Builder
+	// The source being formatted.
+	src    string
+	indent string
+	// Number of enclosing blocks.
+	depth int
+	// The source tokens of each statement.
+	spans map[parser.NodeID]parser.Span
+	// Comments that are not printed yet, in source order.
+	comments []token.Token
+}
+
+// sequence prints stmts on separate lines, with print, along with the
+// comments found before the source line close. Blank lines between
+// statements and comments are kept, though only one in a row.
+func (p *printer) sequence(stmts []parser.Stmt, close int, print func(parser.Stmt)) {
+	// the source line the previous statement or comment ended on
+	last := 0
+	for n, stmt := range stmts {
+		span := p.spans[stmt.Id()]
+		last = p.ownLines(span.First.Line, last)
+		p.separate(last, span.First.Line)
+		// comments inside a simple statement can only go before it
+		if !compound(stmt) {
+			p.ownLines(span.Last.Line, 0)
+		}
+		p.writeIndent()
+		print(stmt)
+
+		// a comment on the last line belongs to this statement,
+		// unless another one follows on the same line
+		next := close
+		if n+1 < len(stmts) {
+			next = p.spans[stmts[n+1].Id()].First.Line
+		}
+		if next != span.Last.Line {
+			p.trailing(span.Last.Line)
+		}
+		p.out.WriteString("\n")
+		last = span.Last.Line
+
+		// comments inside a compound statement that no block
+		// of it could hold, such as before a body that isn't a block
+		if len(p.comments) > 0 && p.comments[0].Line < last {
+			last = p.ownLines(last+1, last)
+		}
+	}
+	p.ownLines(close, last)
+}
+
+// block prints a braced body whose braces are on the source lines open
+// and close, printing each statement in it with print.
+func (p *printer) block(stmts []parser.Stmt, open, close int, print func(parser.Stmt)) {
+	p.out.WriteString("{")
+	// a comment after the opening brace stays there,
+	// unless a statement of the body starts on the same line
+	commented := false
+	if open < close && (len(stmts) == 0 || p.spans[stmts[0].Id()].First.Line > open) {
+		commented = p.trailing(open)
+	}
+	if len(stmts) == 0 && !commented && !p.commentBefore(close) {
+		p.out.WriteString("}")
+		return
+	}
+
+	p.out.WriteString("\n")
+	p.depth++
+	p.sequence(stmts, close, print)
+	p.depth--
+	p.writeIndent()
+	p.out.WriteString("}")
+}
+
+// commentBefore reports whether a comment remains before the source line.
+func (p *printer) commentBefore(line int) bool {
+	return len(p.comments) > 0 && p.comments[0].Line < line
+}
+
+// ownLines prints the comments before the source line on lines of their
+// own, after the statement or comment that ended on the source line last.
+// It returns the line the last comment printed is on, or last if none is.
+func (p *printer) ownLines(line int, last int) int {
+	for p.commentBefore(line) {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+
+		p.separate(last, comment.Line)
+		p.writeIndent()
+		p.out.WriteString(comment.Lexeme + "\n")
+		last = comment.Line
+	}
+	return last
+}
+
+// trailing prints the comment on the source line, if there is one,
+// at the end of the current line and reports whether it did.
+func (p *printer) trailing(line int) bool {
+	if len(p.comments) == 0 || p.comments[0].Line != line {
+		return false
+	}
+	p.out.WriteString(" " + p.comments[0].Lexeme)
+	p.comments = p.comments[1:]
+	return true
+}
+
+// trails reports whether comment follows code on its source line.
+func (p *printer) trails(comment token.Token) bool {
+	lineStart := strings.LastIndexByte(p.src[:comment.Start], '\n') + 1
+	return strings.TrimSpace(p.src[lineStart:comment.Start]) != ""
+}
+
+// separate prints a blank line if one separated the source lines last
+// and next. There is none before the first statement or comment of a body,
+// for which last is 0.
+func (p *printer) separate(last, next int) {
+	if last > 0 && next > last+1 {
+		p.out.WriteString("\n")
+	}
+}
+
+func (p *printer) writeIndent() {
+	p.out.WriteString(strings.Repeat(p.indent, p.depth))
+}
+
+// compound reports whether stmt has a body, whose own statements
+// and comments are printed on lines of their own.
+func compound(stmt parser.Stmt) bool {
+	switch stmt.(type) {
+	case parser.Block, parser.Class, parser.Function, parser.If, parser.While, parser.For:
+		return true
+	}
+	return false
+}
+
+func (p *printer) statement(stmt parser.Stmt) {
+	stmt.Accept(p)
+}
+
+func (p *printer) method(stmt parser.Stmt) {
+	p.function(stmt.(parser.Function))
+}
+
+// body prints the body of a control flow statement, a block or a single
+// statement, after its header.
+func (p *printer) body(stmt parser.Stmt) {
+	if block, ok := stmt.(parser.Block); ok {
+		span := p.spans[block.Id()]
+		p.out.WriteString(" ")
+		p.block(block.Stmts, span.First.Line, span.Last.Line, p.statement)
+		return
+	}
+
+	first := p.spans[stmt.Id()].First.Line
+	if !p.commentBefore(first) {
+		p.out.WriteString(" ")
+		stmt.Accept(p)
+		return
+	}
+	// comments between the header and the statement stay there, a comment
+	// after the header on its line, and the statement goes on its own line
+	if comment := p.comments[0]; p.trails(comment) {
+		p.trailing(comment.Line)
+	}
+	p.out.WriteString("\n")
+	p.depth++
+	p.ownLines(first, 0)
+	p.writeIndent()
+	stmt.Accept(p)
+	p.depth--
+}
+
+// function prints the parameters and body of a function or method.
+func (p *printer) function(stmt parser.Function) {
+	params := make([]string, len(stmt.Params))
+	for n, param := range stmt.Params {
+		params[n] = param.Lexeme
+	}
+	p.out.WriteString(stmt.Name.Lexeme + "(" + strings.Join(params, ", ") + ") ")
+
+	span := p.spans[stmt.Id()]
+	p.block(stmt.Body, span.First.Line, span.Last.Line, p.statement)
+}
+
+func (p *printer) expr(expr parser.Expr) string {
+	text, _ := expr.Accept(p)
+	return text.(string)
+}
+
+// join returns the text of exprs separated by commas.
+func (p *printer) join(exprs []parser.Expr) string {
+	texts := make([]string, len(exprs))
+	for n, expr := range exprs {
+		texts[n] = p.expr(expr)
+	}
+	return strings.Join(texts, ", ")
+}
+
+// VisitBlockStmt implements [parser.StmtVisitor].
+func (p *printer) VisitBlockStmt(stmt parser.Block) (any, error) {
+	span := p.spans[stmt.Id()]
+	p.block(stmt.Stmts, span.First.Line, span.Last.Line, p.statement)
+	return nil, nil
+}
+
+// VisitClassStmt implements [parser.StmtVisitor].
+func (p *printer) VisitClassStmt(stmt parser.Class) (any, error) {
+	p.out.WriteString("class " + stmt.Name.Lexeme + " ")
+	if stmt.Superclass != nil {
+		p.out.WriteString("< " + stmt.Superclass.Name.Lexeme + " ")
+	}
+
+	methods := make([]parser.Stmt, len(stmt.Methods))
+	for n, method := range stmt.Methods {
+		methods[n] = method
+	}
+	span := p.spans[stmt.Id()]
+	p.block(methods, span.First.Line, span.Last.Line, p.method)
+	return nil, nil
+}
+
+// VisitFunctionStmt implements [parser.StmtVisitor].
+func (p *printer) VisitFunctionStmt(stmt parser.Function) (any, error) {
+	p.out.WriteString("fun ")
+	p.function(stmt)
+	return nil, nil
+}
+
+// VisitIfStmt implements [parser.StmtVisitor].
+func (p *printer) VisitIfStmt(stmt parser.If) (any, error) {
+	p.out.WriteString("if (" + p.expr(stmt.Condition) + ")")
+	p.body(stmt.ThenBranch)
+	if stmt.ElseBranch == nil {
+		return nil, nil
+	}
+
+	// else follows the closing brace of a block, otherwise it starts a line,
+	// after the comment on the last line of the branch if else doesn't
+	if _, ok := stmt.ThenBranch.(parser.Block); ok {
+		p.out.WriteString(" ")
+	} else {
+		then := p.spans[stmt.ThenBranch.Id()].Last.Line
+		if p.spans[stmt.ElseBranch.Id()].First.Line > then {
+			p.trailing(then)
+		}
+		p.out.WriteString("\n")
+		p.writeIndent()
+	}
+	p.out.WriteString("else")
+	p.body(stmt.ElseBranch)
+	return nil, nil
+}
+
+// VisitWhileStmt implements [parser.StmtVisitor].
+func (p *printer) VisitWhileStmt(stmt parser.While) (any, error) {
+	p.out.WriteString("while (" + p.expr(stmt.Condition) + ")")
+	p.body(stmt.Body)
+	return nil, nil
+}
+
+// VisitForStmt implements [parser.StmtVisitor].
+func (p *printer) VisitForStmt(stmt parser.For) (any, error) {
+	p.out.WriteString("for (")
+	if stmt.Initializer != nil {
+		stmt.Initializer.Accept(p)
+	} else {
+		p.out.WriteString(";")
+	}
+	if stmt.Condition != nil {
+		p.out.WriteString(" " + p.expr(stmt.Condition))
+	}
+	p.out.WriteString(";")
+	if stmt.Increment != nil {
+		p.out.WriteString(" " + p.expr(stmt.Increment))
+	}
+	p.out.WriteString(")")
+	p.body(stmt.Body)
+	return nil, nil
+}
+
+// VisitBreakStmt implements [parser.StmtVisitor].
+func (p *printer) VisitBreakStmt(stmt parser.Break) (any, error) {
+	p.out.WriteString("break;")
+	return nil, nil
+}
+
+// VisitContinueStmt implements [parser.StmtVisitor].
+func (p *printer) VisitContinueStmt(stmt parser.Continue) (any, error) {
+	p.out.WriteString("continue;")
+	return nil, nil
+}
+
+// VisitBreakpointStmt implements [parser.StmtVisitor].
+func (p *printer) VisitBreakpointStmt(stmt parser.Breakpoint) (any, error) {
+	p.out.WriteString("breakpoint;")
+	return nil, nil
+}
+
+// VisitImportStmt implements [parser.StmtVisitor].
+func (p *printer) VisitImportStmt(stmt parser.Import) (any, error) {
+	p.out.WriteString("import " + stmt.Path.Lexeme)
+	if stmt.Alias != nil {
+		p.out.WriteString(" as " + stmt.Alias.Lexeme)
+	}
+	p.out.WriteString(";")
+	return nil, nil
+}
+
+// VisitReturnStmt implements [parser.StmtVisitor].
+func (p *printer) VisitReturnStmt(stmt parser.Return) (any, error) {
+	if stmt.Value == nil {
+		p.out.WriteString("return;")
+	} else {
+		p.out.WriteString("return " + p.expr(stmt.Value) + ";")
+	}
+	return nil, nil
+}
+
+// VisitVarStmt implements [parser.StmtVisitor].
+func (p *printer) VisitVarStmt(stmt parser.Var) (any, error) {
+	if stmt.Initializer == nil {
+		p.out.WriteString("var " + stmt.Name.Lexeme + ";")
+	} else {
+		p.out.WriteString("var " + stmt.Name.Lexeme + " = " + p.expr(stmt.Initializer) + ";")
+	}
+	return nil, nil
+}
+
+// VisitExpressionStmt implements [parser.StmtVisitor].
+func (p *printer) VisitExpressionStmt(stmt parser.Expression) (any, error) {
+	p.out.WriteString(p.expr(stmt.Expression) + ";")
+	return nil, nil
+}
+
+// VisitPrintStmt implements [parser.StmtVisitor].
+func (p *printer) VisitPrintStmt(stmt parser.Print) (any, error) {
+	p.out.WriteString("print " + p.expr(stmt.Expression) + ";")
+	return nil, nil
+}
+
+// VisitAssignmentExpr implements [parser.ExprVisitor].
+func (p *printer) VisitAssignmentExpr(expr parser.Assignment) (any, error) {
+	return expr.Name.Lexeme + " = " + p.expr(expr.Value), nil
+}
+
+// VisitCallExpr implements [parser.ExprVisitor].
+func (p *printer) VisitCallExpr(expr parser.Call) (any, error) {
+	return p.expr(expr.Callee) + "(" + p.join(expr.Arguments) + ")", nil
+}
+
+// VisitGetExpr implements [parser.ExprVisitor].
+func (p *printer) VisitGetExpr(expr parser.Get) (any, error) {
+	return p.expr(expr.Object) + "." + expr.Name.Lexeme, nil
+}
+
+// VisitSetExpr implements [parser.ExprVisitor].
+func (p *printer) VisitSetExpr(expr parser.Set) (any, error) {
+	return p.expr(expr.Object) + "." + expr.Name.Lexeme + " = " + p.expr(expr.Value), nil
+}
+
+// VisitSuperExpr implements [parser.ExprVisitor].
+func (p *printer) VisitSuperExpr(expr parser.Super) (any, error) {
+	return "super." + expr.Method.Lexeme, nil
+}
+
+// VisitThisExpr implements [parser.ExprVisitor].
+func (p *printer) VisitThisExpr(expr parser.This) (any, error) {
+	return "this", nil
+}
+
+// VisitListExpr implements [parser.ExprVisitor].
+func (p *printer) VisitListExpr(expr parser.List) (any, error) {
+	return "[" + p.join(expr.Elements) + "]", nil
+}
+
+// VisitMapExpr implements [parser.ExprVisitor].
+func (p *printer) VisitMapExpr(expr parser.Map) (any, error) {
+	entries := make([]string, len(expr.Keys))
+	for n, key := range expr.Keys {
+		entries[n] = p.expr(key) + ": " + p.expr(expr.Values[n])
+	}
+	return "{" + strings.Join(entries, ", ") + "}", nil
+}
+
+// VisitIndexExpr implements [parser.ExprVisitor].
+func (p *printer) VisitIndexExpr(expr parser.Index) (any, error) {
+	return p.expr(expr.Object) + "[" + p.expr(expr.Index) + "]", nil
+}
+
+// VisitIndexSetExpr implements [parser.ExprVisitor].
+func (p *printer) VisitIndexSetExpr(expr parser.IndexSet) (any, error) {
+	return p.expr(expr.Object) + "[" + p.expr(expr.Index) + "] = " + p.expr(expr.Value), nil
+}
+
+// VisitVariableExpr implements [parser.ExprVisitor].
+func (p *printer) VisitVariableExpr(expr parser.Variable) (any, error) {
+	return expr.Name.Lexeme, nil
+}
+
+// VisitLiteralExpr implements [parser.ExprVisitor].
+func (p *printer) VisitLiteralExpr(expr parser.Literal) (any, error) {
+	switch value := expr.Value.(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case float64:
+		// Lox has no exponent notation
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case string:
+		return `"` + value + `"`, nil
+	}
+	panic("format: unexpected literal")
+}
+
+// VisitLogicalExpr implements [parser.ExprVisitor].
+func (p *printer) VisitLogicalExpr(expr parser.Logical) (any, error) {
+	return p.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + p.expr(expr.Right), nil
+}
+
+// VisitGroupingExpr implements [parser.ExprVisitor].
+func (p *printer) VisitGroupingExpr(expr parser.Grouping) (any, error) {
+	return "(" + p.expr(expr.Expression) + ")", nil
+}
+
+// VisitUnaryExpr implements [parser.ExprVisitor].
+func (p *printer) VisitUnaryExpr(expr parser.Unary) (any, error) {
+	return expr.Operator.Lexeme + p.expr(expr.Right), nil
+}
+
+// VisitBinaryExpr implements [parser.ExprVisitor].
+func (p *printer) VisitBinaryExpr(expr parser.Binary) (any, error) {
+	return p.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + p.expr(expr.Right), nil
+}
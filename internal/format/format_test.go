@@ -0,0 +1,245 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceSuccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "empty program",
+			source: "",
+			want:   "",
+		},
+		{
+			name:   "one statement per line",
+			source: "var a=1;print a;a=a+1 ;",
+			want:   "var a = 1;\nprint a;\na = a + 1;\n",
+		},
+		{
+			name:   "expressions are spaced canonically",
+			source: "print -a*(b+c)/2>=!d and e or f==nil;",
+			want:   "print -a * (b + c) / 2 >= !d and e or f == nil;\n",
+		},
+		{
+			name:   "numbers are printed canonically",
+			source: "print 1.50 + 007 + 2.0;",
+			want:   "print 1.5 + 7 + 2;\n",
+		},
+		{
+			name:   "calls, properties, lists and maps",
+			source: "obj . field=f( 1,\"two\" )[ 0 ];var l=[1,2 ,3];var m={ \"a\":1,\"b\" : [ ] };var e={};m[\"c\"]=super_.x;",
+			want: "obj.field = f(1, \"two\")[0];\nvar l = [1, 2, 3];\n" +
+				"var m = {\"a\": 1, \"b\": []};\nvar e = {};\nm[\"c\"] = super_.x;\n",
+		},
+		{
+			name: "blocks and control flow",
+			source: `if(a){print 1;}else if(b)
+{print 2;}else{print 3;}
+while(x<3){x=x+1;if(x==2)continue;}
+for(var i=0;i<3;i=i+1)print i;
+for(;;){break;}
+{breakpoint ;}`,
+			want: `if (a) {
+  print 1;
+} else if (b) {
+  print 2;
+} else {
+  print 3;
+}
+while (x < 3) {
+  x = x + 1;
+  if (x == 2) continue;
+}
+for (var i = 0; i < 3; i = i + 1) print i;
+for (;;) {
+  break;
+}
+{
+  breakpoint;
+}
+`,
+		},
+		{
+			name:   "else after a single statement starts a line",
+			source: "if (a) print 1; else print 2;",
+			want:   "if (a) print 1;\nelse print 2;\n",
+		},
+		{
+			name: "functions and classes",
+			source: `fun add(a,b){return a+b;}
+fun nothing(){}
+class Base{}
+class Point<Base{init(x,y){this.x=x;this.y=y;}
+sum(){return this.x+this.y;}
+up(){return super.up();}}`,
+			want: `fun add(a, b) {
+  return a + b;
+}
+fun nothing() {}
+class Base {}
+class Point < Base {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  sum() {
+    return this.x + this.y;
+  }
+  up() {
+    return super.up();
+  }
+}
+`,
+		},
+		{
+			name:   "imports",
+			source: `import "lib/math" ;import "util.lox"as u;`,
+			want:   "import \"lib/math\";\nimport \"util.lox\" as u;\n",
+		},
+		{
+			name: "single blank lines are kept",
+			source: `var a = 1;
+
+
+
+var b = 2;
+fun f() {
+
+  print a;
+
+  print b;
+
+}
+`,
+			want: `var a = 1;
+
+var b = 2;
+fun f() {
+  print a;
+
+  print b;
+}
+`,
+		},
+		{
+			name: "comments are kept",
+			source: `// header
+
+// about a
+var a = 1;  // trailing
+fun f() { // opening
+  // leading
+  return a
+    // inside
+    + 1;
+  // closing
+} // after
+class C {
+  // before method
+  m() {}
+}
+{
+  // only a comment
+}
+// footer
+`,
+			want: `// header
+
+// about a
+var a = 1; // trailing
+fun f() { // opening
+  // leading
+  // inside
+  return a + 1;
+  // closing
+} // after
+class C {
+  // before method
+  m() {}
+}
+{
+  // only a comment
+}
+// footer
+`,
+		},
+		{
+			name:   "a comment after statements on one line belongs to the last",
+			source: "var a = 1; var b = 2; // both\n{ print a; } // block\n",
+			want:   "var a = 1;\nvar b = 2; // both\n{\n  print a;\n} // block\n",
+		},
+		{
+			name:   "comments before a body that is not a block stay before it",
+			source: "while (a)\n  // loop\n  a = a - 1;\n",
+			want:   "while (a)\n  // loop\n  a = a - 1;\n",
+		},
+		{
+			name:   "a comment after a header stays on its line",
+			source: "if (true) // cond\n  print 1;\nfor (;;) // ever\n\n  // and ever\n  break;\n",
+			want:   "if (true) // cond\n  print 1;\nfor (;;) // ever\n  // and ever\n  break;\n",
+		},
+		{
+			name:   "comments around else stay on their lines",
+			source: "if (a) print 1; // one\nelse // other\n  print 2;\nif (b) print 3; else print 4; // both\n",
+			want:   "if (a) print 1; // one\nelse // other\n  print 2;\nif (b) print 3;\nelse print 4; // both\n",
+		},
+		{
+			name:   "comments inside an expression go before its statement",
+			source: "print [1, // one\n  2];\nvar a = 1 +\n  // two\n  2;\n",
+			want:   "// one\nprint [1, 2];\n// two\nvar a = 1 + 2;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			got, err := Source("test.lox", tt.source, Options{Indent: DefaultIndent})
+			r.NoError(err)
+			r.Equal(tt.want, got)
+
+			// formatting is idempotent
+			again, err := Source("test.lox", got, Options{Indent: DefaultIndent})
+			r.NoError(err)
+			r.Equal(got, again)
+		})
+	}
+}
+
+func TestSourceIndentWidth(t *testing.T) {
+	r := require.New(t)
+
+	got, err := Source("", "fun f() { if (a) { print 1; } }", Options{Indent: 4})
+
+	r.NoError(err)
+	r.Equal("fun f() {\n    if (a) {\n        print 1;\n    }\n}\n", got)
+}
+
+func TestSourceSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"scan error", "var a = @;", "[line 1] Error: Unexpected character: @"},
+		{"parse error", "print 1", "[line 1] Error at end: Expect ';' after value."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			got, err := Source("", tt.source, Options{Indent: DefaultIndent})
+
+			r.EqualError(err, tt.wantErr)
+			r.Empty(got)
+		})
+	}
+}
@@ -1,26 +1,201 @@
 package interpreter
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	internalErrors "github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
 
 type Callable interface {
 	/// Calls this callable with the given arguments.
 	Call(interpreter *Interpreter, args []Object) (Object, error)
-	/// Returns the number of arguments this callable expects.
-	Arity() int
+	/// Returns the number of arguments this callable accepts.
+	Arity() Arity
+}
+
+// Arity is the range of argument counts a callable accepts.
+type Arity struct {
+	Min int
+	// Max is the largest accepted count, or -1 when there is no upper bound.
+	Max int
+}
+
+// ExactArity accepts exactly n arguments.
+func ExactArity(n int) Arity {
+	return Arity{n, n}
+}
+
+// RangeArity accepts between min and max arguments, inclusive.
+func RangeArity(min, max int) Arity {
+	return Arity{min, max}
+}
+
+// VariadicArity accepts min or more arguments.
+func VariadicArity(min int) Arity {
+	return Arity{min, -1}
+}
+
+// Accepts reports whether a call with n arguments is allowed.
+func (a Arity) Accepts(n int) bool {
+	return n >= a.Min && (a.Max < 0 || n <= a.Max)
+}
+
+func (a Arity) String() string {
+	switch {
+	case a.Max < 0:
+		return fmt.Sprintf("at least %d", a.Min)
+	case a.Min == a.Max:
+		return fmt.Sprintf("%d", a.Min)
+	default:
+		return fmt.Sprintf("%d to %d", a.Min, a.Max)
+	}
+}
+
+// Kind classifies runtime values for native argument checks.
+type Kind int
+
+const (
+	AnyKind Kind = iota
+	NumberKind
+	StringKind
+	BooleanKind
+	CallableKind
+	ListKind
+	MapKind
+)
+
+func (k Kind) String() string {
+	return [...]string{"value", "number", "string", "boolean", "function", "list", "map"}[k]
+}
+
+// matches reports whether obj is a value of kind k.
+func (k Kind) matches(obj Object) bool {
+	switch k {
+	case NumberKind:
+		_, ok := obj.(float64)
+		return ok
+	case StringKind:
+		_, ok := obj.(string)
+		return ok
+	case BooleanKind:
+		_, ok := obj.(bool)
+		return ok
+	case CallableKind:
+		_, ok := obj.(Callable)
+		return ok
+	case ListKind:
+		_, ok := obj.(*LoxList)
+		return ok
+	case MapKind:
+		_, ok := obj.(*LoxMap)
+		return ok
+	}
+	return true
 }
 
-// TODO: A native function that always accept 0 args is not helpful.
-// Maybe we can generalize this?
-type NativeFun func() (Object, error)
+// Host is the interpreter a native function is called from. Both the
+// tree-walking [Interpreter] and the bytecode VM implement it.
+type Host interface {
+	// Stdout returns the writer program output goes to.
+	Stdout() io.Writer
+	// Allocate charges n bytes, about to be allocated by the native
+	// function for the values it returns or stores, to the memory budget
+	// of the program. The native should fail with the returned error, if
+	// any, rather than allocate them.
+	Allocate(n int) error
+	// Sandbox returns the file system access granted to the program.
+	Sandbox() *Sandbox
+}
+
+// NativeFn is the Go implementation of a native function.
+// args has already been checked against the function's arity and parameter kinds.
+// A returned error that is not a RuntimeError is reported at the call's closing paren.
+type NativeFn func(host Host, args []Object) (Object, error)
+
+// NativeFunction is a function implemented in Go and callable from Lox.
+type NativeFunction struct {
+	name  string
+	arity Arity
+	// The expected kind of each argument. The last kind also applies to any
+	// extra arguments of a variadic call. An empty slice disables the checks.
+	params []Kind
+	fn     NativeFn
+	// The list or map the function is a method of, nil for other natives.
+	receiver Object
+}
+
+func NewNativeFunction(name string, arity Arity, params []Kind, fn NativeFn) *NativeFunction {
+	return &NativeFunction{name: name, arity: arity, params: params, fn: fn}
+}
+
+// DefineNative registers a native function in the global environment.
+func (i *Interpreter) DefineNative(name string, arity Arity, params []Kind, fn NativeFn) {
+	i.Define(name, NewNativeFunction(name, arity, params, fn))
+}
+
+// Call implements [Callable].
+func (f *NativeFunction) Call(interpreter *Interpreter, args []Object) (Object, error) {
+	return f.Invoke(interpreter, args)
+}
+
+// Invoke checks args against the parameter kinds and runs the function for host.
+// The caller is responsible for checking the arity.
+func (f *NativeFunction) Invoke(host Host, args []Object) (Object, error) {
+	if len(f.params) > 0 {
+		for n, arg := range args {
+			kind := f.params[min(n, len(f.params)-1)]
+			if !kind.matches(arg) {
+				return nil, fmt.Errorf("Argument %d to '%s' must be a %s.", n+1, f.name, kind)
+			}
+		}
+	}
+	return f.fn(host, args)
+}
+
+// callAt calls the function and ties any plain error it returns to paren,
+// the closing parenthesis of the call expression.
+func (f *NativeFunction) callAt(interpreter *Interpreter, paren token.Token, args []Object) (Object, error) {
+	result, err := f.Call(interpreter, args)
+	if err == nil {
+		return result, nil
+	}
+
+	var runtimeErr internalErrors.RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return nil, err
+	}
+	return nil, internalErrors.RuntimeErrorAtToken(paren, err.Error())
+}
+
+// Arity implements [Callable].
+func (f *NativeFunction) Arity() Arity {
+	return f.arity
+}
+
+// Name returns the name the function is called by.
+func (f *NativeFunction) Name() string {
+	return f.name
+}
 
-func (f NativeFun) Call(_interpreter *Interpreter, _args []Object) (Object, error) {
-	return f()
+func (f *NativeFunction) String() string {
+	return fmt.Sprintf("<native fn %s>", f.name)
 }
 
-func (f NativeFun) Arity() int {
-	return 0
+// Natives returns the native functions every program starts with.
+func Natives() []*NativeFunction {
+	natives := []*NativeFunction{
+		NewNativeFunction("clock", ExactArity(0), nil, clock),
+	}
+	natives = append(natives, stringNatives()...)
+	natives = append(natives, mathNatives()...)
+	natives = append(natives, conversionNatives()...)
+	return append(natives, fileNatives()...)
 }
 
-func Clock() (Object, error) {
+func clock(_ Host, _ []Object) (Object, error) {
 	return float64(time.Now().Unix()), nil
 }
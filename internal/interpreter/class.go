@@ -3,14 +3,14 @@ package interpreter
 type LoxClass struct {
 	Name       string
 	Superclass *LoxClass
-	methods    map[string]LoxFunction
+	methods    map[string]*LoxFunction
 }
 
-func NewLoxClass(name string, superclass *LoxClass, methods map[string]LoxFunction) *LoxClass {
+func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction) *LoxClass {
 	return &LoxClass{name, superclass, methods}
 }
 
-func (cls *LoxClass) FindMethod(name string) (LoxFunction, bool) {
+func (cls *LoxClass) FindMethod(name string) (*LoxFunction, bool) {
 	if method, ok := cls.methods[name]; ok {
 		return method, true
 	}
@@ -19,7 +19,7 @@ func (cls *LoxClass) FindMethod(name string) (LoxFunction, bool) {
 		return cls.Superclass.FindMethod(name)
 	}
 
-	return LoxFunction{}, false
+	return nil, false
 }
 
 func (cls *LoxClass) String() string {
@@ -29,6 +29,7 @@ func (cls *LoxClass) String() string {
 // Call implements [Callable].
 func (cls *LoxClass) Call(interpreter *Interpreter, arguments []Object) (Object, error) {
 	instance := NewLoxInstance(cls)
+	interpreter.budget.Alloc(InstanceSize)
 
 	init, exist := cls.FindMethod("init")
 	if exist {
@@ -39,10 +40,10 @@ func (cls *LoxClass) Call(interpreter *Interpreter, arguments []Object) (Object,
 }
 
 // Arity implements [Callable].
-func (cls *LoxClass) Arity() int {
+func (cls *LoxClass) Arity() Arity {
 	init, exist := cls.FindMethod("init")
 	if !exist {
-		return 0
+		return ExactArity(0)
 	}
 	return init.Arity()
 }
@@ -0,0 +1,112 @@
+package interpreter
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxFixedDigits is the largest number of decimals toFixed accepts.
+const maxFixedDigits = 100
+
+// numberPattern matches the strings num parses: a decimal number as in
+// source code, optionally signed and with an exponent.
+var numberPattern = regexp.MustCompile(`^[+-]?[0-9]+(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// conversionNatives returns the native functions converting between
+// numbers and strings.
+func conversionNatives() []*NativeFunction {
+	return []*NativeFunction{
+		NewNativeFunction("str", ExactArity(1), []Kind{AnyKind}, str),
+		NewNativeFunction("num", ExactArity(1), []Kind{StringKind}, num),
+		NewNativeFunction("toFixed", ExactArity(2), []Kind{NumberKind}, toFixed),
+		NewNativeFunction("format", VariadicArity(1), []Kind{StringKind, AnyKind}, format),
+	}
+}
+
+// formatNumber formats n the way print statements display it: integers
+// without a fractional part and other numbers with as many digits as it
+// takes to tell them apart, never with an exponent.
+func formatNumber(n float64) string {
+	switch {
+	case math.IsNaN(n):
+		return "NaN"
+	case math.IsInf(n, 1):
+		return "Infinity"
+	case math.IsInf(n, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// str returns its argument as print displays it.
+func str(host Host, args []Object) (Object, error) {
+	if s, ok := args[0].(string); ok {
+		return s, nil
+	}
+	return newString(host, Stringify(args[0]))
+}
+
+// num parses a number, surrounding whitespace aside, returning nil if the
+// string isn't one.
+func num(_ Host, args []Object) (Object, error) {
+	s := strings.TrimSpace(args[0].(string))
+	if !numberPattern.MatchString(s) {
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		// out of range
+		return nil, nil
+	}
+	return n, nil
+}
+
+// toFixed formats a number with a fixed number of decimals.
+func toFixed(host Host, args []Object) (Object, error) {
+	n := args[0].(float64)
+	digits, err := integer(args[1], "Digit count")
+	if err != nil {
+		return nil, err
+	}
+	if digits < 0 || digits > maxFixedDigits {
+		return nil, errors.New("Digit count must be between 0 and 100.")
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return newString(host, formatNumber(n))
+	}
+	return newString(host, strconv.FormatFloat(n, 'f', int(digits), 64))
+}
+
+// format replaces each {} in a string with the next argument, as print
+// displays it. {{ and }} stand for literal braces.
+func format(host Host, args []Object) (Object, error) {
+	template, values := args[0].(string), args[1:]
+
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if i+1 < len(template) {
+			next := template[i+1]
+			switch {
+			case c == '{' && next == '}':
+				if len(values) == 0 {
+					return nil, errors.New("Too few arguments for the format string.")
+				}
+				b.WriteString(Stringify(values[0]))
+				values = values[1:]
+				i++
+				continue
+			case (c == '{' || c == '}') && next == c:
+				i++
+			}
+		}
+		b.WriteByte(c)
+	}
+	if len(values) > 0 {
+		return nil, errors.New("Too many arguments for the format string.")
+	}
+	return newString(host, b.String())
+}
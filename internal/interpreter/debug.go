@@ -0,0 +1,60 @@
+package interpreter
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/parser"
+)
+
+// Debugger is told about every statement the interpreter is about to
+// execute, blocks aside, and may pause the program there by not
+// returning until it should go on.
+type Debugger interface {
+	// Before is called before stmt runs. A returned error aborts the
+	// program, as if stmt had failed with it.
+	Before(stmt parser.Stmt) error
+}
+
+// SetDebugger attaches d to the interpreter, or detaches the current
+// debugger when d is nil.
+func (i *Interpreter) SetDebugger(d Debugger) {
+	i.debugger = d
+}
+
+// Depth returns the number of Lox calls in progress.
+func (i *Interpreter) Depth() int {
+	return len(i.frames)
+}
+
+// Frames returns the Lox calls in progress, outermost first.
+func (i *Interpreter) Frames() []errors.Frame {
+	return slices.Clone(i.frames)
+}
+
+// Scopes returns the variables of the current environment and of every
+// environment enclosing it, innermost first. The last scope holds the
+// globals of the running module; the natives shared by all modules are
+// left out.
+func (i *Interpreter) Scopes() []map[string]Object {
+	var scopes []map[string]Object
+	for env := &i.environment; env != nil && env != i.builtins; env = env.enclosing {
+		scopes = append(scopes, maps.Clone(env.values))
+	}
+	return scopes
+}
+
+// Evaluate evaluates expr in the current environment, as if it appeared
+// in the statement about to run. expr isn't resolved, so its variables
+// are looked up by name, from the innermost scope out. The debugger isn't
+// told about the statements of the functions expr calls.
+func (i *Interpreter) Evaluate(expr parser.Expr) (Object, error) {
+	debugger, globals, mark := i.debugger, i.globals, len(i.temps)
+	// unresolved variables are looked up in globals
+	i.debugger, i.globals = nil, i.environment
+	defer func() {
+		i.debugger, i.globals, i.temps = debugger, globals, i.temps[:mark]
+	}()
+	return i.evaluate(expr)
+}
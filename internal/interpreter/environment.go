@@ -85,6 +85,20 @@ func (e Environment) GetAt(distance int, name string) Object {
 	return e.ancestor(distance).values[name]
 }
 
+// distance returns the number of environments between e and the nearest
+// one defining name, which the resolver computes statically for the
+// variables of a program.
+func (e Environment) distance(name string) (int, bool) {
+	curr := &e
+	for d := 0; curr != nil; d++ {
+		if _, ok := curr.values[name]; ok {
+			return d, true
+		}
+		curr = curr.enclosing
+	}
+	return 0, false
+}
+
 func (e Environment) ancestor(distance int) Environment {
 	curr := e
 	for i := 0; i < distance; i++ {
@@ -0,0 +1,236 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Sandbox is the file system access granted to a program: the directories
+// whose files it may read, and those whose files it may write. The zero
+// value grants none, so the file natives fail until the host allows them.
+//
+// Paths are resolved against the working directory of the process. A path
+// is only accessible if it lies in one of the allowed directories once
+// cleaned, and symbolic links leading out of the directory are not
+// followed.
+type Sandbox struct {
+	read, write []string
+}
+
+// AllowRead grants reading the files under dir.
+func (s *Sandbox) AllowRead(dir string) error {
+	root, err := sandboxRoot(dir)
+	if err != nil {
+		return err
+	}
+	s.read = append(s.read, root)
+	return nil
+}
+
+// AllowWrite grants creating, changing and removing the files under dir.
+func (s *Sandbox) AllowWrite(dir string) error {
+	root, err := sandboxRoot(dir)
+	if err != nil {
+		return err
+	}
+	s.write = append(s.write, root)
+	return nil
+}
+
+// sandboxRoot returns the absolute path of dir, which must be a directory.
+func sandboxRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	return abs, nil
+}
+
+// access is an operation on files, named as in error messages.
+type access string
+
+const (
+	reading access = "read"
+	writing access = "write"
+)
+
+// open returns the allowed directory holding path, opened so that nothing
+// outside of it can be reached, and the path relative to it.
+func (s *Sandbox) open(path string, op access) (*os.Root, string, error) {
+	roots := s.read
+	if op == writing {
+		roots = s.write
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, dir := range roots {
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		root, err := os.OpenRoot(dir)
+		if err != nil {
+			return nil, "", fileError(op, path, err)
+		}
+		return root, rel, nil
+	}
+
+	if len(roots) == 0 {
+		return nil, "", fmt.Errorf("Not allowed to %s files.", op)
+	}
+	return nil, "", fmt.Errorf("Not allowed to %s '%s', which is outside of the allowed directories.", op, path)
+}
+
+// fileError reports err, returned when accessing path, to the program.
+func fileError(op access, path string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Errorf("Could not %s '%s': %s.", op, path, err)
+}
+
+// fileNatives returns the native functions accessing files, as allowed by
+// the [Sandbox] of the host.
+func fileNatives() []*NativeFunction {
+	return []*NativeFunction{
+		NewNativeFunction("readFile", ExactArity(1), []Kind{StringKind}, readFile),
+		NewNativeFunction("writeFile", ExactArity(2), []Kind{StringKind}, writeFile),
+		NewNativeFunction("appendFile", ExactArity(2), []Kind{StringKind}, appendFile),
+		NewNativeFunction("listDir", ExactArity(1), []Kind{StringKind}, listDir),
+		NewNativeFunction("exists", ExactArity(1), []Kind{StringKind}, exists),
+		NewNativeFunction("removeFile", ExactArity(1), []Kind{StringKind}, removeFile),
+	}
+}
+
+// withFile calls fn with the allowed directory holding path and the path
+// relative to it, reporting the errors it returns.
+func withFile(host Host, path string, op access, fn func(root *os.Root, rel string) (Object, error)) (Object, error) {
+	root, rel, err := host.Sandbox().open(path, op)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	result, err := fn(root, rel)
+	if err != nil {
+		return nil, fileError(op, path, err)
+	}
+	return result, nil
+}
+
+// readFile returns the content of a file, charged to the memory budget
+// before it is read, so that programs can't read more than they may hold.
+func readFile(host Host, args []Object) (Object, error) {
+	path := args[0].(string)
+	root, rel, err := host.Sandbox().open(path, reading)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	f, err := root.Open(rel)
+	if err != nil {
+		return nil, fileError(reading, path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fileError(reading, path, err)
+	}
+	if err := host.Allocate(StringSize + int(info.Size())); err != nil {
+		return nil, err
+	}
+	// a file growing in the meantime is read as it was when charged
+	data, err := io.ReadAll(io.LimitReader(f, info.Size()))
+	if err != nil {
+		return nil, fileError(reading, path, err)
+	}
+	return string(data), nil
+}
+
+// writeFile replaces the content of a file, creating it if needed.
+func writeFile(host Host, args []Object) (Object, error) {
+	return withFile(host, args[0].(string), writing, func(root *os.Root, rel string) (Object, error) {
+		return nil, root.WriteFile(rel, []byte(args[1].(string)), 0o644)
+	})
+}
+
+// appendFile adds to the end of a file, creating it if needed.
+func appendFile(host Host, args []Object) (Object, error) {
+	return withFile(host, args[0].(string), writing, func(root *os.Root, rel string) (Object, error) {
+		f, err := root.OpenFile(rel, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.WriteString(args[1].(string)); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return nil, f.Close()
+	})
+}
+
+// listDir returns the sorted names of the entries of a directory.
+func listDir(host Host, args []Object) (Object, error) {
+	entries, err := withFile(host, args[0].(string), reading, func(root *os.Root, rel string) (Object, error) {
+		dir, err := root.Open(rel)
+		if err != nil {
+			return nil, err
+		}
+		defer dir.Close()
+		entries, err := dir.ReadDir(-1)
+		slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+		return entries, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]Object, len(entries.([]fs.DirEntry)))
+	size := ListBytes(len(names))
+	for i, entry := range entries.([]fs.DirEntry) {
+		names[i] = entry.Name()
+		size += StringBytes(entry.Name())
+	}
+	if err := host.Allocate(size); err != nil {
+		return nil, err
+	}
+	return NewLoxList(names), nil
+}
+
+// exists reports whether there is a file or directory at a path.
+func exists(host Host, args []Object) (Object, error) {
+	return withFile(host, args[0].(string), reading, func(root *os.Root, rel string) (Object, error) {
+		_, err := root.Stat(rel)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// removeFile removes a file or an empty directory.
+func removeFile(host Host, args []Object) (Object, error) {
+	return withFile(host, args[0].(string), writing, func(root *os.Root, rel string) (Object, error) {
+		return nil, root.Remove(rel)
+	})
+}
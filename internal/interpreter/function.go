@@ -8,31 +8,58 @@ import (
 )
 
 type LoxFunction struct {
-	declaration   parser.Function
-	closure       Environment
+	declaration parser.Function
+	closure     Environment
+	// The top-level environment of the module declaring the function,
+	// where the global variables it refers to are looked up.
+	globals       Environment
 	isInitializer bool
+	// Name of the class declaring this function as a method, empty otherwise.
+	class string
 }
 
-func NewLoxFunction(declaration parser.Function, closure Environment, isInitializer bool) LoxFunction {
-	return LoxFunction{
+func NewLoxFunction(declaration parser.Function, closure Environment, globals Environment, isInitializer bool) *LoxFunction {
+	return &LoxFunction{
 		declaration,
 		closure,
+		globals,
 		isInitializer,
+		"",
 	}
 }
 
-func (lf LoxFunction) bind(this LoxInstance) LoxFunction {
+// NewLoxMethod creates a function declared as a method of the class called class.
+func NewLoxMethod(declaration parser.Function, closure Environment, globals Environment, class string) *LoxFunction {
+	fn := NewLoxFunction(declaration, closure, globals, declaration.Name.Lexeme == "init")
+	fn.class = class
+	return fn
+}
+
+// bind returns a new function with this bound to the instance, so every
+// access to a method makes a distinct value.
+func (lf *LoxFunction) bind(this *LoxInstance) *LoxFunction {
 	env := NewEnclosedEnvinronment(&lf.closure)
 	env.Define("this", this)
-	return LoxFunction{
+	return &LoxFunction{
 		lf.declaration,
 		env,
+		lf.globals,
 		lf.isInitializer,
+		lf.class,
 	}
 }
 
 // Call implements [LoxCallable].
-func (lf LoxFunction) Call(interpreter *Interpreter, args []Object) (Object, error) {
+func (lf *LoxFunction) Call(interpreter *Interpreter, args []Object) (Object, error) {
+	// run with the globals of the module the function was declared in
+	enclosingGlobals := interpreter.globals
+	interpreter.globals = lf.globals
+	interpreter.saved = append(interpreter.saved, enclosingGlobals)
+	defer func() {
+		interpreter.saved = interpreter.saved[:len(interpreter.saved)-1]
+		interpreter.globals = enclosingGlobals
+	}()
+
 	environment := NewEnclosedEnvinronment(&lf.closure)
 
 	for i, p := range lf.declaration.Params {
@@ -62,10 +89,10 @@ func (lf LoxFunction) Call(interpreter *Interpreter, args []Object) (Object, err
 }
 
 // Arity implements [LoxCallable].
-func (lf LoxFunction) Arity() int {
-	return len(lf.declaration.Params)
+func (lf *LoxFunction) Arity() Arity {
+	return ExactArity(len(lf.declaration.Params))
 }
 
-func (lf LoxFunction) String() string {
+func (lf *LoxFunction) String() string {
 	return fmt.Sprintf("<fn %s>", lf.declaration.Name.Lexeme)
 }
@@ -10,14 +10,14 @@ type LoxInstance struct {
 	fields map[string]Object
 }
 
-func NewLoxInstance(cls *LoxClass) LoxInstance {
-	return LoxInstance{
+func NewLoxInstance(cls *LoxClass) *LoxInstance {
+	return &LoxInstance{
 		class:  cls,
 		fields: make(map[string]Object),
 	}
 }
 
-func (i LoxInstance) Get(name token.Token) (Object, error) {
+func (i *LoxInstance) Get(name token.Token) (Object, error) {
 	if field, ok := i.fields[name.Lexeme]; ok {
 		return field, nil
 	}
@@ -33,10 +33,10 @@ func (i LoxInstance) Get(name token.Token) (Object, error) {
 	)
 }
 
-func (i LoxInstance) Set(name token.Token, value Object) {
+func (i *LoxInstance) Set(name token.Token, value Object) {
 	i.fields[name.Lexeme] = value
 }
 
-func (i LoxInstance) String() string {
+func (i *LoxInstance) String() string {
 	return i.class.Name + " instance"
 }
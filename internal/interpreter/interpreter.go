@@ -1,7 +1,12 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/nt54hamnghi/golox/internal/errors"
 	"github.com/nt54hamnghi/golox/internal/parser"
@@ -10,31 +15,111 @@ import (
 
 type Object any
 
-// The global environment for the interpreter.
-var globals = NewEnvironment()
-
 type Interpreter struct {
+	// The outermost environment, owned by this interpreter and shared by
+	// every module. It holds the natives and values defined by the host.
+	builtins *Environment
+	// The top-level environment of the module whose code is running,
+	// enclosed by builtins. Variables the resolver leaves unresolved are
+	// looked up here.
+	globals Environment
 	// The currently entered environment.
 	environment Environment
 	// A map of variable usages (via node identity) to
 	// their resolved location in the environment stack.
 	locals map[parser.NodeID]int
+	// Where print statements write their output.
+	stdout io.Writer
+	// The Lox calls in progress, outermost first.
+	frames []errors.Frame
+	// The module each import statement was linked to.
+	imports map[parser.NodeID]*LoxModule
+	// Told about every statement before it runs, nil when not debugging.
+	debugger Debugger
+	// The environments set aside by the blocks and calls in progress.
+	saved []Environment
+	// The values of the expressions being evaluated, held until the
+	// expression using them is.
+	temps []Object
+	// The steps taken by the running program, and the limits it runs under.
+	budget Budget
+	// The files programs may access.
+	sandbox Sandbox
 }
 
 func (i *Interpreter) Resolve(expr parser.Expr, depth int) {
 	i.locals[expr.Id()] = depth
 }
 
+// NewInterpreter creates an interpreter with its own global environment,
+// so independent interpreters never observe each other's variables.
 func NewInterpreter() Interpreter {
-	globals.Define("clock", NativeFun(Clock))
+	builtins := NewEnvironment()
+	for _, native := range Natives() {
+		builtins.Define(native.Name(), native)
+	}
+	for name, value := range Constants() {
+		builtins.Define(name, value)
+	}
+	globals := NewEnclosedEnvinronment(&builtins)
 	return Interpreter{
+		builtins: &builtins,
+		globals:  globals,
 		// the interpreter starts with the global environment as its current environment.
 		environment: globals,
 		locals:      make(map[parser.NodeID]int),
+		stdout:      os.Stdout,
+		imports:     make(map[parser.NodeID]*LoxModule),
+		budget:      NewBudget(),
 	}
 }
 
+// SetLimits bounds the work done by the programs interpreted from now on.
+func (i *Interpreter) SetLimits(l Limits) {
+	i.budget.SetLimits(l)
+}
+
+// SetStdout redirects the output of print statements to w.
+func (i *Interpreter) SetStdout(w io.Writer) {
+	i.stdout = w
+}
+
+// Stdout returns the writer program output goes to.
+// Native functions that produce output should write to it.
+func (i *Interpreter) Stdout() io.Writer {
+	return i.stdout
+}
+
+// Sandbox implements [Host].
+func (i *Interpreter) Sandbox() *Sandbox {
+	return &i.sandbox
+}
+
+// Define binds name to value in the environment shared by all modules,
+// redefining it if it already exists.
+func (i *Interpreter) Define(name string, value Object) {
+	i.builtins.Define(name, value)
+}
+
+// Global returns the value bound to name in the global environment,
+// or, failing that, in the environment shared by all modules.
+func (i *Interpreter) Global(name string) (Object, bool) {
+	if value, ok := i.globals.values[name]; ok {
+		return value, true
+	}
+	value, ok := i.builtins.values[name]
+	return value, ok
+}
+
 func (i *Interpreter) Interpret(prog []parser.Stmt) error {
+	return i.InterpretContext(context.Background(), prog)
+}
+
+// InterpretContext executes prog like Interpret, stopping it with a
+// runtime error if ctx is done before it ends. The error wraps the one
+// of ctx.
+func (i *Interpreter) InterpretContext(ctx context.Context, prog []parser.Stmt) error {
+	defer i.budget.Start(ctx, i.roots)()
 	for _, stmt := range prog {
 		_, err := i.execute(stmt)
 		if err != nil {
@@ -45,18 +130,70 @@ func (i *Interpreter) Interpret(prog []parser.Stmt) error {
 	return nil
 }
 
+// CallContext calls callee, which must accept len(args) arguments, from Go.
+// The call is a run of its own, stopped once ctx is done. It gets the full
+// budget, unless made while a program runs, which it is then charged to.
+func (i *Interpreter) CallContext(ctx context.Context, callee Callable, args []Object) (Object, error) {
+	defer i.budget.Start(ctx, i.roots)()
+	return callee.Call(i, args)
+}
+
+// InterpretInteractive executes prog like Interpret and also prints the
+// value of every top-level expression statement, unless it is nil.
+func (i *Interpreter) InterpretInteractive(prog []parser.Stmt) error {
+	defer i.budget.Start(context.Background(), i.roots)()
+	for _, stmt := range prog {
+		expr, ok := stmt.(parser.Expression)
+		if !ok {
+			if _, err := i.execute(stmt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		v, err := i.evaluate(expr.Expression)
+		i.temps = i.temps[:0]
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			fmt.Fprintln(i.stdout, Stringify(v))
+		}
+	}
+
+	return nil
+}
+
 func (i *Interpreter) execute(stmt parser.Stmt) (any, error) {
-	return stmt.Accept(i)
+	i.budget.Step()
+	if _, ok := stmt.(parser.Block); !ok && i.debugger != nil {
+		if err := i.debugger.Before(stmt); err != nil {
+			return nil, err
+		}
+	}
+	mark := len(i.temps)
+	result, err := stmt.Accept(i)
+	i.temps = i.temps[:mark]
+	return result, err
 }
 
 func (i *Interpreter) evaluate(expr parser.Expr) (Object, error) {
-	return expr.Accept(i)
+	mark := len(i.temps)
+	value, err := expr.Accept(i)
+	// the values of the operands are no longer needed, unlike this one
+	i.temps = append(i.temps[:mark], value)
+	return value, err
 }
 
 func (i *Interpreter) executeBlock(stmts []parser.Stmt, environment Environment) (any, error) {
 	current := i.environment
 	i.environment = environment
+	i.saved = append(i.saved, current)
+	// the variables defined by the block are charged as they are defined
+	i.budget.Alloc(environmentBytes(environment))
 	defer func() {
+		i.budget.Free(environmentBytes(environment))
+		i.saved = i.saved[:len(i.saved)-1]
 		i.environment = current
 	}()
 
@@ -76,9 +213,18 @@ func (i *Interpreter) VisitBlockStmt(stmt parser.Block) (any, error) {
 	return i.executeBlock(stmt.Stmts, inner)
 }
 
+// define binds name to value in the current environment, charging the
+// binding to the budget unless it redefines a variable.
+func (i *Interpreter) define(name string, value Object) {
+	if _, ok := i.environment.values[name]; !ok {
+		i.budget.Alloc(BindingSize)
+	}
+	i.environment.Define(name, value)
+}
+
 // VisitClassStmt implements [parser.StmtVisitor].
 func (i *Interpreter) VisitClassStmt(stmt parser.Class) (any, error) {
-	i.environment.Define(stmt.Name.Lexeme, nil)
+	i.define(stmt.Name.Lexeme, nil)
 
 	var superclass *LoxClass
 	if stmt.Superclass != nil {
@@ -97,13 +243,14 @@ func (i *Interpreter) VisitClassStmt(stmt parser.Class) (any, error) {
 
 		current := i.environment
 		i.environment = NewEnclosedEnvinronment(&current)
-		i.environment.Define("super", superclass)
+		i.budget.Alloc(EnvironmentSize)
+		i.define("super", superclass)
 	}
 
-	methods := make(map[string]LoxFunction)
+	methods := make(map[string]*LoxFunction)
 	for _, method := range stmt.Methods {
-		isInitializer := method.Name.Lexeme == "init"
-		methods[method.Name.Lexeme] = NewLoxFunction(method, i.environment, isInitializer)
+		methods[method.Name.Lexeme] = NewLoxMethod(method, i.environment, i.globals, stmt.Name.Lexeme)
+		i.budget.Alloc(ClosureSize)
 	}
 
 	class := NewLoxClass(stmt.Name.Lexeme, superclass, methods)
@@ -118,6 +265,9 @@ func (i *Interpreter) VisitClassStmt(stmt parser.Class) (any, error) {
 // VisitWhileStmt implements [parser.StmtVisitor].
 func (i *Interpreter) VisitWhileStmt(stmt parser.While) (any, error) {
 	for {
+		if err := i.budget.Check(stmt.Keyword); err != nil {
+			return nil, err
+		}
 		condition, err := i.evaluate(stmt.Condition)
 		if err != nil {
 			return nil, err
@@ -125,16 +275,106 @@ func (i *Interpreter) VisitWhileStmt(stmt parser.While) (any, error) {
 		if !isTruthy(condition) {
 			return nil, nil
 		}
-		if _, err := i.execute(stmt.Body); err != nil {
+		if done, err := i.executeLoopBody(stmt.Body); done || err != nil {
+			return nil, err
+		}
+	}
+}
+
+// VisitForStmt implements [parser.StmtVisitor].
+func (i *Interpreter) VisitForStmt(stmt parser.For) (any, error) {
+	// the initializer gets its own scope, shared by every iteration
+	current := i.environment
+	i.environment = NewEnclosedEnvinronment(&current)
+	i.budget.Alloc(EnvironmentSize)
+	defer func() {
+		i.budget.Free(environmentBytes(i.environment))
+		i.environment = current
+	}()
+
+	if stmt.Initializer != nil {
+		if _, err := i.execute(stmt.Initializer); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		if err := i.budget.Check(stmt.Keyword); err != nil {
 			return nil, err
 		}
+		if stmt.Condition != nil {
+			condition, err := i.evaluate(stmt.Condition)
+			if err != nil {
+				return nil, err
+			}
+			if !isTruthy(condition) {
+				return nil, nil
+			}
+		}
+		if done, err := i.executeLoopBody(stmt.Body); done || err != nil {
+			return nil, err
+		}
+		// runs after a continue as well
+		if stmt.Increment != nil {
+			if _, err := i.evaluate(stmt.Increment); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// executeLoopBody executes one iteration of a loop body and reports
+// whether the loop is done because the body executed a break statement.
+// A continue statement simply ends the iteration.
+func (i *Interpreter) executeLoopBody(body parser.Stmt) (bool, error) {
+	_, err := i.execute(body)
+
+	switch err.(type) {
+	case BreakLoop:
+		return true, nil
+	case ContinueLoop:
+		return false, nil
+	default:
+		return false, err
 	}
 }
 
+// VisitBreakStmt implements [parser.StmtVisitor].
+func (i *Interpreter) VisitBreakStmt(stmt parser.Break) (any, error) {
+	return nil, BreakLoop{}
+}
+
+// VisitContinueStmt implements [parser.StmtVisitor].
+func (i *Interpreter) VisitContinueStmt(stmt parser.Continue) (any, error) {
+	return nil, ContinueLoop{}
+}
+
+// VisitBreakpointStmt implements [parser.StmtVisitor].
+// The attached debugger, if any, pauses before the statement runs,
+// which then does nothing.
+func (i *Interpreter) VisitBreakpointStmt(stmt parser.Breakpoint) (any, error) {
+	return nil, nil
+}
+
 // VisitFunctionStmt implements [parser.StmtVisitor].
 func (i *Interpreter) VisitFunctionStmt(stmt parser.Function) (any, error) {
-	function := NewLoxFunction(stmt, i.environment, false)
-	i.environment.Define(stmt.Name.Lexeme, function)
+	function := NewLoxFunction(stmt, i.environment, i.globals, false)
+	i.budget.Alloc(ClosureSize)
+	i.define(stmt.Name.Lexeme, function)
+	return nil, nil
+}
+
+// VisitImportStmt implements [parser.StmtVisitor].
+func (i *Interpreter) VisitImportStmt(stmt parser.Import) (any, error) {
+	module, ok := i.imports[stmt.Id()]
+	if !ok {
+		panic("unlinked import statement")
+	}
+
+	if err := i.load(module); err != nil {
+		return nil, err
+	}
+	i.define(stmt.Name().Lexeme, module)
 	return nil, nil
 }
 
@@ -170,7 +410,7 @@ func (i *Interpreter) VisitVarStmt(stmt parser.Var) (any, error) {
 		}
 	}
 
-	i.environment.Define(stmt.Name.Lexeme, value)
+	i.define(stmt.Name.Lexeme, value)
 	return nil, nil
 }
 
@@ -205,7 +445,7 @@ func (i *Interpreter) VisitPrintStmt(stmt parser.Print) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(stringify(v))
+	fmt.Fprintln(i.stdout, Stringify(v))
 	return nil, nil
 }
 
@@ -222,7 +462,7 @@ func (i *Interpreter) VisitAssignmentExpr(expr parser.Assignment) (any, error) {
 			return nil, err
 		}
 	} else {
-		if err := globals.Assign(expr.Name, value); err != nil {
+		if err := i.globals.Assign(expr.Name, value); err != nil {
 			return nil, err
 		}
 	}
@@ -253,14 +493,78 @@ func (i *Interpreter) VisitCallExpr(expr parser.Call) (any, error) {
 			"Can only call functions and classes.",
 		)
 	}
-	if len(args) != fun.Arity() {
+	if arity := fun.Arity(); !arity.Accepts(len(args)) {
 		return nil, errors.RuntimeErrorAtToken(
 			expr.Paren,
-			fmt.Sprintf("Expected %d arguments but got %d.", fun.Arity(), len(args)),
+			fmt.Sprintf("Expected %s arguments but got %d.", arity, len(args)),
 		)
 	}
 
-	return fun.Call(i, args)
+	i.budget.Step()
+	if err := i.budget.Check(expr.Paren); err != nil {
+		return nil, i.traced(err)
+	}
+	if native, ok := fun.(*NativeFunction); ok {
+		result, err := native.callAt(i, expr.Paren, args)
+		if err != nil {
+			return nil, err
+		}
+		if err := i.budget.Check(expr.Paren); err != nil {
+			return nil, i.traced(err)
+		}
+		return result, nil
+	}
+	if err := i.budget.CheckDepth(expr.Paren, len(i.frames)); err != nil {
+		return nil, i.traced(err)
+	}
+
+	i.frames = append(i.frames, newFrame(fun, expr.Paren))
+	result, err := fun.Call(i, args)
+	if err != nil {
+		err = i.traced(err)
+	}
+	i.frames = i.frames[:len(i.frames)-1]
+	if err != nil {
+		return nil, err
+	}
+	// the instance of a class is charged once it is initialized, and
+	// reported at the call, which has returned
+	if err := i.budget.Check(expr.Paren); err != nil {
+		return nil, i.traced(err)
+	}
+	return result, nil
+}
+
+// newFrame describes a call to fun made at paren.
+func newFrame(fun Callable, paren token.Token) errors.Frame {
+	frame := errors.Frame{Call: paren}
+	switch fun := fun.(type) {
+	case *LoxFunction:
+		frame.Function = fun.declaration.Name.Lexeme
+		frame.Class = fun.class
+	case *LoxClass:
+		// a class without an initializer is called as itself
+		if _, ok := fun.FindMethod("init"); ok {
+			frame.Function = "init"
+			frame.Class = fun.Name
+		} else {
+			frame.Function = fun.Name
+		}
+	default:
+		frame.Function = fmt.Sprint(fun)
+	}
+	return frame
+}
+
+// traced attaches the current call frames to err if it is a runtime error
+// that does not have a traceback yet. Since every call attaches its frames
+// on the way out, the innermost call, which sees the error first, wins.
+func (i *Interpreter) traced(err error) error {
+	runtimeErr, ok := err.(errors.RuntimeError)
+	if !ok || len(runtimeErr.Trace()) > 0 {
+		return err
+	}
+	return runtimeErr.WithTrace(slices.Clone(i.frames))
 }
 
 // VisitGetExpr implements [parser.ExprVisitor].
@@ -270,7 +574,16 @@ func (i *Interpreter) VisitGetExpr(expr parser.Get) (any, error) {
 		return nil, err
 	}
 
-	instance, ok := obj.(LoxInstance)
+	switch collection := obj.(type) {
+	case *LoxList:
+		return collection.Get(expr.Name)
+	case *LoxMap:
+		return collection.Get(expr.Name)
+	case *LoxModule:
+		return collection.Get(expr.Name)
+	}
+
+	instance, ok := obj.(*LoxInstance)
 	if !ok {
 		return nil, errors.RuntimeErrorAtToken(
 			expr.Name,
@@ -281,6 +594,113 @@ func (i *Interpreter) VisitGetExpr(expr parser.Get) (any, error) {
 	return instance.Get(expr.Name)
 }
 
+// VisitListExpr implements [parser.ExprVisitor].
+func (i *Interpreter) VisitListExpr(expr parser.List) (any, error) {
+	elements := make([]Object, 0, len(expr.Elements))
+	for _, e := range expr.Elements {
+		value, err := i.evaluate(e)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, value)
+	}
+	i.budget.Alloc(ListBytes(len(elements)))
+	if err := i.budget.Check(expr.Bracket); err != nil {
+		return nil, err
+	}
+	return NewLoxList(elements), nil
+}
+
+// VisitMapExpr implements [parser.ExprVisitor].
+func (i *Interpreter) VisitMapExpr(expr parser.Map) (any, error) {
+	m := NewLoxMap()
+	i.budget.Alloc(MapSize)
+	for n, k := range expr.Keys {
+		key, err := i.evaluate(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.evaluate(expr.Values[n])
+		if err != nil {
+			return nil, err
+		}
+		if err := i.setEntry(m, expr.Brace, key, value); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// VisitIndexExpr implements [parser.ExprVisitor].
+func (i *Interpreter) VisitIndexExpr(expr parser.Index) (any, error) {
+	obj, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	switch collection := obj.(type) {
+	case *LoxList:
+		return collection.At(expr.Bracket, index)
+	case *LoxMap:
+		return collection.At(expr.Bracket, index)
+	}
+
+	return nil, errors.RuntimeErrorAtToken(
+		expr.Bracket,
+		"Only lists and maps can be indexed.",
+	)
+}
+
+// VisitIndexSetExpr implements [parser.ExprVisitor].
+func (i *Interpreter) VisitIndexSetExpr(expr parser.IndexSet) (any, error) {
+	obj, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch collection := obj.(type) {
+	case *LoxList:
+		err = collection.SetAt(expr.Bracket, index, value)
+	case *LoxMap:
+		err = i.setEntry(collection, expr.Bracket, index, value)
+	default:
+		err = errors.RuntimeErrorAtToken(
+			expr.Bracket,
+			"Only lists and maps can be indexed.",
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// setEntry stores value under key in m like [LoxMap.SetAt], charging new
+// entries to the budget.
+func (i *Interpreter) setEntry(m *LoxMap, bracket token.Token, key Object, value Object) error {
+	size := len(m.Keys())
+	if err := m.SetAt(bracket, key, value); err != nil {
+		return err
+	}
+	i.budget.Alloc((len(m.Keys()) - size) * BindingSize)
+	return i.budget.Check(bracket)
+}
+
 // VisitSetExpr implements [parser.ExprVisitor].
 func (i *Interpreter) VisitSetExpr(expr parser.Set) (any, error) {
 	obj, err := i.evaluate(expr.Object)
@@ -288,7 +708,7 @@ func (i *Interpreter) VisitSetExpr(expr parser.Set) (any, error) {
 		return nil, err
 	}
 
-	instance, ok := obj.(LoxInstance)
+	instance, ok := obj.(*LoxInstance)
 	if !ok {
 		return nil, errors.RuntimeErrorAtToken(
 			expr.Name,
@@ -300,6 +720,12 @@ func (i *Interpreter) VisitSetExpr(expr parser.Set) (any, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := instance.fields[expr.Name.Lexeme]; !ok {
+		i.budget.Alloc(BindingSize)
+		if err := i.budget.Check(expr.Name); err != nil {
+			return nil, err
+		}
+	}
 	instance.Set(expr.Name, value)
 
 	return value, nil
@@ -309,7 +735,10 @@ func (i *Interpreter) VisitSetExpr(expr parser.Set) (any, error) {
 func (i *Interpreter) VisitSuperExpr(expr parser.Super) (any, error) {
 	distance, ok := i.locals[expr.Id()]
 	if !ok {
-		panic("unresolved super expression")
+		// only expressions evaluated for a debugger are left unresolved
+		if distance, ok = i.environment.distance("super"); !ok {
+			return nil, errors.RuntimeErrorAtToken(expr.Keyword, "Can't use 'super' outside of a class.")
+		}
 	}
 
 	obj := i.environment.GetAt(distance, "super")
@@ -319,7 +748,7 @@ func (i *Interpreter) VisitSuperExpr(expr parser.Super) (any, error) {
 	}
 
 	obj = i.environment.GetAt(distance-1, "this")
-	this, ok := obj.(LoxInstance)
+	this, ok := obj.(*LoxInstance)
 	if !ok {
 		panic(fmt.Sprintf("expected LoxInstance bound to 'this', got %T", obj))
 	}
@@ -352,7 +781,7 @@ func (i Interpreter) lookUpVariable(name token.Token, expr parser.Expr) (any, er
 	if distance, ok := i.locals[expr.Id()]; ok {
 		return i.environment.GetAt(distance, name.Lexeme), nil
 	} else {
-		return globals.Get(name)
+		return i.globals.Get(name)
 	}
 }
 
@@ -432,8 +861,16 @@ func (i *Interpreter) VisitBinaryExpr(expr parser.Binary) (any, error) {
 			return l + r, err
 		}
 		if l, r, err := checkOperands[string](left, right, expr.Operator); err == nil {
-			return l + r, err
+			i.budget.Alloc(StringBytes(l) + len(r))
+			if err := i.budget.Check(expr.Operator); err != nil {
+				return nil, err
+			}
+			return l + r, nil
 		}
+		return nil, errors.RuntimeErrorAtToken(
+			expr.Operator,
+			"Operands must be two numbers or two strings.",
+		)
 	case token.MINUS, token.STAR, token.SLASH, token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
 		l, r, err := checkOperands[float64](left, right, expr.Operator)
 		if err != nil {
@@ -512,9 +949,49 @@ func checkOperands[T float64 | string](left, right Object, token token.Token) (T
 	)
 }
 
-func stringify(obj Object) string {
-	if obj == nil {
+// Stringify formats obj the way print statements display it.
+func Stringify(obj Object) string {
+	switch v := obj.(type) {
+	case nil:
 		return "nil"
+	case float64:
+		return formatNumber(v)
 	}
 	return fmt.Sprint(obj)
 }
+
+// repr formats obj as an element of a collection, where strings are quoted
+// so that they can be told apart from other values. seen holds the
+// collections being formatted, so that one containing itself prints as
+// [...] or {...} instead of recursing forever.
+func repr(obj Object, seen map[Object]bool) string {
+	switch v := obj.(type) {
+	case string:
+		return `"` + v + `"`
+	case *LoxList:
+		if seen[v] {
+			return "[...]"
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		elements := make([]string, len(v.elements))
+		for i, e := range v.elements {
+			elements[i] = repr(e, seen)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *LoxMap:
+		if seen[v] {
+			return "{...}"
+		}
+		seen[v] = true
+		defer delete(seen, v)
+
+		entries := make([]string, len(v.keys))
+		for i, k := range v.keys {
+			entries[i] = repr(k, seen) + ": " + repr(v.entries[k], seen)
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	}
+	return Stringify(obj)
+}
@@ -1,10 +1,16 @@
 package interpreter
 
 import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/nt54hamnghi/golox/internal/parser"
 	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,13 +23,14 @@ func parseProgramForTest(t *testing.T, source string) []parser.Stmt {
 	r.NoError(err)
 
 	parser := parser.NewParser(tokens)
-	return parser.Parse()
+	prog, err := parser.Parse()
+	r.NoError(err)
+	return prog
 }
 
 func interpretProgramForTest(t *testing.T, source string) (Interpreter, error) {
 	t.Helper()
 
-	globals = NewEnvironment()
 	interpreter := NewInterpreter()
 	err := interpreter.Interpret(parseProgramForTest(t, source))
 	return interpreter, err
@@ -33,11 +40,12 @@ func assertGlobalValues(t *testing.T, got map[string]Object, want map[string]Obj
 	t.Helper()
 	r := require.New(t)
 
-	r.Contains(got, "clock")
+	// natives live in the builtins, which enclose the globals
+	r.NotContains(got, "clock")
 	for name, value := range want {
 		r.Equal(value, got[name])
 	}
-	r.Len(got, len(want)+1)
+	r.Len(got, len(want))
 }
 
 func TestInterpreterExpressionStatementsSuccess(t *testing.T) {
@@ -396,3 +404,537 @@ var quz = "outer quz";
 		})
 	}
 }
+
+func TestInterpreterInstancesHaveIsolatedGlobals(t *testing.T) {
+	r := require.New(t)
+
+	first, err := interpretProgramForTest(t, `var foo = "first";`)
+	r.NoError(err)
+	second, err := interpretProgramForTest(t, `var bar = "second";`)
+	r.NoError(err)
+
+	assertGlobalValues(t, first.globals.values, map[string]Object{"foo": "first"})
+	assertGlobalValues(t, second.globals.values, map[string]Object{"bar": "second"})
+}
+
+func TestInterpreterInstancesRunConcurrently(t *testing.T) {
+	source := `
+var total = 0;
+var i = 1;
+while (i <= 100) {
+	total = total + i;
+	i = i + 1;
+}
+`
+	for n := range 8 {
+		t.Run(fmt.Sprintf("interpreter %d", n), func(t *testing.T) {
+			t.Parallel()
+			r := require.New(t)
+
+			interpreter, err := interpretProgramForTest(t, source)
+
+			r.NoError(err)
+			assertGlobalValues(t, interpreter.globals.values, map[string]Object{
+				"total": float64(5050),
+				"i":     float64(101),
+			})
+		})
+	}
+}
+
+func TestInterpreterNativeFunctions(t *testing.T) {
+	sum := func(_ Host, args []Object) (Object, error) {
+		total := float64(0)
+		for _, arg := range args {
+			total += arg.(float64)
+		}
+		return total, nil
+	}
+	fail := func(_ Host, _ []Object) (Object, error) {
+		return nil, fmt.Errorf("Something went wrong.")
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		want    Object
+		wantErr string
+	}{
+		{
+			name:   "exact arity",
+			source: "var result = exact(1, 2);",
+			want:   float64(3),
+		},
+		{
+			name:    "exact arity mismatch",
+			source:  "var result = exact(1);",
+			wantErr: "Expected 2 arguments but got 1.\n[line 1]",
+		},
+		{
+			name:   "range arity lower bound",
+			source: "var result = ranged(1);",
+			want:   float64(1),
+		},
+		{
+			name:   "range arity upper bound",
+			source: "var result = ranged(1, 2, 3);",
+			want:   float64(6),
+		},
+		{
+			name:    "range arity mismatch",
+			source:  "var result = ranged(1, 2, 3, 4);",
+			wantErr: "Expected 1 to 3 arguments but got 4.\n[line 1]",
+		},
+		{
+			name:   "variadic with no extra arguments",
+			source: "var result = variadic();",
+			want:   float64(0),
+		},
+		{
+			name:   "variadic with many arguments",
+			source: "var result = variadic(1, 2, 3, 4, 5);",
+			want:   float64(15),
+		},
+		{
+			name:    "argument kind mismatch",
+			source:  "\nvar result = exact(1, \"2\");",
+			wantErr: "Argument 2 to 'exact' must be a number.\n[line 2]",
+		},
+		{
+			name:    "variadic arguments reuse the last kind",
+			source:  "var result = variadic(1, 2, nil);",
+			wantErr: "Argument 3 to 'variadic' must be a number.\n[line 1]",
+		},
+		{
+			name:    "native error is reported at the call",
+			source:  "\n\nvar result = fail();",
+			wantErr: "Something went wrong.\n[line 3]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			interpreter := NewInterpreter()
+			interpreter.DefineNative("exact", ExactArity(2), []Kind{NumberKind, NumberKind}, sum)
+			interpreter.DefineNative("ranged", RangeArity(1, 3), []Kind{NumberKind}, sum)
+			interpreter.DefineNative("variadic", VariadicArity(0), []Kind{NumberKind}, sum)
+			interpreter.DefineNative("fail", ExactArity(0), nil, fail)
+
+			err := interpreter.Interpret(parseProgramForTest(t, tt.source))
+
+			if tt.wantErr != "" {
+				r.EqualError(err, tt.wantErr)
+				return
+			}
+			r.NoError(err)
+			got, ok := interpreter.Global("result")
+			r.True(ok)
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestInterpreterPrintWritesToStdout(t *testing.T) {
+	r := require.New(t)
+
+	var stdout bytes.Buffer
+	interpreter := NewInterpreter()
+	interpreter.SetStdout(&stdout)
+
+	err := interpreter.Interpret(parseProgramForTest(t, `
+print "foo" + "bar";
+print 1 + 2;
+print nil;
+`))
+
+	r.NoError(err)
+	r.Equal("foobar\n3\nnil\n", stdout.String())
+}
+
+func TestInterpreterStringNatives(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    Object
+		wantErr string
+	}{
+		{name: "len counts characters", source: `var result = len("héllo, 世界");`, want: float64(9)},
+		{name: "len of empty string", source: `var result = len("");`, want: float64(0)},
+		{name: "charAt", source: `var result = charAt("naïve", 2);`, want: "ï"},
+		{name: "charAt out of range", source: `var result = charAt("abc", 3);`, wantErr: "String index out of range.\n[line 1]"},
+		{name: "charAt fractional index", source: `var result = charAt("abc", 0.5);`, wantErr: "String index must be an integer.\n[line 1]"},
+		{name: "substr to end", source: `var result = substr("日本語です", 2);`, want: "語です"},
+		{name: "substr range", source: `var result = substr("hello", 1, 3);`, want: "el"},
+		{name: "substr empty at end", source: `var result = substr("hello", 5, 5);`, want: ""},
+		{name: "substr reversed", source: `var result = substr("hello", 3, 1);`, wantErr: "Substring start must not be after its end.\n[line 1]"},
+		{name: "substr past end", source: `var result = substr("hello", 0, 6);`, wantErr: "String index out of range.\n[line 1]"},
+		{name: "indexOf counts characters", source: `var result = indexOf("größe", "ß");`, want: float64(3)},
+		{name: "indexOf missing", source: `var result = indexOf("abc", "d");`, want: float64(-1)},
+		{name: "startsWith", source: `var result = startsWith("lox", "lo");`, want: true},
+		{name: "endsWith", source: `var result = endsWith("lox", "lo");`, want: false},
+		{name: "split", source: `var result = join(split("a,b,,c", ","), "|");`, want: "a|b||c"},
+		{name: "split into characters", source: `var result = split("añb", "").length();`, want: float64(3)},
+		{name: "join prints elements", source: `var result = join([1, "two", nil, true], " ");`, want: "1 two nil true"},
+		{name: "join wants a list", source: `var result = join("abc", "");`, wantErr: "Argument 1 to 'join' must be a list.\n[line 1]"},
+		{name: "upper", source: `var result = upper("héllo");`, want: "HÉLLO"},
+		{name: "lower", source: `var result = lower("ÀB");`, want: "àb"},
+		{name: "trim", source: "var result = trim(\"  lox\t\n\");", want: "lox"},
+		{name: "replace", source: `var result = replace("a-b-c", "-", "+");`, want: "a+b+c"},
+		{name: "repeat", source: `var result = repeat("ab", 3);`, want: "ababab"},
+		{name: "repeat zero times", source: `var result = repeat("ab", 0);`, want: ""},
+		{name: "repeat negative", source: `var result = repeat("ab", -1);`, wantErr: "Repeat count must be a non-negative integer.\n[line 1]"},
+		{name: "repeat too long", source: `var result = repeat("ab", 1000000000000);`, wantErr: "Repeated string is too long.\n[line 1]"},
+		{name: "argument kinds are checked", source: `var result = upper(1);`, wantErr: "Argument 1 to 'upper' must be a string.\n[line 1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			interpreter, err := interpretProgramForTest(t, tt.source)
+
+			if tt.wantErr != "" {
+				r.EqualError(err, tt.wantErr)
+				return
+			}
+			r.NoError(err)
+			got, ok := interpreter.Global("result")
+			r.True(ok)
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestInterpreterMathNatives(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    Object
+		wantErr string
+	}{
+		{name: "floor", source: `var result = floor(-1.5);`, want: float64(-2)},
+		{name: "ceil", source: `var result = ceil(1.2);`, want: float64(2)},
+		{name: "round half away from zero", source: `var result = round(-2.5);`, want: float64(-3)},
+		{name: "abs", source: `var result = abs(-3);`, want: float64(3)},
+		{name: "sqrt", source: `var result = sqrt(16);`, want: float64(4)},
+		{name: "pow", source: `var result = pow(2, 10);`, want: float64(1024)},
+		{name: "min", source: `var result = min(3, -1, 2);`, want: float64(-1)},
+		{name: "max", source: `var result = max(3, -1, 2);`, want: float64(3)},
+		{name: "max of one", source: `var result = max(7);`, want: float64(7)},
+		{name: "max checks every argument", source: `var result = max(1, "2");`, wantErr: "Argument 2 to 'max' must be a number.\n[line 1]"},
+		{name: "sin", source: `var result = sin(PI / 2);`, want: float64(1)},
+		{name: "cos", source: `var result = cos(0);`, want: float64(1)},
+		{name: "atan2", source: `var result = atan2(1, 1) * 4;`, want: math.Pi},
+		{name: "log of E", source: `var result = log(E);`, want: float64(1)},
+		{name: "exp", source: `var result = exp(0);`, want: float64(1)},
+		{name: "argument kinds are checked", source: `var result = sqrt("4");`, wantErr: "Argument 1 to 'sqrt' must be a number.\n[line 1]"},
+		{name: "random is in [0, 1)", source: `var result = random(); result = result >= 0 and result < 1;`, want: true},
+		{name: "randomInt of a single value", source: `var result = randomInt(4, 4);`, want: float64(4)},
+		{name: "randomInt reversed bounds", source: `var result = randomInt(2, 1);`, wantErr: "Lower bound must not be greater than upper bound.\n[line 1]"},
+		{name: "randomInt fractional bound", source: `var result = randomInt(0, 1.5);`, wantErr: "Upper bound must be an integer.\n[line 1]"},
+		{name: "seed fractional", source: `var result = seed(0.5);`, wantErr: "Seed must be an integer.\n[line 1]"},
+		{
+			name: "seed repeats the sequence",
+			source: `seed(42);
+var a = random();
+var b = randomInt(1, 1000000);
+seed(42);
+var result = random() == a and randomInt(1, 1000000) == b;`,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			interpreter, err := interpretProgramForTest(t, tt.source)
+
+			if tt.wantErr != "" {
+				r.EqualError(err, tt.wantErr)
+				return
+			}
+			r.NoError(err)
+			got, ok := interpreter.Global("result")
+			r.True(ok)
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestInterpreterRandomIsPerInterpreter(t *testing.T) {
+	r := require.New(t)
+
+	seeded := NewInterpreter()
+	r.NoError(seeded.Interpret(parseProgramForTest(t, `seed(7);`)))
+	// draws made by another interpreter leave the sequence untouched
+	_, err := interpretProgramForTest(t, `seed(7); random(); random();`)
+	r.NoError(err)
+	r.NoError(seeded.Interpret(parseProgramForTest(t, `var result = random();`)))
+
+	fresh, err := interpretProgramForTest(t, `seed(7); var result = random();`)
+	r.NoError(err)
+	want, _ := fresh.Global("result")
+	got, _ := seeded.Global("result")
+	r.Equal(want, got)
+}
+
+func TestInterpreterConversionNatives(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    Object
+		wantErr string
+	}{
+		{name: "str of an integer", source: `var result = str(1.0);`, want: "1"},
+		{name: "str of a fraction", source: `var result = str(0.1 + 0.2);`, want: "0.30000000000000004"},
+		{name: "str of a large number", source: `var result = str(1000000000000000000000);`, want: "1000000000000000000000"},
+		{name: "str of a string", source: `var result = str("lox");`, want: "lox"},
+		{name: "str of nil", source: `var result = str(nil);`, want: "nil"},
+		{name: "str of a list", source: `var result = str([1, "a"]);`, want: `[1, "a"]`},
+		{name: "num", source: `var result = num("42");`, want: float64(42)},
+		{name: "num with sign, fraction and exponent", source: `var result = num(" -1.5e3 ");`, want: float64(-1500)},
+		{name: "num of garbage", source: `var result = num("12abc");`, want: nil},
+		{name: "num of empty string", source: `var result = num("");`, want: nil},
+		{name: "num rejects Go syntax", source: `var result = num("0x10");`, want: nil},
+		{name: "num rejects infinity", source: `var result = num("Inf");`, want: nil},
+		{name: "num out of range", source: `var result = num("1e400");`, want: nil},
+		{name: "num wants a string", source: `var result = num(1);`, wantErr: "Argument 1 to 'num' must be a string.\n[line 1]"},
+		{name: "toFixed rounds", source: `var result = toFixed(3.14159, 2);`, want: "3.14"},
+		{name: "toFixed pads", source: `var result = toFixed(2, 3);`, want: "2.000"},
+		{name: "toFixed no decimals", source: `var result = toFixed(2.5, 0);`, want: "2"},
+		{name: "toFixed negative digits", source: `var result = toFixed(1, -1);`, wantErr: "Digit count must be between 0 and 100.\n[line 1]"},
+		{name: "toFixed fractional digits", source: `var result = toFixed(1, 1.5);`, wantErr: "Digit count must be an integer.\n[line 1]"},
+		{name: "format", source: `var result = format("{} + {} = {}", 1, 2.5, 3.5);`, want: "1 + 2.5 = 3.5"},
+		{name: "format prints values", source: `var result = format("{}, {}, {}", nil, true, ["x"]);`, want: `nil, true, ["x"]`},
+		{name: "format escapes braces", source: `var result = format("{{}} {}}}", 1);`, want: "{} 1}"},
+		{name: "format without placeholders", source: `var result = format("plain");`, want: "plain"},
+		{name: "format too few arguments", source: `var result = format("{} {}", 1);`, wantErr: "Too few arguments for the format string.\n[line 1]"},
+		{name: "format too many arguments", source: `var result = format("{}", 1, 2);`, wantErr: "Too many arguments for the format string.\n[line 1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			interpreter, err := interpretProgramForTest(t, tt.source)
+
+			if tt.wantErr != "" {
+				r.EqualError(err, tt.wantErr)
+				return
+			}
+			r.NoError(err)
+			got, ok := interpreter.Global("result")
+			r.True(ok)
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestStringifyNumbers(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{value: 1, want: "1"},
+		{value: -0.5, want: "-0.5"},
+		{value: 123456789012, want: "123456789012"},
+		{value: 1e21, want: "1000000000000000000000"},
+		{value: 0.000001, want: "0.000001"},
+		{value: math.Inf(1), want: "Infinity"},
+		{value: math.Inf(-1), want: "-Infinity"},
+		{value: math.NaN(), want: "NaN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			require.Equal(t, tt.want, Stringify(tt.value))
+		})
+	}
+}
+
+func TestInterpreterFileNatives(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.Mkdir("data", 0o755))
+	require.NoError(t, os.Mkdir("out", 0o755))
+	require.NoError(t, os.WriteFile("data/notes.txt", []byte("héllo\n"), 0o644))
+	require.NoError(t, os.WriteFile("secret.txt", []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink("../secret.txt", "data/link.txt"))
+
+	tests := []struct {
+		name       string
+		read       []string
+		write      []string
+		source     string
+		want       Object
+		wantErr    string
+		wantOutput map[string]string
+	}{
+		{
+			name:    "reading is disabled by default",
+			source:  `var result = readFile("data/notes.txt");`,
+			wantErr: "Not allowed to read files.\n[line 1]",
+		},
+		{
+			name:    "writing is disabled by default",
+			read:    []string{"."},
+			source:  `writeFile("out/a.txt", "a");`,
+			wantErr: "Not allowed to write files.\n[line 1]",
+		},
+		{
+			name:   "readFile",
+			read:   []string{"data"},
+			source: `var result = readFile("data/notes.txt");`,
+			want:   "héllo\n",
+		},
+		{
+			name:   "readFile with an absolute path",
+			read:   []string{"data"},
+			source: `var result = readFile("` + filepath.ToSlash(filepath.Join(dir, "data", "notes.txt")) + `");`,
+			want:   "héllo\n",
+		},
+		{
+			name:    "readFile outside of the allowed directories",
+			read:    []string{"data"},
+			source:  `var result = readFile("secret.txt");`,
+			wantErr: "Not allowed to read 'secret.txt', which is outside of the allowed directories.\n[line 1]",
+		},
+		{
+			name:    "readFile escaping with dot-dot",
+			read:    []string{"data"},
+			source:  `var result = readFile("data/../secret.txt");`,
+			wantErr: "Not allowed to read 'data/../secret.txt', which is outside of the allowed directories.\n[line 1]",
+		},
+		{
+			name:    "readFile escaping with a symbolic link",
+			read:    []string{"data"},
+			source:  `var result = readFile("data/link.txt");`,
+			wantErr: "Could not read 'data/link.txt': path escapes from parent.\n[line 1]",
+		},
+		{
+			name:    "readFile of a missing file",
+			read:    []string{"data"},
+			source:  `var result = readFile("data/missing.txt");`,
+			wantErr: "Could not read 'data/missing.txt': no such file or directory.\n[line 1]",
+		},
+		{
+			name:    "reading doesn't allow writing",
+			read:    []string{"out"},
+			write:   []string{"data"},
+			source:  `writeFile("out/a.txt", "a");`,
+			wantErr: "Not allowed to write 'out/a.txt', which is outside of the allowed directories.\n[line 1]",
+		},
+		{
+			name:   "exists",
+			read:   []string{"data"},
+			source: `var result = exists("data/notes.txt") and !exists("data/missing.txt");`,
+			want:   true,
+		},
+		{
+			name:   "listDir",
+			read:   []string{"data"},
+			source: `var result = join(listDir("data"), ",");`,
+			want:   "link.txt,notes.txt",
+		},
+		{
+			name:       "writeFile and appendFile",
+			write:      []string{"out"},
+			source:     `writeFile("out/log.txt", "one"); writeFile("out/log.txt", "a"); appendFile("out/log.txt", "b"); appendFile("out/new.txt", "c");`,
+			wantOutput: map[string]string{"out/log.txt": "ab", "out/new.txt": "c"},
+		},
+		{
+			name:   "removeFile",
+			read:   []string{"out"},
+			write:  []string{"out"},
+			source: `writeFile("out/tmp.txt", ""); removeFile("out/tmp.txt"); var result = exists("out/tmp.txt");`,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			interpreter := NewInterpreter()
+			for _, dir := range tt.read {
+				r.NoError(interpreter.Sandbox().AllowRead(dir))
+			}
+			for _, dir := range tt.write {
+				r.NoError(interpreter.Sandbox().AllowWrite(dir))
+			}
+			err := interpreter.Interpret(parseProgramForTest(t, tt.source))
+
+			if tt.wantErr != "" {
+				r.EqualError(err, tt.wantErr)
+				return
+			}
+			r.NoError(err)
+			if tt.wantOutput != nil {
+				for name, want := range tt.wantOutput {
+					got, err := os.ReadFile(name)
+					r.NoError(err)
+					r.Equal(want, string(got))
+				}
+				return
+			}
+			got, ok := interpreter.Global("result")
+			r.True(ok)
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestInterpreterReadFileChargesBeforeReading(t *testing.T) {
+	r := require.New(t)
+	t.Chdir(t.TempDir())
+	r.NoError(os.WriteFile("big.txt", make([]byte, 2<<20), 0o644))
+
+	interpreter := NewInterpreter()
+	r.NoError(interpreter.Sandbox().AllowRead("."))
+	interpreter.SetLimits(Limits{Memory: 1 << 20})
+	err := interpreter.Interpret(parseProgramForTest(t, `var s = readFile("big.txt");`))
+
+	r.EqualError(err, "Memory limit exceeded.\n[line 1]")
+	_, ok := interpreter.Global("s")
+	r.False(ok)
+}
+
+func TestSandboxAllowWantsDirectory(t *testing.T) {
+	r := require.New(t)
+	t.Chdir(t.TempDir())
+	r.NoError(os.WriteFile("file.txt", nil, 0o644))
+
+	var sandbox Sandbox
+	r.EqualError(sandbox.AllowRead("file.txt"), "file.txt is not a directory")
+	r.Error(sandbox.AllowWrite("missing"))
+}
+
+func TestNewFrameNamesClassCalls(t *testing.T) {
+	r := require.New(t)
+
+	interpreter, err := interpretProgramForTest(t, `
+class Plain {}
+class Point { init() {} }
+`)
+	r.NoError(err)
+	plain, _ := interpreter.Global("Plain")
+	point, _ := interpreter.Global("Point")
+
+	r.Equal("Plain()", newFrame(plain.(Callable), token.Token{}).String())
+	r.Equal("Point.init()", newFrame(point.(Callable), token.Token{}).String())
+}
+
+func TestInterpreterNativesChargeMemory(t *testing.T) {
+	r := require.New(t)
+
+	interpreter := NewInterpreter()
+	interpreter.SetLimits(Limits{Memory: 1 << 20})
+	err := interpreter.Interpret(parseProgramForTest(t, `var s = repeat("ab", 1000000);`))
+
+	r.EqualError(err, "Memory limit exceeded.\n[line 1]")
+	_, ok := interpreter.Global("s")
+	r.False(ok)
+}
@@ -0,0 +1,214 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// DefaultMaxDepth is the number of nested calls allowed when [Limits]
+// doesn't say otherwise, far below the depth at which the Go stack of the
+// interpreter would overflow.
+const DefaultMaxDepth = 10000
+
+// Limits bounds the work a program may do, so that programs that can't be
+// trusted can't run forever or crash the process.
+type Limits struct {
+	// Steps is the number of statements and calls a program may execute,
+	// unlimited if 0. It is checked whenever a loop starts an iteration
+	// or a function is called, which is how programs run for long, so a
+	// few straight-line statements may run past it.
+	Steps int
+	// MaxDepth is the number of nested calls allowed, DefaultMaxDepth if
+	// 0. Deeper calls fail with a "Stack overflow." runtime error.
+	MaxDepth int
+	// Memory is the approximate number of bytes a program may hold,
+	// unlimited if 0. See [Usage] for what is counted.
+	Memory int
+}
+
+// Depth returns the maximum call depth l allows.
+func (l Limits) Depth() int {
+	if l.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return l.MaxDepth
+}
+
+// Usage is the work done by a program run. Values, environments and VM
+// call frames are charged the sizes in memory.go when they are created,
+// and count as held until the program can no longer reach them. As values
+// aren't given back as soon as they are unreachable, but when the budget
+// next measures what the program holds, Peak may include some of them.
+type Usage struct {
+	// Steps is the number of steps taken, as counted against Limits.Steps.
+	Steps int
+	// Allocated is the number of bytes allocated.
+	Allocated int
+	// Peak is the largest number of bytes held at once, as counted against
+	// Limits.Memory.
+	Peak int
+}
+
+// minMeasured is the number of bytes a program may allocate before the
+// budget measures what it holds, so that programs holding little aren't
+// measured over and over.
+const minMeasured = 64 << 10
+
+// Budget counts the steps and memory of a running program against its
+// [Limits] and watches the context it runs under. It is shared by both
+// backends, so that they stop programs for the same reasons and with the
+// same errors.
+type Budget struct {
+	limits Limits
+	ctx    context.Context
+	// Closed when ctx is done, nil if it never is.
+	done <-chan struct{}
+	// Runs in progress, more than one while the host calls back into a
+	// program from a native function.
+	runs  int
+	steps int
+	// Bytes allocated by the program, and the largest number it held.
+	allocated, peak int
+	// Bytes held by the program: those it could reach when last measured,
+	// and those allocated since, less those known to be unreachable.
+	held int
+	// Bytes allocated since the last check, which the program may not be
+	// able to reach yet.
+	fresh int
+	// Bytes the program may hold before it is measured again.
+	next int
+	// Adds the values the running program can reach to a tracer.
+	roots func(t *Tracer)
+}
+
+// NewBudget returns a budget with the default limits, under a context
+// that is never done.
+func NewBudget() Budget {
+	return Budget{ctx: context.Background(), next: minMeasured}
+}
+
+// SetLimits changes the limits of the budget.
+func (b *Budget) SetLimits(l Limits) {
+	b.limits = l
+}
+
+// Limits returns the limits of the budget.
+func (b *Budget) Limits() Limits {
+	return b.limits
+}
+
+// Start watches ctx until the run it starts ends, and resets the usage of
+// the budget unless another run is in progress. Runs nested in another
+// one, started by the host from a native function, are charged to the
+// outer run, so that calling back into the program can't escape its
+// limits. Start returns a function ending the run.
+//
+// roots adds the values the program can reach to a tracer, to measure
+// the memory it holds. The values left by earlier runs are still held.
+func (b *Budget) Start(ctx context.Context, roots func(t *Tracer)) (end func()) {
+	prevCtx, prevDone := b.ctx, b.done
+	if b.runs == 0 {
+		b.steps, b.allocated, b.fresh = 0, 0, 0
+		b.peak = b.held
+		b.roots = roots
+	}
+	b.runs++
+	b.ctx, b.done = ctx, ctx.Done()
+	return func() {
+		b.runs--
+		b.ctx, b.done = prevCtx, prevDone
+	}
+}
+
+// Usage returns the work done since the last call to Start.
+func (b *Budget) Usage() Usage {
+	return Usage{Steps: b.steps, Allocated: b.allocated, Peak: b.peak}
+}
+
+// Step counts one step.
+func (b *Budget) Step() {
+	b.steps++
+}
+
+// Alloc counts n bytes allocated, held until the program can no longer
+// reach them.
+func (b *Budget) Alloc(n int) {
+	b.allocated += n
+	b.held += n
+	b.fresh += n
+	b.peak = max(b.peak, b.held)
+}
+
+// Free counts n bytes no longer held, such as those of an environment
+// going out of scope.
+func (b *Budget) Free(n int) {
+	b.held = max(b.held-n, 0)
+}
+
+// Allocate counts n bytes about to be allocated by a native function, and
+// returns an error, reported at the call, if the program can't hold them.
+func (b *Budget) Allocate(n int) error {
+	b.Alloc(n)
+	if b.exhausted() {
+		return fmt.Errorf("Memory limit exceeded.")
+	}
+	return nil
+}
+
+// exhausted reports whether the program holds more memory than it may.
+// The memory held is measured first if the program went past its limit,
+// or allocated enough since it was last measured.
+func (b *Budget) exhausted() bool {
+	over := b.limits.Memory > 0 && b.held > b.limits.Memory
+	if over || b.held >= b.next {
+		b.measure()
+		over = b.limits.Memory > 0 && b.held > b.limits.Memory
+	}
+	b.fresh = 0
+	return over
+}
+
+// measure finds out the memory held by the program: what it can reach,
+// and what it allocated since the last check, which may not be reachable
+// yet as the values haven't been stored anywhere.
+func (b *Budget) measure() {
+	if b.roots == nil {
+		return
+	}
+	t := newTracer()
+	b.roots(t)
+	live := t.run()
+	b.held = live + b.fresh
+	b.peak = max(b.peak, b.held)
+	b.next = max(2*live, minMeasured)
+}
+
+// Check returns a runtime error at tok if the program used up its steps
+// or its memory, or its context is done.
+func (b *Budget) Check(tok token.Token) error {
+	if b.limits.Steps > 0 && b.steps > b.limits.Steps {
+		return errors.RuntimeErrorAtToken(tok, "Step limit exceeded.")
+	}
+	if b.exhausted() {
+		return errors.RuntimeErrorAtToken(tok, "Memory limit exceeded.")
+	}
+	select {
+	case <-b.done:
+		err := b.ctx.Err()
+		return errors.RuntimeErrorAtToken(tok, fmt.Sprintf("Interrupted: %s.", err)).WithCause(err)
+	default:
+		return nil
+	}
+}
+
+// CheckDepth returns a "Stack overflow." runtime error at tok if depth
+// calls are already in progress.
+func (b *Budget) CheckDepth(tok token.Token, depth int) error {
+	if depth >= b.limits.Depth() {
+		return errors.RuntimeErrorAtToken(tok, "Stack overflow.")
+	}
+	return nil
+}
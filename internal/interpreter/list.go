@@ -0,0 +1,148 @@
+package interpreter
+
+import (
+	"errors"
+	"math"
+
+	internalErrors "github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// LoxList is a growable sequence of values.
+// Lists are mutable and shared by reference, so they are always handled through a pointer.
+type LoxList struct {
+	elements []Object
+}
+
+func NewLoxList(elements []Object) *LoxList {
+	return &LoxList{elements}
+}
+
+// Elements returns the values held by the list.
+// The slice is shared with the list and must not be modified.
+func (l *LoxList) Elements() []Object {
+	return l.elements
+}
+
+// Get returns the method called name bound to the list.
+func (l *LoxList) Get(name token.Token) (Object, error) {
+	switch name.Lexeme {
+	case "length":
+		return l.method(name.Lexeme, ExactArity(0), nil, func(_ Host, args []Object) (Object, error) {
+			return float64(len(l.elements)), nil
+		}), nil
+	case "push":
+		return l.method(name.Lexeme, ExactArity(1), nil, func(host Host, args []Object) (Object, error) {
+			if err := host.Allocate(BindingSize); err != nil {
+				return nil, err
+			}
+			l.elements = append(l.elements, args[0])
+			return nil, nil
+		}), nil
+	case "pop":
+		return l.method(name.Lexeme, ExactArity(0), nil, func(_ Host, args []Object) (Object, error) {
+			if len(l.elements) == 0 {
+				return nil, errors.New("Can't pop from an empty list.")
+			}
+			last := l.elements[len(l.elements)-1]
+			l.elements = l.elements[:len(l.elements)-1]
+			return last, nil
+		}), nil
+	case "insert":
+		return l.method(name.Lexeme, ExactArity(2), []Kind{NumberKind, AnyKind}, func(host Host, args []Object) (Object, error) {
+			// inserting right after the last element appends
+			i, err := listIndex(args[0], len(l.elements)+1)
+			if err != nil {
+				return nil, err
+			}
+			if err := host.Allocate(BindingSize); err != nil {
+				return nil, err
+			}
+			l.elements = append(l.elements, nil)
+			copy(l.elements[i+1:], l.elements[i:])
+			l.elements[i] = args[1]
+			return nil, nil
+		}), nil
+	case "remove":
+		return l.method(name.Lexeme, ExactArity(1), []Kind{NumberKind}, func(_ Host, args []Object) (Object, error) {
+			i, err := listIndex(args[0], len(l.elements))
+			if err != nil {
+				return nil, err
+			}
+			removed := l.elements[i]
+			l.elements = append(l.elements[:i], l.elements[i+1:]...)
+			return removed, nil
+		}), nil
+	case "slice":
+		return l.method(name.Lexeme, RangeArity(0, 2), []Kind{NumberKind}, func(host Host, args []Object) (Object, error) {
+			start, end := 0, len(l.elements)
+			var err error
+			if len(args) > 0 {
+				if start, err = listIndex(args[0], len(l.elements)+1); err != nil {
+					return nil, err
+				}
+			}
+			if len(args) > 1 {
+				if end, err = listIndex(args[1], len(l.elements)+1); err != nil {
+					return nil, err
+				}
+			}
+			if start > end {
+				return nil, errors.New("Slice start must not be after its end.")
+			}
+			if err := host.Allocate(ListBytes(end - start)); err != nil {
+				return nil, err
+			}
+			elements := make([]Object, end-start)
+			copy(elements, l.elements[start:end])
+			return NewLoxList(elements), nil
+		}), nil
+	}
+
+	return nil, internalErrors.RuntimeErrorAtToken(
+		name,
+		"Undefined property '"+name.Lexeme+"'.",
+	)
+}
+
+// method returns fn as a native function, a method of the list called name.
+func (l *LoxList) method(name string, arity Arity, params []Kind, fn NativeFn) *NativeFunction {
+	method := NewNativeFunction(name, arity, params, fn)
+	method.receiver = l
+	return method
+}
+
+// At returns the element at index, reporting errors at bracket.
+func (l *LoxList) At(bracket token.Token, index Object) (Object, error) {
+	i, err := listIndex(index, len(l.elements))
+	if err != nil {
+		return nil, internalErrors.RuntimeErrorAtToken(bracket, err.Error())
+	}
+	return l.elements[i], nil
+}
+
+// SetAt replaces the element at index, reporting errors at bracket.
+func (l *LoxList) SetAt(bracket token.Token, index Object, value Object) error {
+	i, err := listIndex(index, len(l.elements))
+	if err != nil {
+		return internalErrors.RuntimeErrorAtToken(bracket, err.Error())
+	}
+	l.elements[i] = value
+	return nil
+}
+
+func (l *LoxList) String() string {
+	return repr(l, make(map[Object]bool))
+}
+
+// listIndex converts index into a position in a list of the given length.
+func listIndex(index Object, length int) (int, error) {
+	n, ok := index.(float64)
+	if !ok || n != math.Trunc(n) {
+		return 0, errors.New("List index must be an integer.")
+	}
+	if n < 0 || n >= float64(length) {
+		return 0, errors.New("List index out of range.")
+	}
+	return int(n), nil
+}
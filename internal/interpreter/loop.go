@@ -0,0 +1,17 @@
+package interpreter
+
+// BreakLoop unwinds the statements of a loop body up to the innermost
+// enclosing loop, which then stops.
+type BreakLoop struct{}
+
+func (BreakLoop) Error() string {
+	return "break"
+}
+
+// ContinueLoop unwinds the statements of a loop body up to the innermost
+// enclosing loop, which then moves on to its next iteration.
+type ContinueLoop struct{}
+
+func (ContinueLoop) Error() string {
+	return "continue"
+}
@@ -0,0 +1,161 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+
+	internalErrors "github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// LoxMap associates keys with values and remembers the order in which keys
+// were first added, so iterating over it is deterministic.
+// Keys are strings, numbers, booleans or nil.
+// Maps are mutable and shared by reference, so they are always handled through a pointer.
+type LoxMap struct {
+	// Keys in insertion order.
+	keys    []Object
+	entries map[Object]Object
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{
+		keys:    []Object{},
+		entries: make(map[Object]Object),
+	}
+}
+
+// Keys returns the keys of the map in insertion order.
+// The slice is shared with the map and must not be modified.
+func (m *LoxMap) Keys() []Object {
+	return m.keys
+}
+
+// Lookup returns the value stored under key, if any.
+func (m *LoxMap) Lookup(key Object) (Object, bool) {
+	value, ok := m.entries[key]
+	return value, ok
+}
+
+// Put stores value under key, which must be a valid key.
+// A key that is already present keeps its position.
+func (m *LoxMap) Put(key Object, value Object) {
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+}
+
+// Delete removes key from the map and reports whether it was present.
+func (m *LoxMap) Delete(key Object) bool {
+	if _, ok := m.entries[key]; !ok {
+		return false
+	}
+	delete(m.entries, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Get returns the method called name bound to the map.
+func (m *LoxMap) Get(name token.Token) (Object, error) {
+	switch name.Lexeme {
+	case "size":
+		return m.method(name.Lexeme, ExactArity(0), func(_ Host, args []Object) (Object, error) {
+			return float64(len(m.keys)), nil
+		}), nil
+	case "keys":
+		return m.method(name.Lexeme, ExactArity(0), func(host Host, args []Object) (Object, error) {
+			if err := host.Allocate(ListBytes(len(m.keys))); err != nil {
+				return nil, err
+			}
+			keys := make([]Object, len(m.keys))
+			copy(keys, m.keys)
+			return NewLoxList(keys), nil
+		}), nil
+	case "values":
+		return m.method(name.Lexeme, ExactArity(0), func(host Host, args []Object) (Object, error) {
+			if err := host.Allocate(ListBytes(len(m.keys))); err != nil {
+				return nil, err
+			}
+			values := make([]Object, len(m.keys))
+			for i, k := range m.keys {
+				values[i] = m.entries[k]
+			}
+			return NewLoxList(values), nil
+		}), nil
+	case "has":
+		return m.method(name.Lexeme, ExactArity(1), func(_ Host, args []Object) (Object, error) {
+			if err := checkMapKey(args[0]); err != nil {
+				return nil, err
+			}
+			_, ok := m.entries[args[0]]
+			return ok, nil
+		}), nil
+	case "delete":
+		return m.method(name.Lexeme, ExactArity(1), func(_ Host, args []Object) (Object, error) {
+			if err := checkMapKey(args[0]); err != nil {
+				return nil, err
+			}
+			return m.Delete(args[0]), nil
+		}), nil
+	}
+
+	return nil, internalErrors.RuntimeErrorAtToken(
+		name,
+		"Undefined property '"+name.Lexeme+"'.",
+	)
+}
+
+// method returns fn as a native function, a method of the map called name.
+func (m *LoxMap) method(name string, arity Arity, fn NativeFn) *NativeFunction {
+	method := NewNativeFunction(name, arity, nil, fn)
+	method.receiver = m
+	return method
+}
+
+// At returns the value stored under key, reporting errors at bracket.
+func (m *LoxMap) At(bracket token.Token, key Object) (Object, error) {
+	if err := checkMapKey(key); err != nil {
+		return nil, internalErrors.RuntimeErrorAtToken(bracket, err.Error())
+	}
+	value, ok := m.entries[key]
+	if !ok {
+		return nil, internalErrors.RuntimeErrorAtToken(
+			bracket,
+			fmt.Sprintf("Undefined key %s.", repr(key, nil)),
+		)
+	}
+	return value, nil
+}
+
+// SetAt stores value under key, reporting errors at bracket.
+func (m *LoxMap) SetAt(bracket token.Token, key Object, value Object) error {
+	if err := checkMapKey(key); err != nil {
+		return internalErrors.RuntimeErrorAtToken(bracket, err.Error())
+	}
+	m.Put(key, value)
+	return nil
+}
+
+func (m *LoxMap) String() string {
+	return repr(m, make(map[Object]bool))
+}
+
+// checkMapKey reports an error unless key can be used as a map key.
+func checkMapKey(key Object) error {
+	switch k := key.(type) {
+	case nil, string, bool:
+		return nil
+	case float64:
+		// NaN is not equal to itself, so it could never be looked up
+		if k == k {
+			return nil
+		}
+	}
+	return errors.New("Map key must be a string, number, boolean or nil.")
+}
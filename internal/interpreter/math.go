@@ -0,0 +1,118 @@
+package interpreter
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+)
+
+// Constants returns the values other than functions every program starts
+// with.
+func Constants() map[string]Object {
+	return map[string]Object{
+		"PI": math.Pi,
+		"E":  math.E,
+	}
+}
+
+// mathNatives returns the native functions working on numbers.
+//
+// The random numbers come from a generator of their own, seeded randomly
+// until a program calls seed, so every call to mathNatives returns natives
+// with a separate sequence.
+func mathNatives() []*NativeFunction {
+	source := rand.NewPCG(rand.Uint64(), rand.Uint64())
+	rng := rand.New(source)
+
+	return []*NativeFunction{
+		NewNativeFunction("floor", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Floor)),
+		NewNativeFunction("ceil", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Ceil)),
+		NewNativeFunction("round", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Round)),
+		NewNativeFunction("abs", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Abs)),
+		NewNativeFunction("sqrt", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Sqrt)),
+		NewNativeFunction("pow", ExactArity(2), []Kind{NumberKind}, pow),
+		NewNativeFunction("min", VariadicArity(1), []Kind{NumberKind}, minimum),
+		NewNativeFunction("max", VariadicArity(1), []Kind{NumberKind}, maximum),
+		NewNativeFunction("sin", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Sin)),
+		NewNativeFunction("cos", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Cos)),
+		NewNativeFunction("tan", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Tan)),
+		NewNativeFunction("asin", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Asin)),
+		NewNativeFunction("acos", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Acos)),
+		NewNativeFunction("atan", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Atan)),
+		NewNativeFunction("atan2", ExactArity(2), []Kind{NumberKind}, atan2),
+		NewNativeFunction("log", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Log)),
+		NewNativeFunction("exp", ExactArity(1), []Kind{NumberKind}, mapNumber(math.Exp)),
+		NewNativeFunction("random", ExactArity(0), nil, func(_ Host, _ []Object) (Object, error) {
+			return rng.Float64(), nil
+		}),
+		NewNativeFunction("randomInt", ExactArity(2), []Kind{NumberKind}, func(_ Host, args []Object) (Object, error) {
+			return randomInt(rng, args)
+		}),
+		NewNativeFunction("seed", ExactArity(1), []Kind{NumberKind}, func(_ Host, args []Object) (Object, error) {
+			n, err := integer(args[0], "Seed")
+			if err != nil {
+				return nil, err
+			}
+			source.Seed(uint64(n), 0)
+			return nil, nil
+		}),
+	}
+}
+
+// mapNumber makes a native function returning fn applied to its argument.
+func mapNumber(fn func(float64) float64) NativeFn {
+	return func(_ Host, args []Object) (Object, error) {
+		return fn(args[0].(float64)), nil
+	}
+}
+
+func pow(_ Host, args []Object) (Object, error) {
+	return math.Pow(args[0].(float64), args[1].(float64)), nil
+}
+
+// atan2 returns the angle of the point (x, y), called as atan2(y, x).
+func atan2(_ Host, args []Object) (Object, error) {
+	return math.Atan2(args[0].(float64), args[1].(float64)), nil
+}
+
+func minimum(_ Host, args []Object) (Object, error) {
+	result := args[0].(float64)
+	for _, arg := range args[1:] {
+		result = min(result, arg.(float64))
+	}
+	return result, nil
+}
+
+func maximum(_ Host, args []Object) (Object, error) {
+	result := args[0].(float64)
+	for _, arg := range args[1:] {
+		result = max(result, arg.(float64))
+	}
+	return result, nil
+}
+
+// randomInt returns a random integer from lo up to and including hi.
+func randomInt(rng *rand.Rand, args []Object) (Object, error) {
+	lo, err := integer(args[0], "Lower bound")
+	if err != nil {
+		return nil, err
+	}
+	hi, err := integer(args[1], "Upper bound")
+	if err != nil {
+		return nil, err
+	}
+	if lo > hi {
+		return nil, errors.New("Lower bound must not be greater than upper bound.")
+	}
+	return float64(lo + rng.Int64N(hi-lo+1)), nil
+}
+
+// integer converts n into an integer, returning an error naming it what
+// if it has a fractional part or doesn't fit.
+func integer(n Object, what string) (int64, error) {
+	f := n.(float64)
+	if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return 0, errors.New(what + " must be an integer.")
+	}
+	return int64(f), nil
+}
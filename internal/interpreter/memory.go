@@ -0,0 +1,190 @@
+package interpreter
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Approximate sizes in bytes of what programs allocate, as charged to
+// their [Budget]. They are rough estimates of what the Go runtime uses,
+// headers included, shared by both backends.
+const (
+	// StringSize is the size of a string on top of its content.
+	StringSize = 16
+	// BindingSize is the size of a variable in an environment, a field of
+	// an instance, an entry of a map or an element of a list.
+	BindingSize = 32
+	// EnvironmentSize is the size of an environment, or of a call frame on
+	// the VM, without its variables.
+	EnvironmentSize = 48
+	// InstanceSize is the size of an instance without its fields.
+	InstanceSize = 64
+	// ClosureSize is the size of a function value.
+	ClosureSize = 64
+	// ListSize is the size of a list without its elements.
+	ListSize = 32
+	// MapSize is the size of a map without its entries.
+	MapSize = 64
+)
+
+// StringBytes returns the size of s.
+func StringBytes(s string) int {
+	return StringSize + len(s)
+}
+
+// ListBytes returns the size of a list of n elements.
+func ListBytes(n int) int {
+	return ListSize + n*BindingSize
+}
+
+// environmentBytes returns the size of env with the variables it holds.
+func environmentBytes(env Environment) int {
+	return EnvironmentSize + len(env.values)*BindingSize
+}
+
+// Tracer measures the memory held by a program by visiting the values it
+// can reach, adding up their sizes. Values reached more than once are
+// counted once.
+type Tracer struct {
+	seen map[any]bool
+	// Values and environments reached but not visited yet.
+	pending []any
+	bytes   int
+}
+
+// Traceable is implemented by the values of a backend holding other values.
+type Traceable interface {
+	// Trace adds the size of the value to t, along with what it holds.
+	Trace(t *Tracer)
+}
+
+func newTracer() *Tracer {
+	return &Tracer{seen: make(map[any]bool)}
+}
+
+// Add counts size bytes for the value identified by key, unless it was
+// already counted, and reports whether it was new to t.
+func (t *Tracer) Add(key any, size int) bool {
+	if t.seen[key] {
+		return false
+	}
+	t.seen[key] = true
+	t.bytes += size
+	return true
+}
+
+// Object adds v to t, along with what it holds.
+func (t *Tracer) Object(v Object) {
+	t.pending = append(t.pending, v)
+}
+
+// Environment adds env to t, along with its variables and the
+// environments enclosing it.
+func (t *Tracer) Environment(env Environment) {
+	t.pending = append(t.pending, env)
+}
+
+// stringKey identifies the content of a string, which copies of it share.
+type stringKey struct {
+	data *byte
+	len  int
+}
+
+// run visits the values added to t, and those they hold, and returns the
+// number of bytes they take. Values are visited from a list rather than
+// recursively, so that long chains of them don't overflow the stack.
+func (t *Tracer) run() int {
+	for len(t.pending) > 0 {
+		v := t.pending[len(t.pending)-1]
+		t.pending = t.pending[:len(t.pending)-1]
+
+		switch v := v.(type) {
+		case string:
+			t.Add(stringKey{unsafe.StringData(v), len(v)}, StringBytes(v))
+		case Environment:
+			// copies of an environment share its variables
+			if !t.Add(reflect.ValueOf(v.values).Pointer(), environmentBytes(v)) {
+				continue
+			}
+			for _, value := range v.values {
+				t.Object(value)
+			}
+			if v.enclosing != nil {
+				t.Environment(*v.enclosing)
+			}
+		case *LoxFunction:
+			if t.Add(v, ClosureSize) {
+				t.Environment(v.closure)
+				t.Environment(v.globals)
+			}
+		case *LoxClass:
+			if t.Add(v, 0) {
+				for _, method := range v.methods {
+					t.Object(method)
+				}
+				if v.Superclass != nil {
+					t.Object(v.Superclass)
+				}
+			}
+		case *LoxInstance:
+			if t.Add(v, InstanceSize+len(v.fields)*BindingSize) {
+				t.Object(v.class)
+				for _, value := range v.fields {
+					t.Object(value)
+				}
+			}
+		case *LoxList:
+			if t.Add(v, ListBytes(len(v.elements))) {
+				for _, element := range v.elements {
+					t.Object(element)
+				}
+			}
+		case *LoxMap:
+			if t.Add(v, MapSize+len(v.keys)*BindingSize) {
+				for key, value := range v.entries {
+					t.Object(key)
+					t.Object(value)
+				}
+			}
+		case *LoxModule:
+			if t.Add(v, 0) {
+				t.Environment(v.globals)
+			}
+		case *NativeFunction:
+			if v.receiver != nil {
+				t.Object(v.receiver)
+			}
+		case Traceable:
+			v.Trace(t)
+		}
+	}
+	return t.bytes
+}
+
+// roots adds the values the program can reach to t: those of the
+// environments in use or set aside, of the modules and of the expressions
+// being evaluated.
+func (i *Interpreter) roots(t *Tracer) {
+	t.Environment(*i.builtins)
+	t.Environment(i.globals)
+	t.Environment(i.environment)
+	for _, env := range i.saved {
+		t.Environment(env)
+	}
+	for _, module := range i.imports {
+		t.Object(module)
+	}
+	for _, value := range i.temps {
+		t.Object(value)
+	}
+}
+
+// Allocate implements [Host].
+func (i *Interpreter) Allocate(n int) error {
+	return i.budget.Allocate(n)
+}
+
+// Usage returns the work done by the last program interpreted.
+func (i *Interpreter) Usage() Usage {
+	return i.budget.Usage()
+}
@@ -0,0 +1,75 @@
+package interpreter
+
+import (
+	"github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// LoxModule is a Lox file loaded by an import statement. Its top-level
+// declarations live in a namespace of their own and are accessed as
+// properties of the module.
+type LoxModule struct {
+	name    string
+	program []parser.Stmt
+	// The module's top-level environment, enclosed by the builtins.
+	globals Environment
+	// Whether the program has started running. A module runs at most once,
+	// however many times it is imported.
+	loaded bool
+}
+
+// NewModule creates a module named name, after its file, that runs
+// program the first time it is imported. program must already be resolved.
+func (i *Interpreter) NewModule(name string, program []parser.Stmt) *LoxModule {
+	return &LoxModule{
+		name:    name,
+		program: program,
+		globals: NewEnclosedEnvinronment(i.builtins),
+	}
+}
+
+// Link binds an import statement to the module it loads, much like
+// Resolve binds a variable to its scope.
+func (i *Interpreter) Link(stmt parser.Import, module *LoxModule) {
+	i.imports[stmt.Id()] = module
+}
+
+// Get returns the top-level declaration of the module called name.
+func (m *LoxModule) Get(name token.Token) (Object, error) {
+	if value, ok := m.globals.values[name.Lexeme]; ok {
+		return value, nil
+	}
+
+	return nil, errors.RuntimeErrorAtToken(
+		name,
+		"Undefined property '"+name.Lexeme+"'.",
+	)
+}
+
+func (m *LoxModule) String() string {
+	return "<module " + m.name + ">"
+}
+
+// load runs the module's program in its own environment, unless it has already run.
+func (i *Interpreter) load(module *LoxModule) error {
+	if module.loaded {
+		return nil
+	}
+	module.loaded = true
+
+	enclosingGlobals, enclosingEnvironment := i.globals, i.environment
+	i.globals, i.environment = module.globals, module.globals
+	i.saved = append(i.saved, enclosingGlobals, enclosingEnvironment)
+	defer func() {
+		i.saved = i.saved[:len(i.saved)-2]
+		i.globals, i.environment = enclosingGlobals, enclosingEnvironment
+	}()
+
+	for _, stmt := range module.program {
+		if _, err := i.execute(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package interpreter
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringNatives returns the native functions working on strings.
+//
+// Strings are indexed by character, a Unicode code point, like the source
+// code the scanner reads, rather than by byte.
+func stringNatives() []*NativeFunction {
+	return []*NativeFunction{
+		NewNativeFunction("len", ExactArity(1), []Kind{StringKind}, stringLength),
+		NewNativeFunction("charAt", ExactArity(2), []Kind{StringKind, NumberKind}, charAt),
+		NewNativeFunction("substr", RangeArity(2, 3), []Kind{StringKind, NumberKind}, substr),
+		NewNativeFunction("indexOf", ExactArity(2), []Kind{StringKind}, indexOf),
+		NewNativeFunction("startsWith", ExactArity(2), []Kind{StringKind}, startsWith),
+		NewNativeFunction("endsWith", ExactArity(2), []Kind{StringKind}, endsWith),
+		NewNativeFunction("split", ExactArity(2), []Kind{StringKind}, split),
+		NewNativeFunction("join", ExactArity(2), []Kind{ListKind, StringKind}, join),
+		NewNativeFunction("upper", ExactArity(1), []Kind{StringKind}, mapString(strings.ToUpper)),
+		NewNativeFunction("lower", ExactArity(1), []Kind{StringKind}, mapString(strings.ToLower)),
+		NewNativeFunction("trim", ExactArity(1), []Kind{StringKind}, mapString(strings.TrimSpace)),
+		NewNativeFunction("replace", ExactArity(3), []Kind{StringKind}, replace),
+		NewNativeFunction("repeat", ExactArity(2), []Kind{StringKind, NumberKind}, repeat),
+	}
+}
+
+// newString charges s to the memory budget of host and returns it.
+func newString(host Host, s string) (Object, error) {
+	if err := host.Allocate(StringBytes(s)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func stringLength(_ Host, args []Object) (Object, error) {
+	return float64(utf8.RuneCountInString(args[0].(string))), nil
+}
+
+func charAt(host Host, args []Object) (Object, error) {
+	chars := []rune(args[0].(string))
+	i, err := stringIndex(args[1], len(chars))
+	if err != nil {
+		return nil, err
+	}
+	return newString(host, string(chars[i]))
+}
+
+// substr returns the characters of a string from start up to, but not
+// including, end, which defaults to the length of the string.
+func substr(host Host, args []Object) (Object, error) {
+	chars := []rune(args[0].(string))
+	// the end of the string is a valid bound
+	start, err := stringIndex(args[1], len(chars)+1)
+	if err != nil {
+		return nil, err
+	}
+	end := len(chars)
+	if len(args) > 2 {
+		if end, err = stringIndex(args[2], len(chars)+1); err != nil {
+			return nil, err
+		}
+	}
+	if start > end {
+		return nil, errors.New("Substring start must not be after its end.")
+	}
+	return newString(host, string(chars[start:end]))
+}
+
+// indexOf returns the index of the first occurrence of a substring, or -1.
+func indexOf(_ Host, args []Object) (Object, error) {
+	s := args[0].(string)
+	i := strings.Index(s, args[1].(string))
+	if i < 0 {
+		return float64(-1), nil
+	}
+	return float64(utf8.RuneCountInString(s[:i])), nil
+}
+
+func startsWith(_ Host, args []Object) (Object, error) {
+	return strings.HasPrefix(args[0].(string), args[1].(string)), nil
+}
+
+func endsWith(_ Host, args []Object) (Object, error) {
+	return strings.HasSuffix(args[0].(string), args[1].(string)), nil
+}
+
+// split returns the list of the parts of a string around a separator,
+// or of its characters if the separator is empty.
+func split(host Host, args []Object) (Object, error) {
+	parts := strings.Split(args[0].(string), args[1].(string))
+	size := ListBytes(len(parts))
+	for _, part := range parts {
+		size += StringBytes(part)
+	}
+	if err := host.Allocate(size); err != nil {
+		return nil, err
+	}
+
+	elements := make([]Object, len(parts))
+	for i, part := range parts {
+		elements[i] = part
+	}
+	return NewLoxList(elements), nil
+}
+
+// join concatenates the elements of a list, printed as by print, with a
+// separator between them.
+func join(host Host, args []Object) (Object, error) {
+	elements := args[0].(*LoxList).Elements()
+	parts := make([]string, len(elements))
+	for i, element := range elements {
+		parts[i] = Stringify(element)
+	}
+	return newString(host, strings.Join(parts, args[1].(string)))
+}
+
+// mapString makes a native function returning fn applied to its argument.
+func mapString(fn func(string) string) NativeFn {
+	return func(host Host, args []Object) (Object, error) {
+		return newString(host, fn(args[0].(string)))
+	}
+}
+
+// replace replaces every occurrence of a substring.
+func replace(host Host, args []Object) (Object, error) {
+	return newString(host, strings.ReplaceAll(args[0].(string), args[1].(string), args[2].(string)))
+}
+
+func repeat(host Host, args []Object) (Object, error) {
+	s, n := args[0].(string), args[1].(float64)
+	if n < 0 || n != math.Trunc(n) {
+		return nil, errors.New("Repeat count must be a non-negative integer.")
+	}
+	if n > 0 && float64(len(s))*n > math.MaxInt32 {
+		return nil, errors.New("Repeated string is too long.")
+	}
+	// charged before the string is built, which may exceed the budget
+	if err := host.Allocate(StringSize + len(s)*int(n)); err != nil {
+		return nil, err
+	}
+	return strings.Repeat(s, int(n)), nil
+}
+
+// stringIndex converts index into the position of a character in a string
+// of the given length.
+func stringIndex(index Object, length int) (int, error) {
+	n := index.(float64)
+	if n != math.Trunc(n) {
+		return 0, errors.New("String index must be an integer.")
+	}
+	if n < 0 || n >= float64(length) {
+		return 0, errors.New("String index out of range.")
+	}
+	return int(n), nil
+}
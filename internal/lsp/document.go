@@ -0,0 +1,274 @@
+package lsp
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/resolver"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// document is an open text document and what is known about its program.
+type document struct {
+	uri     string
+	version int
+	text    string
+	// Byte offsets of the start of each line.
+	lines []int
+
+	diagnostics []Diagnostic
+	// The statements that parsed, even when others didn't.
+	prog  []parser.Stmt
+	spans map[parser.NodeID]parser.Span
+	// The symbols of the program, nil when it doesn't resolve.
+	symbols []*resolver.Symbol
+}
+
+// newDocument analyzes text, the content of the document at uri.
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, lines: []int{0}}
+	for n, char := range text {
+		if char == '\n' {
+			d.lines = append(d.lines, n+1)
+		}
+	}
+	d.analyze()
+	return d
+}
+
+// filename returns the path of a file URI, or the URI itself.
+func filename(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return uri
+}
+
+// analyze scans, parses and resolves the document. Syntax errors don't
+// stop the analysis of the statements around them, but resolver errors
+// and warnings are only reported for programs without syntax errors.
+func (d *document) analyze() {
+	sc := scanner.NewFileScanner(filename(d.uri), d.text)
+	tokens, scanErr := sc.ScanTokens()
+	d.report(scanErr, SeverityError)
+
+	pa := parser.NewParser(tokens)
+	prog, parseErr := pa.Parse()
+	d.report(parseErr, SeverityError)
+	d.prog = prog
+	d.spans = pa.Spans()
+
+	r := resolver.NewResolver(unresolved{})
+	analysis, err := r.Analyze(prog)
+	if err == nil {
+		d.symbols = analysis.Symbols
+	}
+	if scanErr != nil || parseErr != nil {
+		return
+	}
+	d.report(err, SeverityError)
+	for _, w := range analysis.Warnings {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.tokenRange(w.Token),
+			Severity: SeverityWarning,
+			Code:     w.Rule,
+			Source:   "golox",
+			Message:  w.Message,
+		})
+	}
+}
+
+// unresolved is a [resolver.Interpreter] for programs that are only analyzed.
+type unresolved struct{}
+
+func (unresolved) Resolve(expr parser.Expr, depth int) {}
+
+// located is implemented by errors that know their source position.
+type located interface {
+	Position() token.Position
+	Message() string
+}
+
+// report adds a diagnostic for err, or for each of the errors it wraps.
+func (d *document) report(err error, severity DiagnosticSeverity) {
+	if err == nil {
+		return
+	}
+	errs := []error{err}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = multi.Unwrap()
+	}
+
+	for _, e := range errs {
+		if e == nil {
+			continue
+		}
+		diagnostic := Diagnostic{Severity: severity, Source: "golox", Message: e.Error()}
+		if l, ok := e.(located); ok {
+			diagnostic.Range = d.positionRange(l.Position())
+			diagnostic.Message = l.Message()
+		}
+		d.diagnostics = append(d.diagnostics, diagnostic)
+	}
+}
+
+// position converts a byte offset into the text to an LSP position.
+func (d *document) position(offset int) Position {
+	offset = min(max(offset, 0), len(d.text))
+	line := sort.Search(len(d.lines), func(n int) bool { return d.lines[n] > offset }) - 1
+	character := 0
+	for _, char := range d.text[d.lines[line]:offset] {
+		character += utf16Len(char)
+	}
+	return Position{line, character}
+}
+
+// offset converts an LSP position to a byte offset into the text.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		char, size := utf8.DecodeRuneInString(d.text[offset:])
+		if char == '\n' {
+			break
+		}
+		character += utf16Len(char)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Len(char rune) int {
+	if char >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// positionRange returns the range of a span of source. Spans that only
+// know their line cover the whole line.
+func (d *document) positionRange(pos token.Position) Range {
+	if pos.Column == 0 {
+		line := min(max(pos.Line-1, 0), len(d.lines)-1)
+		end := len(d.text)
+		if line+1 < len(d.lines) {
+			end = d.lines[line+1] - 1
+		}
+		return Range{Position{line, 0}, d.position(end)}
+	}
+	return Range{d.position(pos.Start), d.position(pos.End)}
+}
+
+func (d *document) tokenRange(tok token.Token) Range {
+	return d.positionRange(tok.Position)
+}
+
+// symbolAt returns the symbol declared or referred to by the identifier
+// under offset, along with the token of that identifier.
+func (d *document) symbolAt(offset int) (*resolver.Symbol, token.Token, bool) {
+	contains := func(tok token.Token) bool {
+		return tok.Start <= offset && offset <= tok.End
+	}
+	for _, symbol := range d.symbols {
+		if contains(symbol.Name) {
+			return symbol, symbol.Name, true
+		}
+		for _, ref := range symbol.References {
+			if contains(ref) {
+				return symbol, ref, true
+			}
+		}
+	}
+	return nil, token.Token{}, false
+}
+
+// hover describes a symbol for a person reading the code.
+func hover(symbol *resolver.Symbol) string {
+	scope := "local"
+	if symbol.Global {
+		scope = "global"
+	}
+
+	var b strings.Builder
+	switch symbol.Kind {
+	case resolver.ParameterSymbol:
+		b.WriteString("(parameter) ")
+	case resolver.ImportSymbol:
+		b.WriteString("(module) ")
+	default:
+		b.WriteString("(" + scope + " " + symbol.Kind.String() + ") ")
+	}
+	b.WriteString(symbol.Name.Lexeme)
+
+	if symbol.Arity >= 0 {
+		b.WriteString(", takes " + plural(symbol.Arity, "argument"))
+	}
+	return b.String()
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// documentSymbols returns the classes, methods and functions declared
+// by stmts, with the ones declared inside each as its children.
+func (d *document) documentSymbols(stmts []parser.Stmt) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, stmt := range stmts {
+		symbols = append(symbols, d.stmtSymbols(stmt)...)
+	}
+	return symbols
+}
+
+func (d *document) stmtSymbols(stmt parser.Stmt) []DocumentSymbol {
+	switch s := stmt.(type) {
+	case parser.Class:
+		class := d.declaration(s, s.Name, SymbolClass)
+		for _, method := range s.Methods {
+			kind := SymbolMethod
+			if method.Name.Lexeme == "init" {
+				kind = SymbolConstructor
+			}
+			symbol := d.declaration(method, method.Name, kind)
+			symbol.Children = d.documentSymbols(method.Body)
+			class.Children = append(class.Children, symbol)
+		}
+		return []DocumentSymbol{class}
+	case parser.Function:
+		function := d.declaration(s, s.Name, SymbolFunction)
+		function.Children = d.documentSymbols(s.Body)
+		return []DocumentSymbol{function}
+	case parser.Block:
+		return d.documentSymbols(s.Stmts)
+	case parser.If:
+		return d.documentSymbols([]parser.Stmt{s.ThenBranch, s.ElseBranch})
+	case parser.While:
+		return d.stmtSymbols(s.Body)
+	case parser.For:
+		return d.stmtSymbols(s.Body)
+	}
+	return nil
+}
+
+// declaration returns the symbol of the declaration stmt called name.
+func (d *document) declaration(stmt parser.Stmt, name token.Token, kind SymbolKind) DocumentSymbol {
+	selection := d.tokenRange(name)
+	whole := selection
+	if span, ok := d.spans[stmt.Id()]; ok {
+		whole = Range{d.position(span.First.Start), d.position(span.Last.End)}
+	}
+	return DocumentSymbol{Name: name.Lexeme, Kind: kind, Range: whole, SelectionRange: selection}
+}
@@ -0,0 +1,113 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// Error codes defined by JSON-RPC and LSP.
+const (
+	parseError           = -32700
+	invalidRequest       = -32600
+	methodNotFound       = -32601
+	invalidParams        = -32602
+	serverNotInitialized = -32002
+	requestFailed        = -32803
+)
+
+// message is a JSON-RPC request, notification or response. Requests and
+// responses carry an ID, notifications don't.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	// Result is always set in responses without an error, even to null.
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes JSON-RPC messages framed by a Content-Length
+// header, as LSP does over stdio.
+type conn struct {
+	in  *textproto.Reader
+	out io.Writer
+}
+
+func newConn(in io.Reader, out io.Writer) conn {
+	return conn{textproto.NewReader(bufio.NewReader(in)), out}
+}
+
+// read returns the next message. A message that isn't valid JSON is
+// returned as a *responseError, and the connection can still be read.
+func (c conn) read() (message, error) {
+	header, err := c.in.ReadMIMEHeader()
+	if err != nil {
+		return message{}, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return message{}, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.in.R, body); err != nil {
+		return message{}, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return message{}, &responseError{parseError, err.Error()}
+	}
+	return msg, nil
+}
+
+// write sends msg, filling in the protocol version.
+func (c conn) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// notify sends a notification.
+func (c conn) notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(message{Method: method, Params: raw})
+}
+
+// reply sends the response to the request with the given id.
+func (c conn) reply(id *json.RawMessage, result any, err error) error {
+	if err != nil {
+		respErr, ok := err.(*responseError)
+		if !ok {
+			respErr = &responseError{requestFailed, err.Error()}
+		}
+		return c.write(message{ID: id, Error: respErr})
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.write(message{ID: id, Result: raw})
+}
@@ -0,0 +1,273 @@
+// Package lsp implements a Language Server Protocol server for Lox,
+// speaking JSON-RPC over stdio.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/nt54hamnghi/golox/internal/format"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// Server answers the requests of one editor about the Lox documents it
+// has open. Documents are analyzed again whenever they change, and their
+// diagnostics published right away.
+type Server struct {
+	conn conn
+	// Where problems with the connection itself are logged.
+	log         io.Writer
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer returns a server reading messages from in and writing to out.
+func NewServer(in io.Reader, out io.Writer, log io.Writer) *Server {
+	return &Server{
+		conn:      newConn(in, out),
+		log:       log,
+		documents: make(map[string]*document),
+	}
+}
+
+// Run serves requests until the editor sends the exit notification or
+// closes the input, and returns the process exit code: 0 if the editor
+// asked the server to shut down first, 1 otherwise.
+func (s *Server) Run() int {
+	for {
+		msg, err := s.conn.read()
+		var respErr *responseError
+		if errors.As(err, &respErr) {
+			fmt.Fprintln(s.log, "golox lsp:", err)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintln(s.log, "golox lsp:", err)
+			}
+			break
+		}
+		if msg.Method == "exit" {
+			break
+		}
+
+		if msg.Method == "" {
+			// a response, but the server sends no requests
+			continue
+		} else if msg.ID == nil {
+			err = s.notification(msg)
+		} else {
+			result, reqErr := s.request(msg)
+			err = s.conn.reply(msg.ID, result, reqErr)
+		}
+		if err != nil {
+			fmt.Fprintln(s.log, "golox lsp:", err)
+		}
+	}
+
+	if s.shutdown {
+		return 0
+	}
+	return 1
+}
+
+// request handles a request and returns its result.
+func (s *Server) request(msg message) (any, error) {
+	switch {
+	case msg.Method == "initialize":
+		s.initialized = true
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:           TextDocumentSyncFull,
+				DefinitionProvider:         true,
+				ReferencesProvider:         true,
+				HoverProvider:              true,
+				DocumentSymbolProvider:     true,
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "golox"},
+		}, nil
+	case !s.initialized:
+		return nil, &responseError{serverNotInitialized, "server not initialized"}
+	case s.shutdown:
+		return nil, &responseError{invalidRequest, "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		d, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return d.documentSymbols(d.prog), nil
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.formatting(params), nil
+	default:
+		return nil, &responseError{methodNotFound, "method not found: " + msg.Method}
+	}
+}
+
+// notification handles a notification, which gets no response.
+func (s *Server) notification(msg message) error {
+	if !s.initialized {
+		return nil
+	}
+
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return err
+		}
+		doc := params.TextDocument
+		return s.update(newDocument(doc.URI, doc.Version, doc.Text))
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil
+		}
+		// with full sync, the last change holds the whole content
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return s.update(newDocument(params.TextDocument.URI, params.TextDocument.Version, text))
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	}
+	return nil
+}
+
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{invalidParams, err.Error()}
+	}
+	return nil
+}
+
+// update replaces a document and publishes its diagnostics.
+func (s *Server) update(d *document) error {
+	s.documents[d.uri] = d
+	diagnostics := d.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         d.uri,
+		Version:     &d.version,
+		Diagnostics: diagnostics,
+	})
+}
+
+// definition returns the declaration of the variable at a position,
+// nil when there is none, for instance for globals defined natively.
+func (s *Server) definition(params TextDocumentPositionParams) *Location {
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, _, ok := d.symbolAt(d.offset(params.Position))
+	if !ok {
+		return nil
+	}
+	return &Location{d.uri, d.tokenRange(symbol.Name)}
+}
+
+// references returns the places referring to the variable at a position,
+// in source order.
+func (s *Server) references(params ReferenceParams) []Location {
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, _, ok := d.symbolAt(d.offset(params.Position))
+	if !ok {
+		return nil
+	}
+
+	tokens := slices.Clone(symbol.References)
+	if params.Context.IncludeDeclaration {
+		tokens = append(tokens, symbol.Name)
+	}
+	slices.SortFunc(tokens, func(a, b token.Token) int { return a.Start - b.Start })
+
+	locations := make([]Location, len(tokens))
+	for n, tok := range tokens {
+		locations[n] = Location{d.uri, d.tokenRange(tok)}
+	}
+	return locations
+}
+
+// hover describes the variable at a position.
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, tok, ok := d.symbolAt(d.offset(params.Position))
+	if !ok {
+		return nil
+	}
+	return &Hover{MarkupContent{"plaintext", hover(symbol)}, d.tokenRange(tok)}
+}
+
+// formatting returns the edit reformatting a whole document the way
+// `golox fmt` does, none when it is formatted or doesn't parse.
+func (s *Server) formatting(params DocumentFormattingParams) []TextEdit {
+	d, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	indent := params.Options.TabSize
+	if indent < 1 {
+		indent = format.DefaultIndent
+	}
+
+	formatted, err := format.Source(filename(d.uri), d.text, format.Options{Indent: indent})
+	if err != nil || formatted == d.text {
+		return []TextEdit{}
+	}
+	whole := Range{Position{0, 0}, d.position(len(d.text))}
+	return []TextEdit{{whole, formatted}}
+}
@@ -0,0 +1,298 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const uri = "file:///src/test.lox"
+
+// session runs a server over the given messages, each a method and its
+// params, and returns the exit code and the messages the server sent.
+// Messages are requests numbered from 1, unless their method is listed
+// in notifications.
+func session(t *testing.T, messages ...[2]any) (int, []message) {
+	t.Helper()
+	r := require.New(t)
+
+	var in bytes.Buffer
+	for n, m := range messages {
+		method := m[0].(string)
+		msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": m[1]}
+		if !notifications[method] {
+			msg["id"] = n + 1
+		}
+		body, err := json.Marshal(msg)
+		r.NoError(err)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var out, log bytes.Buffer
+	code := NewServer(&in, &out, &log).Run()
+	r.Empty(log.String())
+
+	var sent []message
+	c := newConn(&out, nil)
+	for {
+		msg, err := c.read()
+		if err != nil {
+			break
+		}
+		sent = append(sent, msg)
+	}
+	return code, sent
+}
+
+var notifications = map[string]bool{
+	"initialized":            true,
+	"exit":                   true,
+	"textDocument/didOpen":   true,
+	"textDocument/didChange": true,
+	"textDocument/didClose":  true,
+}
+
+func initialize() [2]any {
+	return [2]any{"initialize", map[string]any{"capabilities": map[string]any{}}}
+}
+
+func open(text string) [2]any {
+	return [2]any{"textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "lox", "version": 1, "text": text},
+	}}
+}
+
+func at(method string, line, character int, extra ...any) [2]any {
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+	if len(extra) == 2 {
+		params[extra[0].(string)] = extra[1]
+	}
+	return [2]any{method, params}
+}
+
+// response returns the result of the response to request id as JSON.
+func response(t *testing.T, sent []message, id int) string {
+	t.Helper()
+	for _, msg := range sent {
+		if msg.ID != nil && string(*msg.ID) == fmt.Sprint(id) {
+			if msg.Error != nil {
+				return fmt.Sprintf(`{"error": %d}`, msg.Error.Code)
+			}
+			return string(msg.Result)
+		}
+	}
+	t.Fatalf("no response to request %d", id)
+	return ""
+}
+
+// diagnostics returns the params of every publishDiagnostics notification as JSON.
+func diagnostics(sent []message) []string {
+	var published []string
+	for _, msg := range sent {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			published = append(published, string(msg.Params))
+		}
+	}
+	return published
+}
+
+func TestServerLifecycle(t *testing.T) {
+	r := require.New(t)
+
+	code, sent := session(t,
+		[2]any{"shutdown", nil},
+		initialize(),
+		[2]any{"initialized", map[string]any{}},
+		[2]any{"workspace/symbol", map[string]any{}},
+		[2]any{"shutdown", nil},
+		[2]any{"shutdown", nil},
+		[2]any{"exit", nil},
+	)
+
+	r.Equal(0, code)
+	r.JSONEq(`{"error": -32002}`, response(t, sent, 1))
+	r.JSONEq(`{
+		"capabilities": {
+			"textDocumentSync": 1,
+			"definitionProvider": true,
+			"referencesProvider": true,
+			"hoverProvider": true,
+			"documentSymbolProvider": true,
+			"documentFormattingProvider": true
+		},
+		"serverInfo": {"name": "golox"}
+	}`, response(t, sent, 2))
+	r.JSONEq(`{"error": -32601}`, response(t, sent, 4))
+	r.JSONEq(`null`, response(t, sent, 5))
+	r.JSONEq(`{"error": -32600}`, response(t, sent, 6))
+
+	// exiting without shutting down is an error
+	code, _ = session(t, initialize(), [2]any{"exit", nil})
+	r.Equal(1, code)
+}
+
+func TestServerPublishesDiagnostics(t *testing.T) {
+	r := require.New(t)
+
+	_, sent := session(t,
+		initialize(),
+		open("var a = @;\nprint a"),
+		[2]any{"textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": 2},
+			"contentChanges": []any{map[string]any{"text": "fun f(x) {\n  return 1;\n  print x;\n}\nreturn;"}},
+		}},
+		[2]any{"textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": 3},
+			"contentChanges": []any{map[string]any{"text": "{ var s = \"é\"; var b = s; }"}},
+		}},
+		[2]any{"textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": uri}}},
+	)
+
+	published := diagnostics(sent)
+	r.Len(published, 4)
+	r.JSONEq(`{"uri": "file:///src/test.lox", "version": 1, "diagnostics": [
+		{"range": {"start": {"line": 0, "character": 8}, "end": {"line": 0, "character": 9}},
+			"severity": 1, "source": "golox", "message": "Unexpected character: @"},
+		{"range": {"start": {"line": 0, "character": 9}, "end": {"line": 0, "character": 10}},
+			"severity": 1, "source": "golox", "message": "Expect expression."},
+		{"range": {"start": {"line": 1, "character": 7}, "end": {"line": 1, "character": 7}},
+			"severity": 1, "source": "golox", "message": "Expect ';' after value."}
+	]}`, published[0])
+	r.JSONEq(`{"uri": "file:///src/test.lox", "version": 2, "diagnostics": [
+		{"range": {"start": {"line": 4, "character": 0}, "end": {"line": 4, "character": 6}},
+			"severity": 1, "source": "golox", "message": "Can't return from top-level code."}
+	]}`, published[1])
+	r.JSONEq(`{"uri": "file:///src/test.lox", "version": 3, "diagnostics": [
+		{"range": {"start": {"line": 0, "character": 19}, "end": {"line": 0, "character": 20}},
+			"severity": 2, "code": "unused-variable", "source": "golox", "message": "Local variable 'b' is never used."}
+	]}`, published[2])
+	r.JSONEq(`{"uri": "file:///src/test.lox", "diagnostics": []}`, published[3])
+}
+
+const program = `var total = 0;
+fun add(a, b) {
+  return a + b;
+}
+class Counter {
+  init(step) { this.step = step; }
+  tick() {
+    fun next() { return add(total, 1); }
+    total = next();
+  }
+}
+print add(total, 2);
+`
+
+func TestServerNavigation(t *testing.T) {
+	r := require.New(t)
+
+	_, sent := session(t,
+		initialize(),
+		open(program),
+		// total in `return add(total, 1)`
+		at("textDocument/definition", 7, 29),
+		at("textDocument/references", 0, 5, "context", map[string]any{"includeDeclaration": true}),
+		at("textDocument/references", 1, 8, "context", map[string]any{"includeDeclaration": false}),
+		at("textDocument/hover", 11, 7),
+		at("textDocument/hover", 2, 9),
+		// `print` isn't a variable
+		at("textDocument/definition", 11, 2),
+	)
+
+	r.JSONEq(`{"uri": "file:///src/test.lox", "range": {"start": {"line": 0, "character": 4}, "end": {"line": 0, "character": 9}}}`,
+		response(t, sent, 3))
+	r.JSONEq(`[
+		{"uri": "file:///src/test.lox", "range": {"start": {"line": 0, "character": 4}, "end": {"line": 0, "character": 9}}},
+		{"uri": "file:///src/test.lox", "range": {"start": {"line": 7, "character": 28}, "end": {"line": 7, "character": 33}}},
+		{"uri": "file:///src/test.lox", "range": {"start": {"line": 8, "character": 4}, "end": {"line": 8, "character": 9}}},
+		{"uri": "file:///src/test.lox", "range": {"start": {"line": 11, "character": 10}, "end": {"line": 11, "character": 15}}}
+	]`, response(t, sent, 4))
+	r.JSONEq(`[
+		{"uri": "file:///src/test.lox", "range": {"start": {"line": 2, "character": 9}, "end": {"line": 2, "character": 10}}}
+	]`, response(t, sent, 5))
+	r.JSONEq(`{"contents": {"kind": "plaintext", "value": "(global function) add, takes 2 arguments"},
+		"range": {"start": {"line": 11, "character": 6}, "end": {"line": 11, "character": 9}}}`,
+		response(t, sent, 6))
+	r.JSONEq(`{"contents": {"kind": "plaintext", "value": "(parameter) a"},
+		"range": {"start": {"line": 2, "character": 9}, "end": {"line": 2, "character": 10}}}`,
+		response(t, sent, 7))
+	r.JSONEq(`null`, response(t, sent, 8))
+}
+
+func TestServerDocumentSymbols(t *testing.T) {
+	r := require.New(t)
+
+	_, sent := session(t,
+		initialize(),
+		open(program),
+		[2]any{"textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}},
+	)
+
+	rng := func(l1, c1, l2, c2 int) string {
+		return fmt.Sprintf(`{"start": {"line": %d, "character": %d}, "end": {"line": %d, "character": %d}}`, l1, c1, l2, c2)
+	}
+	r.JSONEq(`[
+		{"name": "add", "kind": 12, "range": `+rng(1, 0, 3, 1)+`, "selectionRange": `+rng(1, 4, 1, 7)+`},
+		{"name": "Counter", "kind": 5, "range": `+rng(4, 0, 10, 1)+`, "selectionRange": `+rng(4, 6, 4, 13)+`, "children": [
+			{"name": "init", "kind": 9, "range": `+rng(5, 2, 5, 34)+`, "selectionRange": `+rng(5, 2, 5, 6)+`},
+			{"name": "tick", "kind": 6, "range": `+rng(6, 2, 9, 3)+`, "selectionRange": `+rng(6, 2, 6, 6)+`, "children": [
+				{"name": "next", "kind": 12, "range": `+rng(7, 4, 7, 40)+`, "selectionRange": `+rng(7, 8, 7, 12)+`}
+			]}
+		]}
+	]`, response(t, sent, 3))
+}
+
+func TestServerFormatting(t *testing.T) {
+	r := require.New(t)
+
+	formatting := [2]any{"textDocument/formatting", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"options":      map[string]any{"tabSize": 4, "insertSpaces": true},
+	}}
+	_, sent := session(t,
+		initialize(),
+		open("fun f(){print 1;}"),
+		formatting,
+		open("fun f() {\n    print 1;\n}\n"),
+		formatting,
+		open("print"),
+		formatting,
+	)
+
+	r.JSONEq(`[{"range": {"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 17}},
+		"newText": "fun f() {\n    print 1;\n}\n"}]`, response(t, sent, 3))
+	r.JSONEq(`[]`, response(t, sent, 5))
+	r.JSONEq(`[]`, response(t, sent, 7))
+}
+
+func TestDocumentPositions(t *testing.T) {
+	r := require.New(t)
+	// é is 2 bytes and 1 UTF-16 unit, 😀 is 4 bytes and 2 units
+	d := newDocument(uri, 1, "a\né😀b\n")
+
+	tests := []struct {
+		offset int
+		pos    Position
+	}{
+		{0, Position{0, 0}},
+		{2, Position{1, 0}},
+		{4, Position{1, 1}},
+		{8, Position{1, 3}},
+		{10, Position{2, 0}},
+	}
+	for _, tt := range tests {
+		r.Equal(tt.pos, d.position(tt.offset))
+		r.Equal(tt.offset, d.offset(tt.pos))
+	}
+
+	// positions past the end of a line stay on it
+	r.Equal(9, d.offset(Position{1, 10}))
+	r.True(strings.HasPrefix(d.text[d.offset(Position{1, 3}):], "b"))
+}
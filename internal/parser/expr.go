@@ -25,6 +25,10 @@ func init() {
 	gob.Register(Assignment{})
 	gob.Register(Binary{})
 	gob.Register(Logical{})
+	gob.Register(List{})
+	gob.Register(Map{})
+	gob.Register(Index{})
+	gob.Register(IndexSet{})
 }
 
 type ExprVisitor interface {
@@ -40,19 +44,28 @@ type ExprVisitor interface {
 	VisitAssignmentExpr(expr Assignment) (any, error)
 	VisitBinaryExpr(expr Binary) (any, error)
 	VisitLogicalExpr(expr Logical) (any, error)
+	VisitListExpr(expr List) (any, error)
+	VisitMapExpr(expr Map) (any, error)
+	VisitIndexExpr(expr Index) (any, error)
+	VisitIndexSetExpr(expr IndexSet) (any, error)
 }
 
 type Literal struct {
 	Value any
+	Token token.Token
 	id    NodeID
 }
 
-func NewLiteral(value any) Literal {
+func NewLiteral(value any, tok token.Token) Literal {
 	node := Literal{
 		Value: value,
+		Token: tok,
 	}
 
-	tmp := struct{ Value any }{Value: node.Value}
+	tmp := struct {
+		Value any
+		Token token.Token
+	}{Value: node.Value, Token: node.Token}
 	node.id = NewNodeIDFrom(tmp)
 	return node
 }
@@ -62,7 +75,10 @@ func (self Literal) Accept(visitor ExprVisitor) (any, error) {
 }
 
 func (self Literal) Id() NodeID {
-	tmp := struct{ Value any }{Value: self.Value}
+	tmp := struct {
+		Value any
+		Token token.Token
+	}{Value: self.Value, Token: self.Token}
 	if nodeDigest(self.id.id, tmp) != self.id.digest {
 		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
 	}
@@ -445,3 +461,159 @@ func (self Logical) Id() NodeID {
 	}
 	return self.id
 }
+
+type List struct {
+	Elements []Expr
+	Bracket  token.Token
+	id       NodeID
+}
+
+func NewList(elements []Expr, bracket token.Token) List {
+	node := List{
+		Elements: elements,
+		Bracket:  bracket,
+	}
+
+	tmp := struct {
+		Elements []Expr
+		Bracket  token.Token
+	}{Elements: node.Elements, Bracket: node.Bracket}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self List) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitListExpr(self)
+}
+
+func (self List) Id() NodeID {
+	tmp := struct {
+		Elements []Expr
+		Bracket  token.Token
+	}{Elements: self.Elements, Bracket: self.Bracket}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type Map struct {
+	Keys   []Expr
+	Values []Expr
+	Brace  token.Token
+	id     NodeID
+}
+
+func NewMap(keys []Expr, values []Expr, brace token.Token) Map {
+	node := Map{
+		Keys:   keys,
+		Values: values,
+		Brace:  brace,
+	}
+
+	tmp := struct {
+		Keys   []Expr
+		Values []Expr
+		Brace  token.Token
+	}{Keys: node.Keys, Values: node.Values, Brace: node.Brace}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self Map) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitMapExpr(self)
+}
+
+func (self Map) Id() NodeID {
+	tmp := struct {
+		Keys   []Expr
+		Values []Expr
+		Brace  token.Token
+	}{Keys: self.Keys, Values: self.Values, Brace: self.Brace}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type Index struct {
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+	id      NodeID
+}
+
+func NewIndex(object Expr, bracket token.Token, index Expr) Index {
+	node := Index{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+	}
+
+	tmp := struct {
+		Object  Expr
+		Bracket token.Token
+		Index   Expr
+	}{Object: node.Object, Bracket: node.Bracket, Index: node.Index}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self Index) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitIndexExpr(self)
+}
+
+func (self Index) Id() NodeID {
+	tmp := struct {
+		Object  Expr
+		Bracket token.Token
+		Index   Expr
+	}{Object: self.Object, Bracket: self.Bracket, Index: self.Index}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type IndexSet struct {
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+	Value   Expr
+	id      NodeID
+}
+
+func NewIndexSet(object Expr, bracket token.Token, index Expr, value Expr) IndexSet {
+	node := IndexSet{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+		Value:   value,
+	}
+
+	tmp := struct {
+		Object  Expr
+		Bracket token.Token
+		Index   Expr
+		Value   Expr
+	}{Object: node.Object, Bracket: node.Bracket, Index: node.Index, Value: node.Value}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self IndexSet) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitIndexSetExpr(self)
+}
+
+func (self IndexSet) Id() NodeID {
+	tmp := struct {
+		Object  Expr
+		Bracket token.Token
+		Index   Expr
+		Value   Expr
+	}{Object: self.Object, Bracket: self.Bracket, Index: self.Index, Value: self.Value}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
@@ -0,0 +1,324 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// JSONPrinter prints syntax trees as JSON. Every node is an object whose
+// "type" names the node, followed by its fields in declaration order.
+// Tokens are represented by their lexeme and literals by their value.
+type JSONPrinter struct{}
+
+// Program returns the indented JSON array of the statements of prog.
+func (p JSONPrinter) Program(prog []Stmt) (string, error) {
+	nodes := make([]any, len(prog))
+	for i, stmt := range prog {
+		nodes[i] = p.stmt(stmt)
+	}
+	bytes, err := json.MarshalIndent(nodes, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes) + "\n", nil
+}
+
+// jsonNode is a JSON object whose fields keep their order.
+type jsonNode []jsonField
+
+type jsonField struct {
+	key   string
+	value any
+}
+
+func newJSONNode(typ string, fields ...jsonField) jsonNode {
+	return append(jsonNode{{"type", typ}}, fields...)
+}
+
+// MarshalJSON implements [json.Marshaler].
+func (n jsonNode) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, field := range n {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func (p JSONPrinter) stmt(stmt Stmt) any {
+	if stmt == nil {
+		return nil
+	}
+	node, _ := stmt.Accept(p)
+	return node
+}
+
+func (p JSONPrinter) stmts(stmts []Stmt) []any {
+	nodes := make([]any, len(stmts))
+	for i, stmt := range stmts {
+		nodes[i] = p.stmt(stmt)
+	}
+	return nodes
+}
+
+func (p JSONPrinter) expr(expr Expr) any {
+	if expr == nil {
+		return nil
+	}
+	node, _ := expr.Accept(p)
+	return node
+}
+
+func (p JSONPrinter) exprs(exprs []Expr) []any {
+	nodes := make([]any, len(exprs))
+	for i, expr := range exprs {
+		nodes[i] = p.expr(expr)
+	}
+	return nodes
+}
+
+func lexemes(tokens []token.Token) []string {
+	texts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		texts[i] = tok.Lexeme
+	}
+	return texts
+}
+
+// VisitExpressionStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitExpressionStmt(stmt Expression) (any, error) {
+	return newJSONNode("Expression", jsonField{"expression", p.expr(stmt.Expression)}), nil
+}
+
+// VisitPrintStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitPrintStmt(stmt Print) (any, error) {
+	return newJSONNode("Print", jsonField{"expression", p.expr(stmt.Expression)}), nil
+}
+
+// VisitVarStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitVarStmt(stmt Var) (any, error) {
+	return newJSONNode("Var",
+		jsonField{"name", stmt.Name.Lexeme},
+		jsonField{"initializer", p.expr(stmt.Initializer)},
+	), nil
+}
+
+// VisitClassStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitClassStmt(stmt Class) (any, error) {
+	var superclass any
+	if stmt.Superclass != nil {
+		superclass = p.expr(*stmt.Superclass)
+	}
+	methods := make([]any, len(stmt.Methods))
+	for i, method := range stmt.Methods {
+		methods[i] = p.stmt(method)
+	}
+	return newJSONNode("Class",
+		jsonField{"name", stmt.Name.Lexeme},
+		jsonField{"superclass", superclass},
+		jsonField{"methods", methods},
+	), nil
+}
+
+// VisitFunctionStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitFunctionStmt(stmt Function) (any, error) {
+	return newJSONNode("Function",
+		jsonField{"name", stmt.Name.Lexeme},
+		jsonField{"params", lexemes(stmt.Params)},
+		jsonField{"body", p.stmts(stmt.Body)},
+	), nil
+}
+
+// VisitIfStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitIfStmt(stmt If) (any, error) {
+	return newJSONNode("If",
+		jsonField{"condition", p.expr(stmt.Condition)},
+		jsonField{"thenBranch", p.stmt(stmt.ThenBranch)},
+		jsonField{"elseBranch", p.stmt(stmt.ElseBranch)},
+	), nil
+}
+
+// VisitWhileStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitWhileStmt(stmt While) (any, error) {
+	return newJSONNode("While",
+		jsonField{"condition", p.expr(stmt.Condition)},
+		jsonField{"body", p.stmt(stmt.Body)},
+	), nil
+}
+
+// VisitForStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitForStmt(stmt For) (any, error) {
+	return newJSONNode("For",
+		jsonField{"initializer", p.stmt(stmt.Initializer)},
+		jsonField{"condition", p.expr(stmt.Condition)},
+		jsonField{"increment", p.expr(stmt.Increment)},
+		jsonField{"body", p.stmt(stmt.Body)},
+	), nil
+}
+
+// VisitBreakStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitBreakStmt(stmt Break) (any, error) {
+	return newJSONNode("Break"), nil
+}
+
+// VisitContinueStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitContinueStmt(stmt Continue) (any, error) {
+	return newJSONNode("Continue"), nil
+}
+
+// VisitBreakpointStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitBreakpointStmt(stmt Breakpoint) (any, error) {
+	return newJSONNode("Breakpoint"), nil
+}
+
+// VisitImportStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitImportStmt(stmt Import) (any, error) {
+	var alias any
+	if stmt.Alias != nil {
+		alias = stmt.Alias.Lexeme
+	}
+	return newJSONNode("Import",
+		jsonField{"path", stmt.Path.Literal},
+		jsonField{"alias", alias},
+	), nil
+}
+
+// VisitReturnStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitReturnStmt(stmt Return) (any, error) {
+	return newJSONNode("Return", jsonField{"value", p.expr(stmt.Value)}), nil
+}
+
+// VisitBlockStmt implements [StmtVisitor].
+func (p JSONPrinter) VisitBlockStmt(stmt Block) (any, error) {
+	return newJSONNode("Block", jsonField{"stmts", p.stmts(stmt.Stmts)}), nil
+}
+
+// VisitLiteralExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitLiteralExpr(expr Literal) (any, error) {
+	return newJSONNode("Literal", jsonField{"value", expr.Value}), nil
+}
+
+// VisitCallExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitCallExpr(expr Call) (any, error) {
+	return newJSONNode("Call",
+		jsonField{"callee", p.expr(expr.Callee)},
+		jsonField{"arguments", p.exprs(expr.Arguments)},
+	), nil
+}
+
+// VisitGetExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitGetExpr(expr Get) (any, error) {
+	return newJSONNode("Get",
+		jsonField{"object", p.expr(expr.Object)},
+		jsonField{"name", expr.Name.Lexeme},
+	), nil
+}
+
+// VisitSetExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitSetExpr(expr Set) (any, error) {
+	return newJSONNode("Set",
+		jsonField{"object", p.expr(expr.Object)},
+		jsonField{"name", expr.Name.Lexeme},
+		jsonField{"value", p.expr(expr.Value)},
+	), nil
+}
+
+// VisitSuperExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitSuperExpr(expr Super) (any, error) {
+	return newJSONNode("Super", jsonField{"method", expr.Method.Lexeme}), nil
+}
+
+// VisitThisExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitThisExpr(expr This) (any, error) {
+	return newJSONNode("This"), nil
+}
+
+// VisitGroupingExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitGroupingExpr(expr Grouping) (any, error) {
+	return newJSONNode("Grouping", jsonField{"expression", p.expr(expr.Expression)}), nil
+}
+
+// VisitUnaryExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitUnaryExpr(expr Unary) (any, error) {
+	return newJSONNode("Unary",
+		jsonField{"operator", expr.Operator.Lexeme},
+		jsonField{"right", p.expr(expr.Right)},
+	), nil
+}
+
+// VisitVariableExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitVariableExpr(expr Variable) (any, error) {
+	return newJSONNode("Variable", jsonField{"name", expr.Name.Lexeme}), nil
+}
+
+// VisitAssignmentExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitAssignmentExpr(expr Assignment) (any, error) {
+	return newJSONNode("Assignment",
+		jsonField{"name", expr.Name.Lexeme},
+		jsonField{"value", p.expr(expr.Value)},
+	), nil
+}
+
+// VisitBinaryExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitBinaryExpr(expr Binary) (any, error) {
+	return newJSONNode("Binary",
+		jsonField{"left", p.expr(expr.Left)},
+		jsonField{"operator", expr.Operator.Lexeme},
+		jsonField{"right", p.expr(expr.Right)},
+	), nil
+}
+
+// VisitLogicalExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitLogicalExpr(expr Logical) (any, error) {
+	return newJSONNode("Logical",
+		jsonField{"left", p.expr(expr.Left)},
+		jsonField{"operator", expr.Operator.Lexeme},
+		jsonField{"right", p.expr(expr.Right)},
+	), nil
+}
+
+// VisitListExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitListExpr(expr List) (any, error) {
+	return newJSONNode("List", jsonField{"elements", p.exprs(expr.Elements)}), nil
+}
+
+// VisitMapExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitMapExpr(expr Map) (any, error) {
+	return newJSONNode("Map",
+		jsonField{"keys", p.exprs(expr.Keys)},
+		jsonField{"values", p.exprs(expr.Values)},
+	), nil
+}
+
+// VisitIndexExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitIndexExpr(expr Index) (any, error) {
+	return newJSONNode("Index",
+		jsonField{"object", p.expr(expr.Object)},
+		jsonField{"index", p.expr(expr.Index)},
+	), nil
+}
+
+// VisitIndexSetExpr implements [ExprVisitor].
+func (p JSONPrinter) VisitIndexSetExpr(expr IndexSet) (any, error) {
+	return newJSONNode("IndexSet",
+		jsonField{"object", p.expr(expr.Object)},
+		jsonField{"index", p.expr(expr.Index)},
+		jsonField{"value", p.expr(expr.Value)},
+	), nil
+}
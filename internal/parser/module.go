@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"path"
+	"strings"
+
+	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// ModuleName derives the name an import binds when it has no alias
+// from the last element of the module path, without its extension:
+// "lib/string_utils.lox" is bound to string_utils. It reports false
+// if that is not a valid identifier.
+func ModuleName(modulePath string) (string, bool) {
+	base := path.Base(strings.ReplaceAll(modulePath, `\`, "/"))
+	name := strings.TrimSuffix(base, path.Ext(base))
+	if name == "" || scanner.IsKeyword(name) {
+		return "", false
+	}
+	for i, char := range name {
+		isAlpha := char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+		isDigit := char >= '0' && char <= '9'
+		if !isAlpha && !(isDigit && i > 0) {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// Name returns the name the import binds the module to: its alias if it
+// has one, otherwise the name derived from its path, located at the path.
+func (self Import) Name() token.Token {
+	if self.Alias != nil {
+		return *self.Alias
+	}
+	name, _ := ModuleName(self.Path.Literal.(string))
+	t := self.Path
+	t.Type = token.IDENTIFIER
+	t.Lexeme = name
+	t.Literal = nil
+	return t
+}
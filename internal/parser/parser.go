@@ -2,8 +2,8 @@ package parser
 
 import (
 	"fmt"
-	"os"
 	"slices"
+	"strings"
 
 	"github.com/nt54hamnghi/golox/internal/errors"
 	"github.com/nt54hamnghi/golox/internal/scanner/token"
@@ -12,41 +12,133 @@ import (
 type Parser struct {
 	tokens  []token.Token
 	current int
+	// Syntax errors collected so far, in source order.
+	errs ParserError
+	// Whether the tokens were typed at a prompt, see ParseInteractive.
+	interactive bool
+	// The tokens each statement was parsed from, see Spans.
+	spans map[NodeID]Span
+}
+
+// Span is the range of tokens a statement was parsed from.
+type Span struct {
+	First token.Token
+	Last  token.Token
 }
 
 func NewParser(tokens []token.Token) Parser {
-	return Parser{tokens, 0}
+	return Parser{tokens, 0, nil, false, make(map[NodeID]Span)}
+}
+
+// Spans returns, by node identity, the first and last token of every
+// declaration, statement and method parsed so far. Tools that reprint
+// the source use them to find the blank lines and comments around a
+// statement. Clauses of a for loop have no span.
+func (p *Parser) Spans() map[NodeID]Span {
+	return p.spans
 }
 
+// spanned runs parse and records the tokens spanned by the statement it returns.
+func (p *Parser) spanned(parse func() (Stmt, error)) (Stmt, error) {
+	first := p.peek()
+	stmt, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	p.spans[stmt.Id()] = Span{first, p.previous()}
+	return stmt, nil
+}
+
+// Parse parses the whole token stream, recovering from syntax errors at
+// statement boundaries so that every error in the program is reported.
+// If any error occurred, it returns the statements that did parse along
+// with a ParserError listing all of them.
+//
 // program → declaration* EOF ;
-func (p Parser) Parse() []Stmt {
+func (p *Parser) Parse() ([]Stmt, error) {
 	stmts := make([]Stmt, 0)
 
 	for !p.isAtEnd() {
 		s, err := p.declaration()
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			p.report(err)
 			p.synchronize()
 		} else {
 			stmts = append(stmts, s)
 		}
 	}
 
-	return stmts
+	if len(p.errs) > 0 {
+		return stmts, p.errs
+	}
+	return stmts, nil
+}
+
+// ParseInteractive parses like Parse, but lets the final expression
+// statement omit its semicolon, as is convenient when typing at a prompt.
+func (p *Parser) ParseInteractive() ([]Stmt, error) {
+	p.interactive = true
+	return p.Parse()
+}
+
+// ParseExpression parses tokens holding a single expression and nothing
+// else, such as an expression typed at a debugger prompt.
+func (p *Parser) ParseExpression() (Expr, error) {
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isAtEnd() {
+		return nil, p.error("Expect end of expression.")
+	}
+	return expr, nil
 }
 
-// declaration → classDecl | funDecl | varDecl | statement ;
+// declaration → importDecl | classDecl | funDecl | varDecl | statement ;
 func (p *Parser) declaration() (Stmt, error) {
-	if p.match(token.FUN) {
-		return p.function("function")
+	return p.spanned(func() (Stmt, error) {
+		if p.match(token.IMPORT) {
+			return p.importDeclaration()
+		}
+		if p.match(token.FUN) {
+			return p.function("function")
+		}
+		if p.match(token.CLASS) {
+			return p.classDeclaration()
+		}
+		if p.match(token.VAR) {
+			return p.varDeclaration()
+		}
+		return p.statement()
+	})
+}
+
+// importDecl → "import" STRING ( "as" IDENTIFIER )? ";" ;
+func (p *Parser) importDeclaration() (Stmt, error) {
+	keyword := p.previous()
+	path, err := p.consume(token.STRING, "Expect module path after 'import'.")
+	if err != nil {
+		return nil, err
 	}
-	if p.match(token.CLASS) {
-		return p.classDeclaration()
+
+	// "as" is only special here, so it stays usable as an identifier elsewhere
+	var alias *token.Token
+	if p.check(token.IDENTIFIER) && p.peek().Lexeme == "as" {
+		p.advance()
+		name, err := p.consume(token.IDENTIFIER, "Expect module name after 'as'.")
+		if err != nil {
+			return nil, err
+		}
+		alias = &name
+	} else if _, ok := ModuleName(path.Literal.(string)); !ok {
+		return nil, errors.StaticErrorAtToken(path, "Module path doesn't end in a valid name, use 'as' to name it.")
 	}
-	if p.match(token.VAR) {
-		return p.varDeclaration()
+
+	if _, err := p.consume(token.SEMICOLON, "Expect ';' after import."); err != nil {
+		return nil, err
 	}
-	return p.statement()
+
+	return NewImport(keyword, path, alias), nil
 }
 
 // classDecl → "class" IDENTIFIER ( "<" IDENTIFIER )? "{" function* "}";
@@ -72,7 +164,9 @@ func (p *Parser) classDeclaration() (Stmt, error) {
 
 	methods := make([]Function, 0)
 	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
-		stmt, err := p.function("method")
+		stmt, err := p.spanned(func() (Stmt, error) {
+			return p.function("method")
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -104,9 +198,8 @@ func (p *Parser) function(kind string) (Stmt, error) {
 	params := make([]token.Token, 0)
 	if !p.check(token.RIGHT_PAREN) {
 		for {
-			if len(params) >= 225 {
-				err = errors.StaticErrorAtToken(p.peek(), "Can't have more than 255 parameters.")
-				fmt.Fprint(os.Stderr, err.Error())
+			if len(params) >= 255 {
+				p.report(errors.StaticErrorAtToken(p.peek(), "Can't have more than 255 parameters."))
 			}
 			param, err := p.consume(token.IDENTIFIER, "Expect parameter name.")
 			if err != nil {
@@ -159,28 +252,48 @@ func (p *Parser) varDeclaration() (Stmt, error) {
 	return NewVar(ident, init), nil
 }
 
-// statement → exprStmt | ifStmt | printStmt | returnStmt | whileStmt | forStmt | block ;
+// statement → exprStmt | ifStmt | printStmt | returnStmt | whileStmt | forStmt | breakStmt | continueStmt | breakpointStmt | block ;
 func (p *Parser) statement() (Stmt, error) {
-	switch {
-	case p.match(token.RETURN):
-		return p.returnStatement()
-	case p.match(token.FOR):
-		return p.forStatement()
-	case p.match(token.WHILE):
-		return p.whileStatement()
-	case p.match(token.IF):
-		return p.ifStatement()
-	case p.match(token.PRINT):
-		return p.printStatement()
-	case p.match(token.LEFT_BRACE):
-		stmts, err := p.block()
-		if err != nil {
-			return nil, err
+	return p.spanned(func() (Stmt, error) {
+		switch {
+		case p.match(token.RETURN):
+			return p.returnStatement()
+		case p.match(token.BREAK):
+			keyword := p.previous()
+			if _, err := p.consume(token.SEMICOLON, "Expect ';' after 'break'."); err != nil {
+				return nil, err
+			}
+			return NewBreak(keyword), nil
+		case p.match(token.CONTINUE):
+			keyword := p.previous()
+			if _, err := p.consume(token.SEMICOLON, "Expect ';' after 'continue'."); err != nil {
+				return nil, err
+			}
+			return NewContinue(keyword), nil
+		case p.match(token.BREAKPOINT):
+			keyword := p.previous()
+			if _, err := p.consume(token.SEMICOLON, "Expect ';' after 'breakpoint'."); err != nil {
+				return nil, err
+			}
+			return NewBreakpoint(keyword), nil
+		case p.match(token.FOR):
+			return p.forStatement()
+		case p.match(token.WHILE):
+			return p.whileStatement()
+		case p.match(token.IF):
+			return p.ifStatement()
+		case p.match(token.PRINT):
+			return p.printStatement()
+		case p.match(token.LEFT_BRACE):
+			stmts, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			return NewBlock(stmts), nil
+		default:
+			return p.expressionStatement()
 		}
-		return NewBlock(stmts), nil
-	default:
-		return p.expressionStatement()
-	}
+	})
 }
 
 // returnStmt → "return" expression? ";" ;
@@ -198,13 +311,14 @@ func (p *Parser) returnStatement() (Stmt, error) {
 	}
 	_, err = p.consume(token.SEMICOLON, "Expect ';' after return value.")
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	return NewReturn(keyword, value), nil
 }
 
 // forStmt → "for" "(" ( varDecl | exprStmt | ";" ) expression? ";"  expression? ")" statement ;
 func (p *Parser) forStatement() (Stmt, error) {
+	keyword := p.previous()
 	var err error
 	_, err = p.consume(token.LEFT_PAREN, "Expect '(' after 'for'.")
 	if err != nil {
@@ -260,22 +374,12 @@ func (p *Parser) forStatement() (Stmt, error) {
 		return nil, err
 	}
 
-	if increment != nil {
-		body = NewBlock([]Stmt{body, NewExpression(increment)})
-	}
-	if condition == nil {
-		condition = NewLiteral(true)
-	}
-	body = NewWhile(condition, body)
-	if initializer != nil {
-		body = NewBlock([]Stmt{initializer, body})
-	}
-
-	return body, nil
+	return NewFor(keyword, initializer, condition, increment, body), nil
 }
 
 // whileStmt → "while" "(" expression ")" statement ;
 func (p *Parser) whileStatement() (Stmt, error) {
+	keyword := p.previous()
 	if _, err := p.consume(token.LEFT_PAREN, "Expect '(' after 'while'."); err != nil {
 		return nil, err
 	}
@@ -290,7 +394,7 @@ func (p *Parser) whileStatement() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewWhile(condition, body), nil
+	return NewWhile(keyword, condition, body), nil
 }
 
 // ifStmt → "if" "(" expression ")" statement ( "else" statement )? ;
@@ -357,6 +461,10 @@ func (p *Parser) expressionStatement() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
+	// at a prompt, the last expression may be typed without a semicolon
+	if p.interactive && p.isAtEnd() {
+		return NewExpression(expr), nil
+	}
 	if err := p.expectSemicolon(); err != nil {
 		return nil, err
 	}
@@ -389,6 +497,8 @@ func (p *Parser) assignment() (Expr, error) {
 			return NewAssignment(name, value), nil
 		} else if get, ok := expr.(Get); ok {
 			return NewSet(get.Object, get.Name, value), nil
+		} else if index, ok := expr.(Index); ok {
+			return NewIndexSet(index.Object, index.Bracket, index.Index, value), nil
 		}
 
 		return nil, errors.StaticErrorAtToken(equal, "Invalid assignment target.")
@@ -526,7 +636,7 @@ func (p *Parser) unary() (Expr, error) {
 	return p.call()
 }
 
-// call → primary ( "(" arguments? ")" | "." IDENTIFIER )* ;
+// call → primary ( "(" arguments? ")" | "." IDENTIFIER | "[" expression "]" )* ;
 // arguments → expression ( "," expression )* ;
 func (p *Parser) call() (Expr, error) {
 	expr, err := p.primary()
@@ -545,6 +655,16 @@ func (p *Parser) call() (Expr, error) {
 				return nil, err
 			}
 			expr = NewGet(expr, name)
+		} else if p.match(token.LEFT_BRACKET) {
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			bracket, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after index.")
+			if err != nil {
+				return nil, err
+			}
+			expr = NewIndex(expr, bracket, index)
 		} else {
 			break
 		}
@@ -558,8 +678,7 @@ func (p *Parser) finishCall(callee Expr) (Expr, error) {
 	if !p.check(token.RIGHT_PAREN) {
 		for {
 			if len(args) >= 255 {
-				err := errors.StaticErrorAtToken(p.peek(), "Can't have more than 255 arguments.")
-				fmt.Fprint(os.Stderr, err.Error())
+				p.report(errors.StaticErrorAtToken(p.peek(), "Can't have more than 255 arguments."))
 			}
 			expr, err := p.expression()
 			if err != nil {
@@ -580,22 +699,79 @@ func (p *Parser) finishCall(callee Expr) (Expr, error) {
 	return NewCall(callee, paren, args), nil
 }
 
+// finishList parses the elements of a list literal after its opening bracket.
+func (p *Parser) finishList() (Expr, error) {
+	elements := make([]Expr, 0)
+	if !p.check(token.RIGHT_BRACKET) {
+		for {
+			expr, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, expr)
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	bracket, err := p.consume(token.RIGHT_BRACKET, "Expect ']' after list elements.")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewList(elements, bracket), nil
+}
+
+// finishMap parses the entries of a map literal after its opening brace.
+func (p *Parser) finishMap() (Expr, error) {
+	keys := make([]Expr, 0)
+	values := make([]Expr, 0)
+	if !p.check(token.RIGHT_BRACE) {
+		for {
+			key, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.consume(token.COLON, "Expect ':' after map key."); err != nil {
+				return nil, err
+			}
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			values = append(values, value)
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	brace, err := p.consume(token.RIGHT_BRACE, "Expect '}' after map entries.")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMap(keys, values, brace), nil
+}
+
 // primary → "true" | "false" | "nil" | "this"
 //
 //	| NUMBER | STRING | IDENTIFIER | "(" expression ")"
-//	| "super" "." IDENTIFIER ;
+//	| "[" arguments? "]" | "{" entries? "}" | "super" "." IDENTIFIER ;
 func (p *Parser) primary() (Expr, error) {
 	if p.match(token.FALSE) {
-		return NewLiteral(false), nil
+		return NewLiteral(false, p.previous()), nil
 	}
 	if p.match(token.TRUE) {
-		return NewLiteral(true), nil
+		return NewLiteral(true, p.previous()), nil
 	}
 	if p.match(token.NIL) {
-		return NewLiteral(nil), nil
+		return NewLiteral(nil, p.previous()), nil
 	}
 	if p.match(token.NUMBER, token.STRING) {
-		return NewLiteral(p.previous().Literal), nil
+		return NewLiteral(p.previous().Literal, p.previous()), nil
 	}
 
 	if p.match(token.LEFT_PAREN) {
@@ -609,6 +785,14 @@ func (p *Parser) primary() (Expr, error) {
 		return NewGrouping(expr), nil
 	}
 
+	if p.match(token.LEFT_BRACKET) {
+		return p.finishList()
+	}
+
+	if p.match(token.LEFT_BRACE) {
+		return p.finishMap()
+	}
+
 	if p.match(token.SUPER) {
 		keyword := p.previous()
 		_, err := p.consume(token.DOT, "Expect '.' after 'super'.")
@@ -699,6 +883,13 @@ func (p Parser) error(message string) error {
 	return errors.StaticErrorAtToken(p.peek(), message)
 }
 
+// report records a syntax error without interrupting parsing.
+// It is used directly for errors that leave the parser in a known state,
+// such as too many parameters, where no synchronization is needed.
+func (p *Parser) report(err error) {
+	p.errs = append(p.errs, err)
+}
+
 // synchronize attempts to recover from a parsing error
 // by discarding tokens until it has found a statement boundary.
 func (p *Parser) synchronize() {
@@ -710,10 +901,30 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().Type {
-		case token.CLASS, token.FUN, token.VAR, token.FOR, token.IF, token.WHILE, token.PRINT, token.RETURN:
+		case token.CLASS, token.FUN, token.VAR, token.FOR, token.IF, token.WHILE, token.PRINT, token.RETURN, token.BREAK, token.CONTINUE, token.BREAKPOINT, token.IMPORT:
 			return
 		}
 
 		p.advance()
 	}
 }
+
+// ParserError is a collection of syntax errors that occurred during parsing.
+type ParserError []error
+
+// Error implements the error interface, returning a string representation
+// of all errors in the collection.
+func (pe ParserError) Error() string {
+	var b strings.Builder
+
+	for _, err := range pe {
+		fmt.Fprintln(&b, err.Error())
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// Unwrap returns the individual syntax errors.
+func (pe ParserError) Unwrap() []error {
+	return pe
+}
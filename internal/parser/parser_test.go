@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/nt54hamnghi/golox/internal/scanner"
@@ -39,6 +41,26 @@ func TestParsingExpressionSyntacticErrors(t *testing.T) {
 			source:  "(67 +)",
 			wantErr: "[line 1] Error at ')': Expect expression.",
 		},
+		{
+			name:    "list without closing bracket",
+			source:  "[1, 2",
+			wantErr: "[line 1] Error at end: Expect ']' after list elements.",
+		},
+		{
+			name:    "index without closing bracket",
+			source:  "xs[0;",
+			wantErr: "[line 1] Error at ';': Expect ']' after index.",
+		},
+		{
+			name:    "map entry without colon",
+			source:  "{\"a\" 1}",
+			wantErr: "[line 1] Error at '1': Expect ':' after map key.",
+		},
+		{
+			name:    "map without closing brace",
+			source:  "{1: 2",
+			wantErr: "[line 1] Error at end: Expect '}' after map entries.",
+		},
 		{
 			name:    "plus token alone",
 			source:  "+",
@@ -239,3 +261,289 @@ func TestParsingExpressionsEqualityOperators(t *testing.T) {
 		})
 	}
 }
+
+func TestParsingExpressionsLists(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"empty list", "[]", "(list)"},
+		{"list of expressions", "[1, \"two\", 1 + 2]", "(list 1 two (+ 1 2))"},
+		{"nested lists", "[[1], []]", "(list (list 1) (list))"},
+		{"index", "xs[0]", "(index xs 0)"},
+		{"chained index", "[[1, 2]][0][1 + 0]", "(index (index (list (list 1 2)) 0) (+ 1 0))"},
+		{"index assignment", "xs[0] = ys[1] = 2", "(index= xs 0 (index= ys 1 2))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertParseOutput(t, tt.source, tt.want)
+		})
+	}
+}
+
+func TestParsingExpressionsMaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"empty map", "{}", "(map)"},
+		{"map entries", "{\"a\": 1, 2: 1 + 1}", "(map a 1 2 (+ 1 1))"},
+		{"nested map", "{\"m\": {true: nil}}", "(map m (map true nil))"},
+		{"index a map literal", "{\"a\": [1]}[\"a\"][0]", "(index (index (map a (list 1)) a) 0)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertParseOutput(t, tt.source, tt.want)
+		})
+	}
+}
+
+func TestParsingExpressionsCallsAndProperties(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"call without arguments", "f()", "(call f)"},
+		{"call with arguments", "f(1, a + b)(2)", "(call (call f 1 (+ a b)) 2)"},
+		{"property access", "a.b.c", "(. (. a b) c)"},
+		{"property assignment", "a.b = c.d = 1", "(.= a b (.= c d 1))"},
+		{"method call", "this.m(x)", "(call (. this m) x)"},
+		{"super method", "super.m", "(super m)"},
+		{"logical operators", "a or b and !c", "(or a (and b (! c)))"},
+		{"assignment", "a = b = 1", "(= a (= b 1))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertParseOutput(t, tt.source, tt.want)
+		})
+	}
+}
+
+func parseProgram(source string) ([]Stmt, error) {
+	scanner := scanner.NewScanner(source)
+	tokens, err := scanner.ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	parser := NewParser(tokens)
+	return parser.Parse()
+}
+
+func TestParsingProgramCollectsSyntaxErrors(t *testing.T) {
+	params := make([]string, 256)
+	for i := range params {
+		params[i] = fmt.Sprintf("p%d", i)
+	}
+
+	tests := []struct {
+		name      string
+		source    string
+		wantStmts int
+		wantErrs  []string
+	}{
+		{
+			name:      "valid program",
+			source:    "var a = 1;\nprint a;",
+			wantStmts: 2,
+		},
+		{
+			name:      "recovers at statement boundaries",
+			source:    "var = 1;\nprint 2;\nprint (3;\nprint 4;",
+			wantStmts: 2,
+			wantErrs: []string{
+				"[line 1] Error at '=': Expect variable name.",
+				"[line 3] Error at ';': Expect ')' after expression.",
+			},
+		},
+		{
+			name:      "missing semicolon after return value",
+			source:    "fun f() { return 1 }",
+			wantStmts: 0,
+			wantErrs: []string{
+				"[line 1] Error at '}': Expect ';' after return value.",
+			},
+		},
+		{
+			name:      "too many parameters does not stop parsing",
+			source:    fmt.Sprintf("fun f(%s) {}\nprint 1;", strings.Join(params, ", ")),
+			wantStmts: 2,
+			wantErrs: []string{
+				"[line 1] Error at 'p255': Can't have more than 255 parameters.",
+			},
+		},
+		{
+			name:      "too many arguments does not stop parsing",
+			source:    fmt.Sprintf("f(%s);\nprint 1;", strings.Join(params, ", ")),
+			wantStmts: 2,
+			wantErrs: []string{
+				"[line 1] Error at 'p255': Can't have more than 255 arguments.",
+			},
+		},
+		{
+			name:      "import needs a string path",
+			source:    "import util;\nprint 1;",
+			wantStmts: 1,
+			wantErrs: []string{
+				"[line 1] Error at 'util': Expect module path after 'import'.",
+			},
+		},
+		{
+			name:      "import alias must be a name",
+			source:    `import "util.lox" as "u";`,
+			wantStmts: 0,
+			wantErrs: []string{
+				`[line 1] Error at '"u"': Expect module name after 'as'.`,
+			},
+		},
+		{
+			name:      "import path must end in a valid name",
+			source:    `import "lib/class.lox"; import "2d.lox"; import "2d.lox" as geometry;`,
+			wantStmts: 1,
+			wantErrs: []string{
+				`[line 1] Error at '"lib/class.lox"': Module path doesn't end in a valid name, use 'as' to name it.`,
+				`[line 1] Error at '"2d.lox"': Module path doesn't end in a valid name, use 'as' to name it.`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			stmts, err := parseProgram(tt.source)
+
+			r.Len(stmts, tt.wantStmts)
+			if len(tt.wantErrs) == 0 {
+				r.NoError(err)
+				return
+			}
+
+			var parserErr ParserError
+			r.ErrorAs(err, &parserErr)
+			r.Len(parserErr, len(tt.wantErrs))
+			r.Equal(strings.Join(tt.wantErrs, "\n"), err.Error())
+		})
+	}
+}
+
+func TestParsingInteractiveAllowsTrailingExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{
+			name:   "expression without semicolon",
+			source: "1 + 2",
+		},
+		{
+			name:   "statements followed by an expression",
+			source: "var a = 1;\na",
+		},
+		{
+			name:    "only the last expression may omit it",
+			source:  "a\nb",
+			wantErr: "[line 2] Error at 'b': Expect ';' after value.",
+		},
+		{
+			name:    "print still needs a semicolon",
+			source:  "print 1",
+			wantErr: "[line 1] Error at end: Expect ';' after value.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			scanner := scanner.NewScanner(tt.source)
+			tokens, err := scanner.ScanTokens()
+			r.NoError(err)
+
+			parser := NewParser(tokens)
+			_, err = parser.ParseInteractive()
+			if tt.wantErr == "" {
+				r.NoError(err)
+			} else {
+				r.EqualError(err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrintingStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "expressions, prints and variables",
+			source: "1;\nprint a;\nvar b;\nvar c = 2;",
+			want:   "(expr 1)\n(print a)\n(var b)\n(var c 2)\n",
+		},
+		{
+			name:   "blocks and control flow",
+			source: "{ if (a) print 1; else { print 2; } while (b) break; breakpoint; }",
+			want:   "(block (if a (print 1) (block (print 2))) (while b (break)) (breakpoint))\n",
+		},
+		{
+			name:   "for loops print omitted clauses as ()",
+			source: "for (var i = 0; i < 3; i = i + 1) continue;\nfor (;;) {}",
+			want:   "(for (var i 0) (< i 3) (= i (+ i 1)) (continue))\n(for () () () (block))\n",
+		},
+		{
+			name:   "functions and classes",
+			source: "fun f(a, b) { return; }\nclass A < B { m() { return super.m(); } }",
+			want:   "(fun f (a b) (return))\n(class A (< B) (fun m () (return (call (super m)))))\n",
+		},
+		{
+			name:   "imports",
+			source: "import \"m\";\nimport \"lib/n\" as n;",
+			want:   "(import \"m\")\n(import \"lib/n\" as n)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			prog, err := parseProgram(tt.source)
+			r.NoError(err)
+			r.Equal(tt.want, AstPrinter{}.Program(prog))
+		})
+	}
+}
+
+func TestPrintingJSON(t *testing.T) {
+	r := require.New(t)
+
+	prog, err := parseProgram("class A < B { m(x) { this.y = -x; } }\nif (a or nil) print \"s\"[0]; else f(true);\nimport \"m\";")
+	r.NoError(err)
+
+	got, err := JSONPrinter{}.Program(prog)
+	r.NoError(err)
+	r.JSONEq(`[
+		{"type": "Class", "name": "A", "superclass": {"type": "Variable", "name": "B"}, "methods": [
+			{"type": "Function", "name": "m", "params": ["x"], "body": [
+				{"type": "Expression", "expression": {"type": "Set", "object": {"type": "This"}, "name": "y",
+					"value": {"type": "Unary", "operator": "-", "right": {"type": "Variable", "name": "x"}}}}
+			]}
+		]},
+		{"type": "If",
+			"condition": {"type": "Logical", "left": {"type": "Variable", "name": "a"}, "operator": "or", "right": {"type": "Literal", "value": null}},
+			"thenBranch": {"type": "Print", "expression": {"type": "Index", "object": {"type": "Literal", "value": "s"}, "index": {"type": "Literal", "value": 0}}},
+			"elseBranch": {"type": "Expression", "expression": {"type": "Call", "callee": {"type": "Variable", "name": "f"}, "arguments": [{"type": "Literal", "value": true}]}}},
+		{"type": "Import", "path": "m", "alias": null}
+	]`, got)
+
+	// fields keep their declaration order, with the type first
+	r.True(strings.HasPrefix(got, "[\n  {\n    \"type\": \"Class\",\n    \"name\": \"A\",\n    \"superclass\""))
+}
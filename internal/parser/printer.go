@@ -7,6 +7,8 @@ import (
 	"github.com/nt54hamnghi/golox/internal/scanner/token"
 )
 
+// AstPrinter prints syntax trees as S-expressions, with the operator or
+// node kind first, for debugging the parser.
 type AstPrinter struct{}
 
 func (p AstPrinter) String(expr Expr) string {
@@ -18,39 +20,195 @@ func (p AstPrinter) String(expr Expr) string {
 	}
 }
 
+// StmtString returns the S-expression of stmt, on a single line.
+func (p AstPrinter) StmtString(stmt Stmt) string {
+	repr, _ := stmt.Accept(p)
+	if v, ok := repr.(string); ok {
+		return v
+	} else {
+		panic("AstPrinter: expected string result from stmt.Accept")
+	}
+}
+
+// Program returns the S-expressions of prog, one statement per line.
+func (p AstPrinter) Program(prog []Stmt) string {
+	var b strings.Builder
+	for _, stmt := range prog {
+		b.WriteString(p.StmtString(stmt) + "\n")
+	}
+	return b.String()
+}
+
+// VisitExpressionStmt implements [StmtVisitor].
+func (p AstPrinter) VisitExpressionStmt(stmt Expression) (any, error) {
+	return p.parenthesize("expr", stmt.Expression)
+}
+
+// VisitPrintStmt implements [StmtVisitor].
+func (p AstPrinter) VisitPrintStmt(stmt Print) (any, error) {
+	return p.parenthesize("print", stmt.Expression)
+}
+
+// VisitVarStmt implements [StmtVisitor].
+func (p AstPrinter) VisitVarStmt(stmt Var) (any, error) {
+	if stmt.Initializer == nil {
+		return p.form("var", stmt.Name.Lexeme), nil
+	}
+	return p.form("var", stmt.Name.Lexeme, p.String(stmt.Initializer)), nil
+}
+
+// VisitClassStmt implements [StmtVisitor].
+func (p AstPrinter) VisitClassStmt(stmt Class) (any, error) {
+	parts := []string{stmt.Name.Lexeme}
+	if stmt.Superclass != nil {
+		parts = append(parts, p.form("<", stmt.Superclass.Name.Lexeme))
+	}
+	for _, method := range stmt.Methods {
+		parts = append(parts, p.StmtString(method))
+	}
+	return p.form("class", parts...), nil
+}
+
+// VisitFunctionStmt implements [StmtVisitor].
+func (p AstPrinter) VisitFunctionStmt(stmt Function) (any, error) {
+	params := make([]string, len(stmt.Params))
+	for i, param := range stmt.Params {
+		params[i] = param.Lexeme
+	}
+	parts := []string{stmt.Name.Lexeme, "(" + strings.Join(params, " ") + ")"}
+	for _, s := range stmt.Body {
+		parts = append(parts, p.StmtString(s))
+	}
+	return p.form("fun", parts...), nil
+}
+
+// VisitIfStmt implements [StmtVisitor].
+func (p AstPrinter) VisitIfStmt(stmt If) (any, error) {
+	parts := []string{p.String(stmt.Condition), p.StmtString(stmt.ThenBranch)}
+	if stmt.ElseBranch != nil {
+		parts = append(parts, p.StmtString(stmt.ElseBranch))
+	}
+	return p.form("if", parts...), nil
+}
+
+// VisitWhileStmt implements [StmtVisitor].
+func (p AstPrinter) VisitWhileStmt(stmt While) (any, error) {
+	return p.form("while", p.String(stmt.Condition), p.StmtString(stmt.Body)), nil
+}
+
+// VisitForStmt implements [StmtVisitor].
+// Omitted clauses are printed as ().
+func (p AstPrinter) VisitForStmt(stmt For) (any, error) {
+	parts := []string{"()", "()", "()", p.StmtString(stmt.Body)}
+	if stmt.Initializer != nil {
+		parts[0] = p.StmtString(stmt.Initializer)
+	}
+	if stmt.Condition != nil {
+		parts[1] = p.String(stmt.Condition)
+	}
+	if stmt.Increment != nil {
+		parts[2] = p.String(stmt.Increment)
+	}
+	return p.form("for", parts...), nil
+}
+
+// VisitBreakStmt implements [StmtVisitor].
+func (p AstPrinter) VisitBreakStmt(stmt Break) (any, error) {
+	return p.form("break"), nil
+}
+
+// VisitContinueStmt implements [StmtVisitor].
+func (p AstPrinter) VisitContinueStmt(stmt Continue) (any, error) {
+	return p.form("continue"), nil
+}
+
+// VisitBreakpointStmt implements [StmtVisitor].
+func (p AstPrinter) VisitBreakpointStmt(stmt Breakpoint) (any, error) {
+	return p.form("breakpoint"), nil
+}
+
+// VisitImportStmt implements [StmtVisitor].
+func (p AstPrinter) VisitImportStmt(stmt Import) (any, error) {
+	if stmt.Alias == nil {
+		return p.form("import", stmt.Path.Lexeme), nil
+	}
+	return p.form("import", stmt.Path.Lexeme, "as", stmt.Alias.Lexeme), nil
+}
+
+// VisitReturnStmt implements [StmtVisitor].
+func (p AstPrinter) VisitReturnStmt(stmt Return) (any, error) {
+	if stmt.Value == nil {
+		return p.form("return"), nil
+	}
+	return p.parenthesize("return", stmt.Value)
+}
+
+// VisitBlockStmt implements [StmtVisitor].
+func (p AstPrinter) VisitBlockStmt(stmt Block) (any, error) {
+	parts := make([]string, len(stmt.Stmts))
+	for i, s := range stmt.Stmts {
+		parts[i] = p.StmtString(s)
+	}
+	return p.form("block", parts...), nil
+}
+
 // VisitCallExpr implements [ExprVisitor].
 func (p AstPrinter) VisitCallExpr(expr Call) (any, error) {
-	panic("unimplemented")
+	return p.parenthesize("call", append([]Expr{expr.Callee}, expr.Arguments...)...)
 }
 
 // VisitGetExpr implements [ExprVisitor].
 func (p AstPrinter) VisitGetExpr(expr Get) (any, error) {
-	panic("unimplemented")
+	return p.form(".", p.String(expr.Object), expr.Name.Lexeme), nil
 }
 
 // VisitSetExpr implements [ExprVisitor].
 func (p AstPrinter) VisitSetExpr(expr Set) (any, error) {
-	panic("unimplemented")
+	return p.form(".=", p.String(expr.Object), expr.Name.Lexeme, p.String(expr.Value)), nil
 }
 
 // VisitSuperExpr implements [ExprVisitor].
 func (p AstPrinter) VisitSuperExpr(expr Super) (any, error) {
-	panic("unimplemented")
+	return p.form("super", expr.Method.Lexeme), nil
 }
 
 // VisitThisExpr implements [ExprVisitor].
 func (p AstPrinter) VisitThisExpr(expr This) (any, error) {
-	panic("unimplemented")
+	return "this", nil
 }
 
 // VisitLogicalExpr implements [ExprVisitor].
 func (p AstPrinter) VisitLogicalExpr(expr Logical) (any, error) {
-	panic("unimplemented")
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
 }
 
 // VisitAssignmentExpr implements [ExprVisitor].
 func (p AstPrinter) VisitAssignmentExpr(expr Assignment) (any, error) {
-	panic("unimplemented")
+	return p.form("=", expr.Name.Lexeme, p.String(expr.Value)), nil
+}
+
+// VisitListExpr implements [ExprVisitor].
+func (p AstPrinter) VisitListExpr(expr List) (any, error) {
+	return p.parenthesize("list", expr.Elements...)
+}
+
+// VisitMapExpr implements [ExprVisitor].
+func (p AstPrinter) VisitMapExpr(expr Map) (any, error) {
+	entries := make([]Expr, 0, 2*len(expr.Keys))
+	for n, key := range expr.Keys {
+		entries = append(entries, key, expr.Values[n])
+	}
+	return p.parenthesize("map", entries...)
+}
+
+// VisitIndexExpr implements [ExprVisitor].
+func (p AstPrinter) VisitIndexExpr(expr Index) (any, error) {
+	return p.parenthesize("index", expr.Object, expr.Index)
+}
+
+// VisitIndexSetExpr implements [ExprVisitor].
+func (p AstPrinter) VisitIndexSetExpr(expr IndexSet) (any, error) {
+	return p.parenthesize("index=", expr.Object, expr.Index, expr.Value)
 }
 
 // VisitVariableExpr implements [ExprVisitor].
@@ -93,15 +251,23 @@ func (p AstPrinter) parenthesize(name string, expr ...Expr) (any, error) {
 	return b.String(), nil
 }
 
+// form is like parenthesize for parts that are already printed.
+func (p AstPrinter) form(name string, parts ...string) string {
+	if len(parts) == 0 {
+		return "(" + name + ")"
+	}
+	return "(" + name + " " + strings.Join(parts, " ") + ")"
+}
+
 func printExample() {
 	var printer AstPrinter
 	expr := NewBinary(
 		NewUnary(
 			token.NewToken(token.MINUS, "-", nil, 0),
-			NewLiteral(123),
+			NewLiteral(123, token.NewToken(token.NUMBER, "123", 123, 0)),
 		),
 		token.NewToken(token.STAR, "*", nil, 0),
-		NewGrouping(NewLiteral(45.67)),
+		NewGrouping(NewLiteral(45.67, token.NewToken(token.NUMBER, "45.67", 45.67, 0))),
 	)
 	repr := printer.String(expr)
 	fmt.Println(repr)
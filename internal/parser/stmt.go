@@ -20,6 +20,11 @@ func init() {
 	gob.Register(Function{})
 	gob.Register(If{})
 	gob.Register(While{})
+	gob.Register(For{})
+	gob.Register(Break{})
+	gob.Register(Continue{})
+	gob.Register(Breakpoint{})
+	gob.Register(Import{})
 	gob.Register(Return{})
 	gob.Register(Block{})
 }
@@ -32,6 +37,11 @@ type StmtVisitor interface {
 	VisitFunctionStmt(stmt Function) (any, error)
 	VisitIfStmt(stmt If) (any, error)
 	VisitWhileStmt(stmt While) (any, error)
+	VisitForStmt(stmt For) (any, error)
+	VisitBreakStmt(stmt Break) (any, error)
+	VisitContinueStmt(stmt Continue) (any, error)
+	VisitBreakpointStmt(stmt Breakpoint) (any, error)
+	VisitImportStmt(stmt Import) (any, error)
 	VisitReturnStmt(stmt Return) (any, error)
 	VisitBlockStmt(stmt Block) (any, error)
 }
@@ -243,21 +253,24 @@ func (self If) Id() NodeID {
 }
 
 type While struct {
+	Keyword   token.Token
 	Condition Expr
 	Body      Stmt
 	id        NodeID
 }
 
-func NewWhile(condition Expr, body Stmt) While {
+func NewWhile(keyword token.Token, condition Expr, body Stmt) While {
 	node := While{
+		Keyword:   keyword,
 		Condition: condition,
 		Body:      body,
 	}
 
 	tmp := struct {
+		Keyword   token.Token
 		Condition Expr
 		Body      Stmt
-	}{Condition: node.Condition, Body: node.Body}
+	}{Keyword: node.Keyword, Condition: node.Condition, Body: node.Body}
 	node.id = NewNodeIDFrom(tmp)
 	return node
 }
@@ -268,9 +281,177 @@ func (self While) Accept(visitor StmtVisitor) (any, error) {
 
 func (self While) Id() NodeID {
 	tmp := struct {
+		Keyword   token.Token
 		Condition Expr
 		Body      Stmt
-	}{Condition: self.Condition, Body: self.Body}
+	}{Keyword: self.Keyword, Condition: self.Condition, Body: self.Body}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type For struct {
+	Keyword     token.Token
+	Initializer Stmt
+	Condition   Expr
+	Increment   Expr
+	Body        Stmt
+	id          NodeID
+}
+
+func NewFor(keyword token.Token, initializer Stmt, condition Expr, increment Expr, body Stmt) For {
+	node := For{
+		Keyword:     keyword,
+		Initializer: initializer,
+		Condition:   condition,
+		Increment:   increment,
+		Body:        body,
+	}
+
+	tmp := struct {
+		Keyword     token.Token
+		Initializer Stmt
+		Condition   Expr
+		Increment   Expr
+		Body        Stmt
+	}{Keyword: node.Keyword, Initializer: node.Initializer, Condition: node.Condition, Increment: node.Increment, Body: node.Body}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self For) Accept(visitor StmtVisitor) (any, error) {
+	return visitor.VisitForStmt(self)
+}
+
+func (self For) Id() NodeID {
+	tmp := struct {
+		Keyword     token.Token
+		Initializer Stmt
+		Condition   Expr
+		Increment   Expr
+		Body        Stmt
+	}{Keyword: self.Keyword, Initializer: self.Initializer, Condition: self.Condition, Increment: self.Increment, Body: self.Body}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type Break struct {
+	Keyword token.Token
+	id      NodeID
+}
+
+func NewBreak(keyword token.Token) Break {
+	node := Break{
+		Keyword: keyword,
+	}
+
+	tmp := struct{ Keyword token.Token }{Keyword: node.Keyword}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self Break) Accept(visitor StmtVisitor) (any, error) {
+	return visitor.VisitBreakStmt(self)
+}
+
+func (self Break) Id() NodeID {
+	tmp := struct{ Keyword token.Token }{Keyword: self.Keyword}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type Continue struct {
+	Keyword token.Token
+	id      NodeID
+}
+
+func NewContinue(keyword token.Token) Continue {
+	node := Continue{
+		Keyword: keyword,
+	}
+
+	tmp := struct{ Keyword token.Token }{Keyword: node.Keyword}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self Continue) Accept(visitor StmtVisitor) (any, error) {
+	return visitor.VisitContinueStmt(self)
+}
+
+func (self Continue) Id() NodeID {
+	tmp := struct{ Keyword token.Token }{Keyword: self.Keyword}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type Breakpoint struct {
+	Keyword token.Token
+	id      NodeID
+}
+
+func NewBreakpoint(keyword token.Token) Breakpoint {
+	node := Breakpoint{
+		Keyword: keyword,
+	}
+
+	tmp := struct{ Keyword token.Token }{Keyword: node.Keyword}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self Breakpoint) Accept(visitor StmtVisitor) (any, error) {
+	return visitor.VisitBreakpointStmt(self)
+}
+
+func (self Breakpoint) Id() NodeID {
+	tmp := struct{ Keyword token.Token }{Keyword: self.Keyword}
+	if nodeDigest(self.id.id, tmp) != self.id.digest {
+		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
+	}
+	return self.id
+}
+
+type Import struct {
+	Keyword token.Token
+	Path    token.Token
+	Alias   *token.Token
+	id      NodeID
+}
+
+func NewImport(keyword token.Token, path token.Token, alias *token.Token) Import {
+	node := Import{
+		Keyword: keyword,
+		Path:    path,
+		Alias:   alias,
+	}
+
+	tmp := struct {
+		Keyword token.Token
+		Path    token.Token
+		Alias   *token.Token
+	}{Keyword: node.Keyword, Path: node.Path, Alias: node.Alias}
+	node.id = NewNodeIDFrom(tmp)
+	return node
+}
+
+func (self Import) Accept(visitor StmtVisitor) (any, error) {
+	return visitor.VisitImportStmt(self)
+}
+
+func (self Import) Id() NodeID {
+	tmp := struct {
+		Keyword token.Token
+		Path    token.Token
+		Alias   *token.Token
+	}{Keyword: self.Keyword, Path: self.Path, Alias: self.Alias}
 	if nodeDigest(self.id.id, tmp) != self.id.digest {
 		panic(fmt.Sprintf("node id hash mismatch, a copied value was modified: %#v", self))
 	}
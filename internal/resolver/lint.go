@@ -0,0 +1,398 @@
+package resolver
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+	"github.com/nt54hamnghi/golox/pkg/stack"
+)
+
+// Rules checked by [Resolver.Lint].
+const (
+	UnusedVariable  = "unused-variable"
+	UnusedParameter = "unused-parameter"
+	Shadowing       = "shadowing"
+	UnreachableCode = "unreachable-code"
+	SelfAssignment  = "self-assignment"
+	ThisInFunction  = "this-in-function"
+	ArgumentCount   = "argument-count"
+)
+
+const (
+	// Arity of bindings that aren't known to hold a function or class.
+	unknownArity = -1
+	// Variables and parameters whose name starts with this are meant to be unused.
+	unusedNamePrefix = "_"
+)
+
+// Warning is a problem found by [Resolver.Lint]: code that is valid Lox,
+// but probably not what was meant.
+type Warning struct {
+	// Rule names the check that found the problem, such as [UnusedVariable].
+	Rule    string
+	Token   token.Token
+	Message string
+}
+
+// SymbolKind tells what declared a [Symbol].
+type SymbolKind int
+
+const (
+	VariableSymbol SymbolKind = iota
+	ParameterSymbol
+	FunctionSymbol
+	ClassSymbol
+	ImportSymbol
+)
+
+func (k SymbolKind) String() string {
+	return [...]string{"variable", "parameter", "function", "class", "import"}[k]
+}
+
+// Symbol is a name declared by a program, along with every place that
+// refers to it.
+type Symbol struct {
+	Name token.Token
+	Kind SymbolKind
+	// Whether the name is declared in top-level code.
+	Global bool
+	// Number of arguments the declared function or class takes,
+	// -1 for other symbols and classes that inherit their initializer.
+	Arity int
+	// The variable expressions, assignments and later declarations of the
+	// same global referring to the symbol, not necessarily in source order.
+	References []token.Token
+}
+
+// Analysis is what [Resolver.Analyze] learns about a program.
+type Analysis struct {
+	// Warnings sorted by position.
+	Warnings []Warning
+	// Symbols in the order of their declarations.
+	Symbols []*Symbol
+}
+
+// binding is a name declared in the program being linted.
+type binding struct {
+	*Symbol
+	// Whether the value of the binding is ever read.
+	used bool
+	// Whether the binding is assigned or declared again after its declaration.
+	reassigned bool
+	// Number of arguments a call to the declared function or class takes,
+	// unknownArity when it isn't known statically.
+	arity int
+	// Whether the binding only stands for a global that is assigned
+	// before any declaration of it.
+	undeclared bool
+}
+
+// call is a call whose callee is a variable, checked once the whole program
+// has been resolved, when it is known whether the variable is reassigned.
+type call struct {
+	expr   parser.Call
+	callee token.Token
+	// The local binding of the callee, nil if it is global.
+	binding *binding
+}
+
+// linter collects warnings and symbols while the resolver walks a program.
+// Its scopes mirror the ones of the resolver, with the bindings declared in each.
+// The methods the resolver calls do nothing on a nil linter, which is
+// what the resolver holds when it isn't linting.
+type linter struct {
+	warnings []Warning
+	scopes   stack.Stack[map[string]*binding]
+	globals  map[string]*binding
+	calls    []call
+	symbols  []*Symbol
+	// Global variables used before any declaration of them.
+	pending []token.Token
+}
+
+func newLinter() *linter {
+	return &linter{
+		scopes:  stack.NewStack[map[string]*binding](),
+		globals: make(map[string]*binding),
+	}
+}
+
+// Lint resolves stmts like [Resolver.Resolve] does and also returns the
+// warnings found along the way, sorted by position. Warnings are only
+// returned for programs that resolve without errors.
+func (r *Resolver) Lint(stmts []parser.Stmt) ([]Warning, error) {
+	analysis, err := r.Analyze(stmts)
+	return analysis.Warnings, err
+}
+
+// Analyze resolves stmts like [Resolver.Lint] does and also returns the
+// symbols the program declares, with the references to each.
+func (r *Resolver) Analyze(stmts []parser.Stmt) (Analysis, error) {
+	r.lint = newLinter()
+	defer func() { r.lint = nil }()
+
+	if _, err := r.Resolve(stmts); err != nil {
+		return Analysis{}, err
+	}
+	r.lint.checkCalls()
+	for _, name := range r.lint.pending {
+		if b, ok := r.lint.globals[name.Lexeme]; ok && !b.undeclared {
+			b.References = append(b.References, name)
+		}
+	}
+
+	warnings := r.lint.warnings
+	slices.SortStableFunc(warnings, func(a, b Warning) int {
+		return cmp.Or(cmp.Compare(a.Token.Line, b.Token.Line), cmp.Compare(a.Token.Column, b.Token.Column))
+	})
+	return Analysis{warnings, r.lint.symbols}, nil
+}
+
+func (l *linter) warn(rule string, tok token.Token, format string, args ...any) {
+	if l == nil {
+		return
+	}
+	l.warnings = append(l.warnings, Warning{rule, tok, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) beginScope() {
+	if l == nil {
+		return
+	}
+	l.scopes.Push(make(map[string]*binding))
+}
+
+// endScope reports the bindings of the innermost scope that are never read.
+func (l *linter) endScope() {
+	if l == nil {
+		return
+	}
+	s, _ := l.scopes.Pop()
+	for _, b := range s {
+		if b.used || strings.HasPrefix(b.Name.Lexeme, unusedNamePrefix) {
+			continue
+		}
+		switch b.Kind {
+		case ParameterSymbol:
+			l.warn(UnusedParameter, b.Name, "Parameter '%s' is never used.", b.Name.Lexeme)
+		case FunctionSymbol:
+			l.warn(UnusedVariable, b.Name, "Local function '%s' is never used.", b.Name.Lexeme)
+		case ClassSymbol:
+			l.warn(UnusedVariable, b.Name, "Local class '%s' is never used.", b.Name.Lexeme)
+		default:
+			l.warn(UnusedVariable, b.Name, "Local variable '%s' is never used.", b.Name.Lexeme)
+		}
+	}
+}
+
+// declare records a binding in the innermost scope, or as a global in
+// top-level code, and reports it if it hides a binding of an outer scope.
+func (l *linter) declare(name token.Token, kind SymbolKind, arity int) {
+	if l == nil {
+		return
+	}
+	current, exist := l.scopes.Peek()
+	symbol := &Symbol{Name: name, Kind: kind, Global: !exist, Arity: arity}
+	b := &binding{Symbol: symbol, arity: arity}
+
+	if !exist {
+		if previous, ok := l.globals[name.Lexeme]; ok && !previous.undeclared {
+			// the binding is replaced when its declaration runs
+			previous.reassigned = true
+			previous.arity = unknownArity
+			previous.References = append(previous.References, name)
+			return
+		} else if ok {
+			b.reassigned = true
+			b.arity = unknownArity
+		}
+		l.globals[name.Lexeme] = b
+		l.symbols = append(l.symbols, symbol)
+		return
+	}
+
+	if outer := l.lookup(name.Lexeme); outer != nil && !outer.undeclared {
+		l.warn(Shadowing, name, "'%s' shadows the declaration on line %d.", name.Lexeme, outer.Name.Line)
+	}
+	current[name.Lexeme] = b
+	l.symbols = append(l.symbols, symbol)
+}
+
+// lookup returns the binding name refers to at this point of the program,
+// nil if it is a global that hasn't been declared or assigned yet.
+func (l *linter) lookup(name string) *binding {
+	for _, s := range l.scopes.All() {
+		if b, ok := s[name]; ok {
+			return b
+		}
+	}
+	return l.globals[name]
+}
+
+// refer records that name refers to the variable it names.
+// References to globals that aren't declared yet are resolved at the end.
+func (l *linter) refer(name token.Token) *binding {
+	b := l.lookup(name.Lexeme)
+	if b == nil || b.undeclared {
+		l.pending = append(l.pending, name)
+		return b
+	}
+	b.References = append(b.References, name)
+	return b
+}
+
+// use records that the value of the variable called name is read.
+func (l *linter) use(name token.Token) {
+	if l == nil {
+		return
+	}
+	if b := l.refer(name); b != nil {
+		b.used = true
+	}
+}
+
+// assign records an assignment to the variable called name.
+func (l *linter) assign(name token.Token) {
+	if l == nil {
+		return
+	}
+	if b := l.refer(name); b != nil {
+		b.reassigned = true
+		return
+	}
+	// assigning an undeclared global is an error at runtime,
+	// unless the global is declared before the assignment runs
+	l.globals[name.Lexeme] = &binding{
+		Symbol:     &Symbol{Name: name, Kind: VariableSymbol, Global: true, Arity: unknownArity},
+		reassigned: true,
+		arity:      unknownArity,
+		undeclared: true,
+	}
+}
+
+// unreachable reports the statement following one that always jumps away.
+func (l *linter) unreachable(stmts []parser.Stmt) {
+	if l == nil {
+		return
+	}
+	if len(stmts) < 2 {
+		return
+	}
+	for _, stmt := range stmts[:len(stmts)-1] {
+		var keyword token.Token
+		switch s := stmt.(type) {
+		case parser.Return:
+			keyword = s.Keyword
+		case parser.Break:
+			keyword = s.Keyword
+		case parser.Continue:
+			keyword = s.Keyword
+		default:
+			continue
+		}
+		l.warn(UnreachableCode, keyword, "Code after '%s' is unreachable.", keyword.Lexeme)
+		return
+	}
+}
+
+// thisInFunction reports a use of this in a function nested in a method,
+// where it refers to the instance of the method, not to the function.
+func (l *linter) thisInFunction(keyword token.Token) {
+	l.warn(ThisInFunction, keyword, "'this' in a function refers to the instance of the enclosing method.")
+}
+
+// selfAssignment reports assignments of a variable or a property to itself.
+func (l *linter) selfAssignment(name token.Token, object parser.Expr, value parser.Expr) {
+	if l == nil {
+		return
+	}
+	switch v := value.(type) {
+	case parser.Variable:
+		if object == nil && v.Name.Lexeme == name.Lexeme {
+			l.warn(SelfAssignment, name, "'%s' is assigned to itself.", name.Lexeme)
+		}
+	case parser.Get:
+		if object != nil && v.Name.Lexeme == name.Lexeme && sameReceiver(object, v.Object) {
+			l.warn(SelfAssignment, name, "Property '%s' is assigned to itself.", name.Lexeme)
+		}
+	}
+}
+
+// sameReceiver reports whether a and b are both this or the same variable.
+func sameReceiver(a, b parser.Expr) bool {
+	switch a := a.(type) {
+	case parser.This:
+		_, ok := b.(parser.This)
+		return ok
+	case parser.Variable:
+		b, ok := b.(parser.Variable)
+		return ok && a.Name.Lexeme == b.Name.Lexeme
+	}
+	return false
+}
+
+// call records a call whose callee may be a function or class declared
+// in the program, to check its argument count later.
+func (l *linter) call(expr parser.Call) {
+	if l == nil {
+		return
+	}
+	callee, ok := expr.Callee.(parser.Variable)
+	if !ok {
+		return
+	}
+	c := call{expr: expr, callee: callee.Name}
+	for _, s := range l.scopes.All() {
+		if b, ok := s[callee.Name.Lexeme]; ok {
+			c.binding = b
+			break
+		}
+	}
+	l.calls = append(l.calls, c)
+}
+
+// checkCalls reports calls to functions and classes that are never
+// reassigned with a number of arguments they don't take.
+func (l *linter) checkCalls() {
+	for _, c := range l.calls {
+		b := c.binding
+		if b == nil {
+			b = l.globals[c.callee.Lexeme]
+		}
+		if b == nil || b.reassigned || b.arity == unknownArity {
+			continue
+		}
+		if got := len(c.expr.Arguments); got != b.arity {
+			l.warn(
+				ArgumentCount, c.callee,
+				"'%s' takes %d %s but is called with %d.",
+				c.callee.Lexeme, b.arity, plural(b.arity, "argument"), got,
+			)
+		}
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// classArity returns the number of arguments taken by the constructor of class.
+func classArity(class parser.Class) int {
+	for _, method := range class.Methods {
+		if method.Name.Lexeme == "init" {
+			return len(method.Params)
+		}
+	}
+	if class.Superclass != nil {
+		// the initializer may be inherited
+		return unknownArity
+	}
+	return 0
+}
@@ -2,7 +2,6 @@ package resolver
 
 import (
 	"github.com/nt54hamnghi/golox/internal/errors"
-	"github.com/nt54hamnghi/golox/internal/interpreter"
 	"github.com/nt54hamnghi/golox/internal/parser"
 	"github.com/nt54hamnghi/golox/internal/scanner/token"
 	"github.com/nt54hamnghi/golox/pkg/stack"
@@ -34,17 +33,31 @@ func (f funType) String() string {
 // of a variable has been resolved.
 type scope = map[string]bool
 
+// Interpreter is told where the variable used by each expression is declared.
+// Both the tree-walking interpreter and the bytecode VM implement it.
+type Interpreter interface {
+	// Resolve records that expr uses a local variable declared depth
+	// scopes out from the innermost one. Variables that are never
+	// resolved are global.
+	Resolve(expr parser.Expr, depth int)
+}
+
 type Resolver struct {
-	interpreter *interpreter.Interpreter
+	interpreter Interpreter
 	// A stack of scopes, representing nesting lexical scopes.
 	// The innermost scope is at the top of the stack, and the
 	// outermost scope is at the bottom.
 	scopes           stack.Stack[scope]
 	currentFunType   funType
 	currentClassType classType
+	// Number of loops enclosing the code being resolved,
+	// counted from the innermost function body.
+	loopDepth int
+	// Collects warnings while linting, nil otherwise.
+	lint *linter
 }
 
-func NewResolver(interpreter *interpreter.Interpreter) Resolver {
+func NewResolver(interpreter Interpreter) Resolver {
 	return Resolver{
 		interpreter:      interpreter,
 		scopes:           stack.NewStack[scope](),
@@ -67,6 +80,7 @@ func (r *Resolver) Resolve(stmts []parser.Stmt) (any, error) {
 			return nil, err
 		}
 	}
+	r.lint.unreachable(stmts)
 	return nil, nil
 }
 
@@ -74,11 +88,13 @@ func (r *Resolver) Resolve(stmts []parser.Stmt) (any, error) {
 func (r *Resolver) beginScope() {
 	s := make(scope)
 	r.scopes.Push(s)
+	r.lint.beginScope()
 }
 
 // endScope exits the current scope by popping it from the stack.
 func (r *Resolver) endScope() {
 	r.scopes.Pop()
+	r.lint.endScope()
 }
 
 // VisitBlockStmt implements [StmtVisitor].
@@ -97,6 +113,11 @@ func (r *Resolver) VisitClassStmt(stmt parser.Class) (any, error) {
 	r.currentClassType = KLASS
 	defer func() { r.currentClassType = enclosingClassType }()
 
+	// the class is bound outside the scope holding super
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	r.lint.declare(stmt.Name, ClassSymbol, classArity(stmt))
+
 	if stmt.Superclass != nil {
 		r.currentClassType = SUBCLASS
 
@@ -119,9 +140,6 @@ func (r *Resolver) VisitClassStmt(stmt parser.Class) (any, error) {
 		s["super"] = true
 	}
 
-	r.declare(stmt.Name)
-	r.define(stmt.Name)
-
 	r.beginScope()
 	defer r.endScope()
 
@@ -152,6 +170,7 @@ func (r *Resolver) VisitVarStmt(stmt parser.Var) (any, error) {
 		}
 	}
 	r.define(stmt.Name)
+	r.lint.declare(stmt.Name, VariableSymbol, unknownArity)
 	return nil, nil
 }
 
@@ -186,6 +205,7 @@ func (r *Resolver) VisitFunctionStmt(stmt parser.Function) (any, error) {
 		return nil, err
 	}
 	r.define(stmt.Name)
+	r.lint.declare(stmt.Name, FunctionSymbol, len(stmt.Params))
 	if _, err := r.resolveFunction(stmt, FUNCTION); err != nil {
 		return nil, err
 	}
@@ -197,6 +217,11 @@ func (r *Resolver) resolveFunction(fun parser.Function, funT funType) (any, erro
 	r.currentFunType = funT
 	defer func() { r.currentFunType = enclosingFunType }()
 
+	// a loop outside the function can't be left from inside it
+	enclosingLoopDepth := r.loopDepth
+	r.loopDepth = 0
+	defer func() { r.loopDepth = enclosingLoopDepth }()
+
 	r.beginScope()
 	defer r.endScope()
 	for _, param := range fun.Params {
@@ -204,6 +229,7 @@ func (r *Resolver) resolveFunction(fun parser.Function, funT funType) (any, erro
 			return nil, err
 		}
 		r.define(param)
+		r.lint.declare(param, ParameterSymbol, unknownArity)
 	}
 	if _, err := r.Resolve(fun.Body); err != nil {
 		return nil, err
@@ -251,7 +277,120 @@ func (r *Resolver) VisitWhileStmt(stmt parser.While) (any, error) {
 	if _, err := r.resolveExpr(stmt.Condition); err != nil {
 		return nil, err
 	}
-	if _, err := r.resolveStmt(stmt.Body); err != nil {
+	if _, err := r.resolveLoopBody(stmt.Body); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// VisitForStmt implements [StmtVisitor].
+func (r *Resolver) VisitForStmt(stmt parser.For) (any, error) {
+	r.beginScope()
+	defer r.endScope()
+
+	if stmt.Initializer != nil {
+		if _, err := r.resolveStmt(stmt.Initializer); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.Condition != nil {
+		if _, err := r.resolveExpr(stmt.Condition); err != nil {
+			return nil, err
+		}
+	}
+	if stmt.Increment != nil {
+		if _, err := r.resolveExpr(stmt.Increment); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := r.resolveLoopBody(stmt.Body); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// resolveLoopBody resolves the body of a loop, where break and continue are allowed.
+func (r *Resolver) resolveLoopBody(body parser.Stmt) (any, error) {
+	r.loopDepth++
+	defer func() { r.loopDepth-- }()
+	return r.resolveStmt(body)
+}
+
+// VisitImportStmt implements [StmtVisitor].
+func (r *Resolver) VisitImportStmt(stmt parser.Import) (any, error) {
+	// modules are linked before the program runs, which only
+	// works for imports that are executed exactly once
+	if !r.scopes.IsEmpty() {
+		return nil, errors.StaticErrorAtToken(stmt.Keyword, "Can't import outside of top-level code.")
+	}
+	r.lint.declare(stmt.Name(), ImportSymbol, unknownArity)
+	return nil, nil
+}
+
+// VisitBreakStmt implements [StmtVisitor].
+func (r *Resolver) VisitBreakStmt(stmt parser.Break) (any, error) {
+	if r.loopDepth == 0 {
+		return nil, errors.StaticErrorAtToken(stmt.Keyword, "Can't use 'break' outside of a loop.")
+	}
+	return nil, nil
+}
+
+// VisitContinueStmt implements [StmtVisitor].
+func (r *Resolver) VisitContinueStmt(stmt parser.Continue) (any, error) {
+	if r.loopDepth == 0 {
+		return nil, errors.StaticErrorAtToken(stmt.Keyword, "Can't use 'continue' outside of a loop.")
+	}
+	return nil, nil
+}
+
+// VisitBreakpointStmt implements [StmtVisitor].
+func (r *Resolver) VisitBreakpointStmt(stmt parser.Breakpoint) (any, error) {
+	return nil, nil
+}
+
+// VisitListExpr implements [ExprVisitor].
+func (r *Resolver) VisitListExpr(expr parser.List) (any, error) {
+	for _, e := range expr.Elements {
+		if _, err := r.resolveExpr(e); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// VisitMapExpr implements [ExprVisitor].
+func (r *Resolver) VisitMapExpr(expr parser.Map) (any, error) {
+	for n, key := range expr.Keys {
+		if _, err := r.resolveExpr(key); err != nil {
+			return nil, err
+		}
+		if _, err := r.resolveExpr(expr.Values[n]); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// VisitIndexExpr implements [ExprVisitor].
+func (r *Resolver) VisitIndexExpr(expr parser.Index) (any, error) {
+	if _, err := r.resolveExpr(expr.Object); err != nil {
+		return nil, err
+	}
+	if _, err := r.resolveExpr(expr.Index); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// VisitIndexSetExpr implements [ExprVisitor].
+func (r *Resolver) VisitIndexSetExpr(expr parser.IndexSet) (any, error) {
+	if _, err := r.resolveExpr(expr.Object); err != nil {
+		return nil, err
+	}
+	if _, err := r.resolveExpr(expr.Index); err != nil {
+		return nil, err
+	}
+	if _, err := r.resolveExpr(expr.Value); err != nil {
 		return nil, err
 	}
 	return nil, nil
@@ -263,6 +402,8 @@ func (r *Resolver) VisitAssignmentExpr(expr parser.Assignment) (any, error) {
 		return nil, err
 	}
 	r.resolveLocal(expr, expr.Name)
+	r.lint.selfAssignment(expr.Name, nil, expr.Value)
+	r.lint.assign(expr.Name)
 	return nil, nil
 }
 
@@ -287,6 +428,7 @@ func (r *Resolver) VisitCallExpr(expr parser.Call) (any, error) {
 			return nil, err
 		}
 	}
+	r.lint.call(expr)
 	return nil, nil
 }
 
@@ -307,6 +449,7 @@ func (r *Resolver) VisitSetExpr(expr parser.Set) (any, error) {
 	if _, err := r.resolveExpr(expr.Object); err != nil {
 		return nil, err
 	}
+	r.lint.selfAssignment(expr.Name, expr.Object, expr.Value)
 
 	return nil, nil
 }
@@ -338,6 +481,9 @@ func (r *Resolver) VisitThisExpr(expr parser.This) (any, error) {
 			"Can't use 'this' outside of a class.",
 		)
 	}
+	if r.currentFunType == FUNCTION {
+		r.lint.thisInFunction(expr.Keyword)
+	}
 	r.resolveLocal(expr, expr.Keyword)
 	return nil, nil
 }
@@ -384,6 +530,7 @@ func (r *Resolver) VisitVariableExpr(expr parser.Variable) (any, error) {
 		}
 	}
 	r.resolveLocal(expr, expr.Name)
+	r.lint.use(expr.Name)
 	return nil, nil
 }
 
@@ -4,28 +4,33 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/nt54hamnghi/golox/internal/errors"
 	"github.com/nt54hamnghi/golox/internal/scanner/token"
 )
 
 var keyword map[string]token.TokenType = map[string]token.TokenType{
-	"and":    token.AND,
-	"class":  token.CLASS,
-	"else":   token.ELSE,
-	"false":  token.FALSE,
-	"for":    token.FOR,
-	"fun":    token.FUN,
-	"if":     token.IF,
-	"nil":    token.NIL,
-	"or":     token.OR,
-	"print":  token.PRINT,
-	"return": token.RETURN,
-	"super":  token.SUPER,
-	"this":   token.THIS,
-	"true":   token.TRUE,
-	"var":    token.VAR,
-	"while":  token.WHILE,
+	"and":        token.AND,
+	"break":      token.BREAK,
+	"breakpoint": token.BREAKPOINT,
+	"class":      token.CLASS,
+	"continue":   token.CONTINUE,
+	"else":       token.ELSE,
+	"false":      token.FALSE,
+	"for":        token.FOR,
+	"fun":        token.FUN,
+	"if":         token.IF,
+	"import":     token.IMPORT,
+	"nil":        token.NIL,
+	"or":         token.OR,
+	"print":      token.PRINT,
+	"return":     token.RETURN,
+	"super":      token.SUPER,
+	"this":       token.THIS,
+	"true":       token.TRUE,
+	"var":        token.VAR,
+	"while":      token.WHILE,
 }
 
 type Scanner struct {
@@ -33,21 +38,45 @@ type Scanner struct {
 	source []rune
 	// Slice of tokens to be filled as we scan the source code
 	tokens []token.Token
+	// Comments found while scanning, which the parser never sees
+	comments []token.Token
 	// Offset into the source code, pointing at the first character of the lexeme being scanned
 	start int
 	// Offset into the source code, pointing at the character being considered for the current lexeme
 	current int
 	// Line where the lexeme is located.
 	line int
+	// Name of the file the source was read from, recorded on every token.
+	file string
+	// Byte offsets into the UTF-8 encoded source matching start and current.
+	startByte   int
+	currentByte int
+	// Offset of the first character of the current line.
+	lineStart int
+	// Column of the first character of the lexeme being scanned.
+	startColumn int
 }
 
 func NewScanner(src string) Scanner {
+	return NewFileScanner("", src)
+}
+
+// NewFileScanner creates a scanner for src, which was read from the file called name.
+func NewFileScanner(name string, src string) Scanner {
+	return NewFileScannerAtLine(name, src, 1)
+}
+
+// NewFileScannerAtLine creates a scanner for src whose first line is
+// numbered line, for sources that continue earlier input such as the
+// lines typed at a prompt.
+func NewFileScannerAtLine(name string, src string, line int) Scanner {
 	return Scanner{
 		source:  []rune(src),
 		tokens:  []token.Token{},
 		start:   0,
 		current: 0,
-		line:    1,
+		line:    line,
+		file:    name,
 	}
 }
 
@@ -55,13 +84,14 @@ func (s *Scanner) ScanTokens() ([]token.Token, error) {
 	sErr := ScannerError{}
 
 	for !s.isAtEnd() {
-		s.start = s.current
+		s.beginLexeme()
 		if err := s.scanToken(); err != nil {
 			sErr = append(sErr, err)
 		}
 	}
 
-	s.tokens = append(s.tokens, token.NewEOFToken(s.line))
+	s.beginLexeme()
+	s.tokens = append(s.tokens, token.Token{Type: token.EOF, Position: s.position()})
 
 	if sErr.empty() {
 		return s.tokens, nil
@@ -80,8 +110,14 @@ func (s *Scanner) scanToken() error {
 		s.addToken(token.LEFT_BRACE, nil)
 	case '}':
 		s.addToken(token.RIGHT_BRACE, nil)
+	case '[':
+		s.addToken(token.LEFT_BRACKET, nil)
+	case ']':
+		s.addToken(token.RIGHT_BRACKET, nil)
 	case ',':
 		s.addToken(token.COMMA, nil)
+	case ':':
+		s.addToken(token.COLON, nil)
 	case '.':
 		s.addToken(token.DOT, nil)
 	case '-':
@@ -129,6 +165,7 @@ func (s *Scanner) scanToken() error {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advanced()
 			}
+			s.addComment()
 		} else {
 			s.addToken(token.SLASH, nil)
 		}
@@ -136,7 +173,7 @@ func (s *Scanner) scanToken() error {
 		// ignore whitespace
 		return nil
 	case '\n':
-		s.line++
+		// line counting happens in advanced
 		return nil
 	case '"':
 		return s.string()
@@ -146,13 +183,19 @@ func (s *Scanner) scanToken() error {
 		} else if isAlpha(char) {
 			s.identifier()
 		} else {
-			return errors.StaticErrorAtLine(s.line, "Unexpected character: "+string(char))
+			return errors.StaticErrorAt(s.position(), "Unexpected character: "+string(char))
 		}
 	}
 
 	return nil
 }
 
+// IsKeyword reports whether name is a reserved word rather than an identifier.
+func IsKeyword(name string) bool {
+	_, ok := keyword[name]
+	return ok
+}
+
 func isDigit(char rune) bool {
 	return '0' <= char && char <= '9'
 }
@@ -168,6 +211,11 @@ func isAlphaNumeric(char rune) bool {
 func (s *Scanner) advanced() rune {
 	char := s.source[s.current]
 	s.current++
+	s.currentByte += utf8.RuneLen(char)
+	if char == '\n' {
+		s.line++
+		s.lineStart = s.current
+	}
 	return char
 }
 
@@ -181,7 +229,7 @@ func (s *Scanner) match(expected rune) bool {
 		return false
 	}
 
-	s.current++
+	s.advanced()
 	return true
 }
 
@@ -193,17 +241,14 @@ func (s *Scanner) peek() rune {
 }
 
 func (s *Scanner) string() error {
+	// multi-line strings are supported, advanced
+	// updates the line counter on every newline
 	for s.peek() != '"' && !s.isAtEnd() {
-		// support for multi-line string, updating line
-		// counter when encountering a newline
-		if s.peek() == '\n' {
-			s.line += 1
-		}
 		s.advanced()
 	}
 
 	if s.isAtEnd() {
-		return errors.StaticErrorAtLine(s.line, "Unterminated string.")
+		return errors.StaticErrorAt(s.position(), "Unterminated string.")
 	}
 
 	// consume the closing "
@@ -259,10 +304,45 @@ func (s *Scanner) peekNext() rune {
 
 func (s *Scanner) addToken(typ token.TokenType, literal any) {
 	text := string(s.source[s.start:s.current])
-	token := token.NewToken(typ, text, literal, s.line)
+	token := token.Token{Type: typ, Lexeme: text, Literal: literal, Position: s.position()}
 	s.tokens = append(s.tokens, token)
 }
 
+// addComment records the comment just scanned, without its line terminator.
+func (s *Scanner) addComment() {
+	raw := string(s.source[s.start:s.current])
+	text := strings.TrimRight(raw, " \t\r")
+	pos := s.position()
+	pos.End -= len(raw) - len(text)
+	s.comments = append(s.comments, token.Token{Type: token.COMMENT, Lexeme: text, Position: pos})
+}
+
+// Comments returns the comments skipped by ScanTokens, in source order.
+// Their lexeme is the comment text, including the leading slashes.
+func (s *Scanner) Comments() []token.Token {
+	return s.comments
+}
+
+// beginLexeme marks the current character as the start of the next lexeme.
+func (s *Scanner) beginLexeme() {
+	s.start = s.current
+	s.startByte = s.currentByte
+	s.startColumn = s.current - s.lineStart + 1
+}
+
+// position returns the location of the lexeme scanned so far.
+// Like the token line, Line is the line the lexeme ends on,
+// while Column is where it starts.
+func (s Scanner) position() token.Position {
+	return token.Position{
+		File:   s.file,
+		Line:   s.line,
+		Column: s.startColumn,
+		Start:  s.startByte,
+		End:    s.currentByte,
+	}
+}
+
 func (s Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
@@ -284,6 +364,11 @@ func (se ScannerError) Error() string {
 	return strings.TrimSpace(b.String())
 }
 
+// Unwrap returns the individual lexical errors.
+func (se ScannerError) Unwrap() []error {
+	return se
+}
+
 // empty returns true if all errors are nil
 func (se ScannerError) empty() bool {
 	empty := true
@@ -221,6 +221,8 @@ func TestScannerBraces(t *testing.T) {
 		{"single right brace", "}", []token.TokenType{token.RIGHT_BRACE, token.EOF}},
 		{"pair of braces", "{{}}", []token.TokenType{token.LEFT_BRACE, token.LEFT_BRACE, token.RIGHT_BRACE, token.RIGHT_BRACE, token.EOF}},
 		{"alternating braces", "}{}{{", []token.TokenType{token.RIGHT_BRACE, token.LEFT_BRACE, token.RIGHT_BRACE, token.LEFT_BRACE, token.LEFT_BRACE, token.EOF}},
+		{"colon", ":", []token.TokenType{token.COLON, token.EOF}},
+		{"pair of brackets", "[[]]", []token.TokenType{token.LEFT_BRACKET, token.LEFT_BRACKET, token.RIGHT_BRACKET, token.RIGHT_BRACKET, token.EOF}},
 		{"mixed braces and parens", "{(){()}", []token.TokenType{token.LEFT_BRACE, token.LEFT_PAREN, token.RIGHT_PAREN, token.LEFT_BRACE, token.LEFT_PAREN, token.RIGHT_PAREN, token.RIGHT_BRACE, token.EOF}},
 	}
 
@@ -353,6 +355,27 @@ func TestScannerDivisionOperatorAndComments(t *testing.T) {
 	}
 }
 
+func TestScannerKeepsComments(t *testing.T) {
+	r := require.New(t)
+
+	scanner := NewScanner("// header\nprint 1; // trailing  \n/ //// slashes")
+	tokens, err := scanner.ScanTokens()
+	r.NoError(err)
+	for _, tok := range tokens {
+		r.NotEqual(token.COMMENT, tok.Type)
+	}
+
+	comments := scanner.Comments()
+	r.Len(comments, 3)
+	r.Equal(token.COMMENT, comments[0].Type)
+	r.Equal("// header", comments[0].Lexeme)
+	r.Equal(token.Position{Line: 1, Column: 1, Start: 0, End: 9}, comments[0].Position)
+	r.Equal("// trailing", comments[1].Lexeme)
+	r.Equal(token.Position{Line: 2, Column: 10, Start: 19, End: 30}, comments[1].Position)
+	r.Equal("//// slashes", comments[2].Lexeme)
+	r.Equal(3, comments[2].Line)
+}
+
 func TestScannerWhitespace(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -450,6 +473,11 @@ func TestScannerReservedWords(t *testing.T) {
 		want   []token.TokenType
 	}{
 		{"single reserved word", "else", []token.TokenType{token.ELSE, token.EOF}},
+		{
+			"loop control words",
+			"break continue BREAK breaking",
+			[]token.TokenType{token.BREAK, token.CONTINUE, token.IDENTIFIER, token.IDENTIFIER, token.EOF},
+		},
 		{
 			"reserved and uppercase identifiers",
 			"nil true print class this ELSE AND WHILE FALSE while or CLASS VAR var NIL if FOR super IF FUN and OR TRUE SUPER for fun PRINT RETURN false else return THIS",
@@ -483,3 +511,35 @@ func TestScannerReservedWords(t *testing.T) {
 		})
 	}
 }
+
+func TestScannerTokenPositions(t *testing.T) {
+	r := require.New(t)
+
+	scanner := NewFileScanner("pos.lox", "var s = \"ॐ\nb\";\n\tprint s;")
+	tokens, err := scanner.ScanTokens()
+
+	r.NoError(err)
+
+	want := []struct {
+		lexeme string
+		pos    token.Position
+	}{
+		{"var", token.Position{File: "pos.lox", Line: 1, Column: 1, Start: 0, End: 3}},
+		{"s", token.Position{File: "pos.lox", Line: 1, Column: 5, Start: 4, End: 5}},
+		{"=", token.Position{File: "pos.lox", Line: 1, Column: 7, Start: 6, End: 7}},
+		// a multi-line string ends on line 2 but starts at column 9 of line 1,
+		// and its offsets count the bytes of the UTF-8 encoded 'ॐ'
+		{"\"ॐ\nb\"", token.Position{File: "pos.lox", Line: 2, Column: 9, Start: 8, End: 15}},
+		{";", token.Position{File: "pos.lox", Line: 2, Column: 3, Start: 15, End: 16}},
+		{"print", token.Position{File: "pos.lox", Line: 3, Column: 2, Start: 18, End: 23}},
+		{"s", token.Position{File: "pos.lox", Line: 3, Column: 8, Start: 24, End: 25}},
+		{";", token.Position{File: "pos.lox", Line: 3, Column: 9, Start: 25, End: 26}},
+		{"", token.Position{File: "pos.lox", Line: 3, Column: 10, Start: 26, End: 26}},
+	}
+
+	r.Len(tokens, len(want))
+	for i, w := range want {
+		r.Equal(w.lexeme, tokens[i].Lexeme, "token[%d] lexeme mismatch", i)
+		r.Equal(w.pos, tokens[i].Position, "token[%d] position mismatch", i)
+	}
+}
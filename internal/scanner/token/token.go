@@ -4,11 +4,25 @@ import (
 	"fmt"
 )
 
+// Position locates a span of source text.
+type Position struct {
+	// Name of the file the source was read from, empty if unknown.
+	File string
+	Line int
+	// 1-based column of the first character, counted in runes.
+	// Zero means the position only knows its line.
+	Column int
+	// Byte offset of the first byte of the span.
+	Start int
+	// Byte offset just past the last byte of the span.
+	End int
+}
+
 type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal any
-	Line    int
+	Position
 }
 
 func NewToken(kind TokenType, lexeme string, literal any, line int) Token {
@@ -16,7 +30,7 @@ func NewToken(kind TokenType, lexeme string, literal any, line int) Token {
 		kind,
 		lexeme,
 		literal,
-		line,
+		Position{Line: line},
 	}
 }
 
@@ -25,7 +39,7 @@ func NewEOFToken(line int) Token {
 		EOF,
 		"",
 		nil,
-		line,
+		Position{Line: line},
 	}
 }
 
@@ -9,7 +9,10 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
+	COLON
 	DOT
 	MINUS
 	PLUS
@@ -37,12 +40,16 @@ const (
 	// Keywords.
 
 	AND
+	BREAK
+	BREAKPOINT
 	CLASS
+	CONTINUE
 	ELSE
 	FALSE
 	FUN
 	FOR
 	IF
+	IMPORT
 	NIL
 	OR
 	PRINT
@@ -53,6 +60,10 @@ const (
 	VAR
 	WHILE
 
+	// Line comments, which are kept out of the token stream.
+
+	COMMENT
+
 	EOF
 )
 
@@ -61,7 +72,10 @@ var TokenTypeString = [...]string{
 	"RIGHT_PAREN",
 	"LEFT_BRACE",
 	"RIGHT_BRACE",
+	"LEFT_BRACKET",
+	"RIGHT_BRACKET",
 	"COMMA",
+	"COLON",
 	"DOT",
 	"MINUS",
 	"PLUS",
@@ -80,12 +94,16 @@ var TokenTypeString = [...]string{
 	"STRING",
 	"NUMBER",
 	"AND",
+	"BREAK",
+	"BREAKPOINT",
 	"CLASS",
+	"CONTINUE",
 	"ELSE",
 	"FALSE",
 	"FUN",
 	"FOR",
 	"IF",
+	"IMPORT",
 	"NIL",
 	"OR",
 	"PRINT",
@@ -95,6 +113,7 @@ var TokenTypeString = [...]string{
 	"TRUE",
 	"VAR",
 	"WHILE",
+	"COMMENT",
 	"EOF",
 }
 
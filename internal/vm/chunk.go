@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"sort"
+
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// OpCode is a bytecode instruction. Its operands, if any, follow it in the
+// code: constant indices, slots and jump offsets are 16-bit big-endian,
+// argument counts a single byte.
+type OpCode byte
+
+const (
+	// OP_CONSTANT index: push a constant.
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	// OP_GET_LOCAL slot: push a local of the current frame.
+	OpGetLocal
+	// OP_SET_LOCAL slot: store the top of the stack in a local.
+	OpSetLocal
+	// OP_GET_UPVALUE index: push a variable captured by the current closure.
+	OpGetUpvalue
+	// OP_SET_UPVALUE index: store the top of the stack in a captured variable.
+	OpSetUpvalue
+	// OP_GET_GLOBAL name: push a global of the current module or a builtin.
+	OpGetGlobal
+	// OP_DEFINE_GLOBAL name: pop a value into a new global of the current module.
+	OpDefineGlobal
+	// OP_SET_GLOBAL name: store the top of the stack in an existing global.
+	OpSetGlobal
+	// OP_GET_PROPERTY name: replace an object with one of its properties.
+	OpGetProperty
+	// OP_SET_PROPERTY name: set a field of an instance to the top of the stack.
+	OpSetProperty
+	// OP_GET_SUPER name: replace this and a superclass with a bound superclass method.
+	OpGetSuper
+	OpGetIndex
+	OpSetIndex
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpNot
+	OpNegate
+	OpPrint
+	// OP_ECHO: pop a value and print it unless it is nil, for interactive sessions.
+	OpEcho
+	// OP_JUMP offset: jump forward.
+	OpJump
+	// OP_JUMP_IF_FALSE offset: jump forward if the top of the stack is falsey, without popping it.
+	OpJumpIfFalse
+	// OP_LOOP offset: jump backward.
+	OpLoop
+	// OP_CALL count: call the value below count arguments.
+	OpCall
+	// OP_CLOSURE function: push a closure, followed by an is-local byte and
+	// a 16-bit slot or upvalue index for each variable it captures.
+	OpClosure
+	// OP_CLOSE_UPVALUE: move the local on top of the stack to the heap and pop it.
+	OpCloseUpvalue
+	OpReturn
+	// OP_CLASS name: push a new class.
+	OpClass
+	// OP_INHERIT: copy the methods of a superclass into the class on top of it, popping the class.
+	OpInherit
+	// OP_METHOD name: add the closure on top of the stack to the class below it.
+	OpMethod
+	// OP_LIST count: replace count values with a list holding them.
+	OpList
+	// OP_MAP: push an empty map.
+	OpMap
+	// OP_MAP_ENTRY: pop a key and a value and store them in the map below.
+	OpMapEntry
+	// OP_IMPORT module: push a module, then the result of running it the
+	// first time it is imported or nil.
+	OpImport
+)
+
+var opNames = [...]string{
+	OpConstant:     "OP_CONSTANT",
+	OpNil:          "OP_NIL",
+	OpTrue:         "OP_TRUE",
+	OpFalse:        "OP_FALSE",
+	OpPop:          "OP_POP",
+	OpGetLocal:     "OP_GET_LOCAL",
+	OpSetLocal:     "OP_SET_LOCAL",
+	OpGetUpvalue:   "OP_GET_UPVALUE",
+	OpSetUpvalue:   "OP_SET_UPVALUE",
+	OpGetGlobal:    "OP_GET_GLOBAL",
+	OpDefineGlobal: "OP_DEFINE_GLOBAL",
+	OpSetGlobal:    "OP_SET_GLOBAL",
+	OpGetProperty:  "OP_GET_PROPERTY",
+	OpSetProperty:  "OP_SET_PROPERTY",
+	OpGetSuper:     "OP_GET_SUPER",
+	OpGetIndex:     "OP_GET_INDEX",
+	OpSetIndex:     "OP_SET_INDEX",
+	OpEqual:        "OP_EQUAL",
+	OpNotEqual:     "OP_NOT_EQUAL",
+	OpGreater:      "OP_GREATER",
+	OpGreaterEqual: "OP_GREATER_EQUAL",
+	OpLess:         "OP_LESS",
+	OpLessEqual:    "OP_LESS_EQUAL",
+	OpAdd:          "OP_ADD",
+	OpSubtract:     "OP_SUBTRACT",
+	OpMultiply:     "OP_MULTIPLY",
+	OpDivide:       "OP_DIVIDE",
+	OpNot:          "OP_NOT",
+	OpNegate:       "OP_NEGATE",
+	OpPrint:        "OP_PRINT",
+	OpEcho:         "OP_ECHO",
+	OpJump:         "OP_JUMP",
+	OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	OpLoop:         "OP_LOOP",
+	OpCall:         "OP_CALL",
+	OpClosure:      "OP_CLOSURE",
+	OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+	OpReturn:       "OP_RETURN",
+	OpClass:        "OP_CLASS",
+	OpInherit:      "OP_INHERIT",
+	OpMethod:       "OP_METHOD",
+	OpList:         "OP_LIST",
+	OpMap:          "OP_MAP",
+	OpMapEntry:     "OP_MAP_ENTRY",
+	OpImport:       "OP_IMPORT",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return "OP_UNKNOWN"
+}
+
+// Chunk is the bytecode of a function along with the constants it refers to.
+type Chunk struct {
+	code      []byte
+	constants []interpreter.Object
+	// The token each instruction that can fail was compiled from,
+	// in code order, so runtime errors point at the same source as
+	// the tree-walking interpreter's.
+	locations []location
+}
+
+// location ties the instruction at offset to the token it was compiled from.
+type location struct {
+	offset int
+	token  token.Token
+}
+
+// write appends op to the code, tied to tok for error reporting.
+func (c *Chunk) write(op OpCode, tok token.Token) {
+	c.locations = append(c.locations, location{len(c.code), tok})
+	c.code = append(c.code, byte(op))
+}
+
+// writeByte appends a raw byte, an opcode that can't fail or an operand.
+func (c *Chunk) writeByte(b byte) {
+	c.code = append(c.code, b)
+}
+
+// writeShort appends a 16-bit operand.
+func (c *Chunk) writeShort(n int) {
+	c.code = append(c.code, byte(n>>8), byte(n))
+}
+
+// readShort reads the 16-bit operand at offset.
+func (c *Chunk) readShort(offset int) int {
+	return int(c.code[offset])<<8 | int(c.code[offset+1])
+}
+
+// tokenAt returns the token the instruction at offset was compiled from.
+func (c *Chunk) tokenAt(offset int) token.Token {
+	i := sort.Search(len(c.locations), func(i int) bool {
+		return c.locations[i].offset > offset
+	})
+	if i == 0 {
+		return token.Token{}
+	}
+	return c.locations[i-1].token
+}
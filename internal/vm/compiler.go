@@ -0,0 +1,828 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// The largest 16-bit operand.
+const maxShort = 1<<16 - 1
+
+type funKind int
+
+const (
+	scriptKind funKind = iota
+	functionKind
+	methodKind
+	initializerKind
+)
+
+// compiler turns resolved statements into bytecode.
+//
+// It keeps the same stack of lexical scopes as the resolver, so the depth
+// the resolver recorded for a variable usage designates the scope that
+// declares it. Scopes belong to the function being compiled when they were
+// opened: a variable declared in the current function lives in a stack slot,
+// one declared in an enclosing function is captured as an upvalue.
+type compiler struct {
+	// Resolved depth of each local variable usage.
+	locals map[parser.NodeID]int
+	// The module each import statement was linked to.
+	imports map[parser.NodeID]*Module
+	// The module the code is compiled for.
+	module *Module
+	// The function being compiled.
+	fn *funcState
+	// The scopes currently open, innermost last.
+	scopes []*scope
+	// The last token an instruction was compiled from.
+	token token.Token
+	// The first error found, if any.
+	err error
+}
+
+// funcState is the state of a function being compiled.
+type funcState struct {
+	enclosing *funcState
+	function  *Function
+	kind      funKind
+	// Number of stack slots in use, slot 0 being the callee or this.
+	slots    int
+	upvalues []upvalueRef
+	// Indices of the constants already added, to reuse them.
+	constants map[Object]int
+	// The loops being compiled, innermost last.
+	loops []*loop
+}
+
+// upvalueRef tells a closure where to capture a variable from: a slot of the
+// enclosing function, or one of the enclosing function's own upvalues.
+type upvalueRef struct {
+	index   int
+	isLocal bool
+}
+
+type scope struct {
+	fn     *funcState
+	locals []*local
+}
+
+type local struct {
+	name     string
+	slot     int
+	captured bool
+}
+
+type loop struct {
+	// The while or for keyword, where limits stop the loop.
+	keyword token.Token
+	// Where continue statements jump to.
+	start int
+	// Number of scopes open outside the loop.
+	scopes int
+	// Jumps of break statements, patched once the end of the loop is known.
+	breaks []int
+}
+
+// compile compiles program as the top-level code of module.
+// When interactive is set, the value of top-level expression statements
+// is printed, unless it is nil.
+func (vm *VM) compile(program []parser.Stmt, module *Module, interactive bool) (*Function, error) {
+	c := &compiler{
+		locals:  vm.locals,
+		imports: vm.imports,
+		module:  module,
+	}
+	c.beginFunction("", "", 0, scriptKind)
+
+	for _, stmt := range program {
+		if expr, ok := stmt.(parser.Expression); ok && interactive {
+			c.expression(expr.Expression)
+			c.emitOp(OpEcho)
+			continue
+		}
+		c.statement(stmt)
+	}
+
+	function, _ := c.endFunction()
+	if c.err != nil {
+		return nil, c.err
+	}
+	return function, nil
+}
+
+func (c *compiler) statement(stmt parser.Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *compiler) expression(expr parser.Expr) {
+	expr.Accept(c)
+}
+
+// error records a static error at the last compiled token, unless one was
+// already found. Compilation goes on, but its result is discarded.
+func (c *compiler) error(message string) {
+	if c.err == nil {
+		c.err = errors.StaticErrorAtToken(c.token, message)
+	}
+}
+
+func (c *compiler) chunk() *Chunk {
+	return &c.fn.function.chunk
+}
+
+// emit appends an instruction that can fail at tok.
+func (c *compiler) emit(op OpCode, tok token.Token) {
+	c.token = tok
+	c.chunk().write(op, tok)
+}
+
+// emitOp appends an instruction that can't fail.
+func (c *compiler) emitOp(op OpCode) {
+	c.chunk().writeByte(byte(op))
+}
+
+// emitShort appends a 16-bit operand.
+func (c *compiler) emitShort(n int) {
+	c.chunk().writeShort(n)
+}
+
+// constant adds value to the constant pool and returns its index.
+func (c *compiler) constant(value Object) int {
+	switch value.(type) {
+	case float64, string:
+		if index, ok := c.fn.constants[value]; ok {
+			return index
+		}
+	}
+
+	chunk := c.chunk()
+	index := len(chunk.constants)
+	if index > maxShort {
+		c.error("Too many constants in one chunk.")
+		return 0
+	}
+	chunk.constants = append(chunk.constants, value)
+
+	switch value.(type) {
+	case float64, string:
+		c.fn.constants[value] = index
+	}
+	return index
+}
+
+// emitConstant appends an instruction taking the constant value as operand.
+func (c *compiler) emitConstant(op OpCode, tok token.Token, value Object) {
+	c.token = tok
+	index := c.constant(value)
+	c.emit(op, tok)
+	c.emitShort(index)
+}
+
+// emitJump appends a forward jump and returns the offset of its operand.
+func (c *compiler) emitJump(op OpCode) int {
+	c.emitOp(op)
+	c.emitShort(maxShort)
+	return len(c.chunk().code) - 2
+}
+
+// patchJump makes the jump whose operand is at offset land on the next instruction.
+func (c *compiler) patchJump(offset int) {
+	code := c.chunk().code
+	jump := len(code) - offset - 2
+	if jump > maxShort {
+		c.error("Too much code to jump over.")
+	}
+	code[offset] = byte(jump >> 8)
+	code[offset+1] = byte(jump)
+}
+
+// emitLoop appends a backward jump to start, in the loop introduced by keyword.
+func (c *compiler) emitLoop(start int, keyword token.Token) {
+	c.emit(OpLoop, keyword)
+	offset := len(c.chunk().code) - start + 2
+	if offset > maxShort {
+		c.error("Loop body too large.")
+	}
+	c.emitShort(offset)
+}
+
+// emitReturn returns from the current function without a value,
+// which returns this from an initializer.
+func (c *compiler) emitReturn() {
+	if c.fn.kind == initializerKind {
+		c.emitOp(OpGetLocal)
+		c.emitShort(0)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.emitOp(OpReturn)
+}
+
+// beginFunction starts compiling a function. Slot 0 holds this in methods
+// and the callee otherwise, where it can't be referred to.
+func (c *compiler) beginFunction(name string, class string, arity int, kind funKind) {
+	c.fn = &funcState{
+		enclosing: c.fn,
+		function: &Function{
+			name:   name,
+			class:  class,
+			arity:  arity,
+			module: c.module,
+		},
+		kind:      kind,
+		slots:     1,
+		constants: make(map[Object]int),
+	}
+}
+
+// endFunction finishes the current function and returns it along with
+// the variables it captures. The scopes it opened are dropped, without
+// popping their locals since returning discards them.
+func (c *compiler) endFunction() (*Function, []upvalueRef) {
+	c.emitReturn()
+
+	fs := c.fn
+	for len(c.scopes) > 0 && c.scopes[len(c.scopes)-1].fn == fs {
+		c.scopes = c.scopes[:len(c.scopes)-1]
+	}
+	c.fn = fs.enclosing
+
+	fs.function.upvalues = len(fs.upvalues)
+	return fs.function, fs.upvalues
+}
+
+func (c *compiler) beginScope() {
+	c.scopes = append(c.scopes, &scope{fn: c.fn})
+}
+
+// endScope closes the innermost scope and pops its locals.
+func (c *compiler) endScope() {
+	s := c.scopes[len(c.scopes)-1]
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.popLocals(s)
+	c.fn.slots -= len(s.locals)
+}
+
+// popLocals emits the instructions discarding the locals of s, moving the
+// captured ones to the heap.
+func (c *compiler) popLocals(s *scope) {
+	for i := len(s.locals) - 1; i >= 0; i-- {
+		if s.locals[i].captured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+	}
+}
+
+// addLocal declares a local in the innermost scope, bound to the next stack slot.
+func (c *compiler) addLocal(name string) *local {
+	if c.fn.slots > maxShort {
+		c.error("Too many local variables in function.")
+	}
+	l := &local{name: name, slot: c.fn.slots}
+	c.fn.slots++
+
+	s := c.scopes[len(c.scopes)-1]
+	s.locals = append(s.locals, l)
+	return l
+}
+
+// declare binds name to the value on top of the stack: as a local when a
+// scope is open and as a global of the module otherwise.
+func (c *compiler) declare(name token.Token) {
+	if len(c.scopes) > 0 {
+		c.addLocal(name.Lexeme)
+		return
+	}
+	c.emitConstant(OpDefineGlobal, name, name.Lexeme)
+}
+
+// find returns the local called name declared in s.
+func (s *scope) find(name string) *local {
+	for i := len(s.locals) - 1; i >= 0; i-- {
+		if s.locals[i].name == name {
+			return s.locals[i]
+		}
+	}
+	panic(fmt.Sprintf("unresolved variable '%s'", name))
+}
+
+// access is how to read and write a variable.
+type access struct {
+	get, set OpCode
+	// The slot, upvalue index or name constant of the variable.
+	operand int
+}
+
+// variable returns how to access the variable called name used by expr.
+func (c *compiler) variable(expr parser.Expr, name string) access {
+	depth, ok := c.locals[expr.Id()]
+	if !ok {
+		return access{OpGetGlobal, OpSetGlobal, c.constant(name)}
+	}
+	return c.scoped(c.scopes[len(c.scopes)-1-depth], name)
+}
+
+// scoped returns how to access the local called name declared in s.
+func (c *compiler) scoped(s *scope, name string) access {
+	l := s.find(name)
+	if s.fn == c.fn {
+		return access{OpGetLocal, OpSetLocal, l.slot}
+	}
+	return access{OpGetUpvalue, OpSetUpvalue, c.upvalue(c.fn, s.fn, l)}
+}
+
+// upvalue returns the index of the upvalue through which fs captures the
+// local l of owner, one of the functions enclosing it.
+func (c *compiler) upvalue(fs *funcState, owner *funcState, l *local) int {
+	var ref upvalueRef
+	if fs.enclosing == owner {
+		l.captured = true
+		ref = upvalueRef{l.slot, true}
+	} else {
+		ref = upvalueRef{c.upvalue(fs.enclosing, owner, l), false}
+	}
+
+	for i, existing := range fs.upvalues {
+		if existing == ref {
+			return i
+		}
+	}
+	if len(fs.upvalues) > maxShort {
+		c.error("Too many closure variables in function.")
+		return 0
+	}
+	fs.upvalues = append(fs.upvalues, ref)
+	return len(fs.upvalues) - 1
+}
+
+// load emits the instruction reading a variable, reporting errors at tok.
+func (c *compiler) load(a access, tok token.Token) {
+	c.emit(a.get, tok)
+	c.emitShort(a.operand)
+}
+
+// function compiles decl and emits the closure creating it.
+func (c *compiler) function(decl parser.Function, kind funKind, class string) {
+	c.beginFunction(decl.Name.Lexeme, class, len(decl.Params), kind)
+	if kind == methodKind || kind == initializerKind {
+		// this gets a scope of its own, like in the resolver
+		c.fn.slots = 0
+		c.beginScope()
+		c.addLocal("this")
+	}
+
+	c.beginScope()
+	for _, param := range decl.Params {
+		c.addLocal(param.Lexeme)
+	}
+	for _, stmt := range decl.Body {
+		c.statement(stmt)
+	}
+
+	function, upvalues := c.endFunction()
+	c.emitConstant(OpClosure, decl.Name, function)
+	for _, ref := range upvalues {
+		if ref.isLocal {
+			c.chunk().writeByte(1)
+		} else {
+			c.chunk().writeByte(0)
+		}
+		c.emitShort(ref.index)
+	}
+}
+
+// VisitBlockStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitBlockStmt(stmt parser.Block) (any, error) {
+	c.beginScope()
+	for _, s := range stmt.Stmts {
+		c.statement(s)
+	}
+	c.endScope()
+	return nil, nil
+}
+
+// VisitClassStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitClassStmt(stmt parser.Class) (any, error) {
+	name := stmt.Name.Lexeme
+	c.emitConstant(OpClass, stmt.Name, name)
+	c.declare(stmt.Name)
+
+	// the class was just declared, so it is in the innermost scope or global
+	class := access{OpGetGlobal, OpSetGlobal, c.constant(name)}
+	if len(c.scopes) > 0 {
+		class = c.scoped(c.scopes[len(c.scopes)-1], name)
+	}
+
+	if stmt.Superclass != nil {
+		c.VisitVariableExpr(*stmt.Superclass)
+		c.beginScope()
+		c.addLocal("super")
+
+		c.load(class, stmt.Name)
+		c.emit(OpInherit, stmt.Superclass.Name)
+	}
+
+	c.load(class, stmt.Name)
+	for _, method := range stmt.Methods {
+		kind := methodKind
+		if method.Name.Lexeme == "init" {
+			kind = initializerKind
+		}
+		c.function(method, kind, name)
+		c.emitConstant(OpMethod, method.Name, method.Name.Lexeme)
+	}
+	c.emitOp(OpPop)
+
+	if stmt.Superclass != nil {
+		c.endScope()
+	}
+	return nil, nil
+}
+
+// VisitExpressionStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitExpressionStmt(stmt parser.Expression) (any, error) {
+	c.expression(stmt.Expression)
+	c.emitOp(OpPop)
+	return nil, nil
+}
+
+// VisitFunctionStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitFunctionStmt(stmt parser.Function) (any, error) {
+	if len(c.scopes) > 0 {
+		// declared before its body, so it can call itself
+		c.addLocal(stmt.Name.Lexeme)
+		c.function(stmt, functionKind, "")
+		return nil, nil
+	}
+	c.function(stmt, functionKind, "")
+	c.declare(stmt.Name)
+	return nil, nil
+}
+
+// VisitIfStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitIfStmt(stmt parser.If) (any, error) {
+	c.expression(stmt.Condition)
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.statement(stmt.ThenBranch)
+
+	elseJump := c.emitJump(OpJump)
+	c.patchJump(thenJump)
+	c.emitOp(OpPop)
+	if stmt.ElseBranch != nil {
+		c.statement(stmt.ElseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil, nil
+}
+
+// VisitImportStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitImportStmt(stmt parser.Import) (any, error) {
+	module, ok := c.imports[stmt.Id()]
+	if !ok {
+		panic("unlinked import statement")
+	}
+
+	c.emitConstant(OpImport, stmt.Keyword, module)
+	c.emitOp(OpPop)
+	c.declare(stmt.Name())
+	return nil, nil
+}
+
+// VisitPrintStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitPrintStmt(stmt parser.Print) (any, error) {
+	c.expression(stmt.Expression)
+	c.emitOp(OpPrint)
+	return nil, nil
+}
+
+// VisitReturnStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitReturnStmt(stmt parser.Return) (any, error) {
+	if stmt.Value == nil {
+		c.emitReturn()
+		return nil, nil
+	}
+	c.expression(stmt.Value)
+	c.emitOp(OpReturn)
+	return nil, nil
+}
+
+// VisitVarStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitVarStmt(stmt parser.Var) (any, error) {
+	if stmt.Initializer != nil {
+		c.expression(stmt.Initializer)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.declare(stmt.Name)
+	return nil, nil
+}
+
+// VisitWhileStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitWhileStmt(stmt parser.While) (any, error) {
+	start := len(c.chunk().code)
+	c.expression(stmt.Condition)
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+
+	c.loopBody(stmt.Keyword, stmt.Body, start)
+
+	c.patchJump(exitJump)
+	c.emitOp(OpPop)
+	c.patchBreaks()
+	return nil, nil
+}
+
+// VisitForStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitForStmt(stmt parser.For) (any, error) {
+	// the initializer gets its own scope, shared by every iteration
+	c.beginScope()
+	if stmt.Initializer != nil {
+		c.statement(stmt.Initializer)
+	}
+
+	start := len(c.chunk().code)
+	exitJump := -1
+	if stmt.Condition != nil {
+		c.expression(stmt.Condition)
+		exitJump = c.emitJump(OpJumpIfFalse)
+		c.emitOp(OpPop)
+	}
+
+	if stmt.Increment != nil {
+		// the increment comes first in the code, but runs after the body
+		bodyJump := c.emitJump(OpJump)
+		increment := len(c.chunk().code)
+		c.expression(stmt.Increment)
+		c.emitOp(OpPop)
+		c.emitLoop(start, stmt.Keyword)
+		start = increment
+		c.patchJump(bodyJump)
+	}
+
+	c.loopBody(stmt.Keyword, stmt.Body, start)
+
+	if exitJump >= 0 {
+		c.patchJump(exitJump)
+		c.emitOp(OpPop)
+	}
+	c.patchBreaks()
+	c.endScope()
+	return nil, nil
+}
+
+// loopBody compiles the body of the loop introduced by keyword,
+// which continues at start.
+// The loop stays open until patchBreaks is called.
+func (c *compiler) loopBody(keyword token.Token, body parser.Stmt, start int) {
+	c.fn.loops = append(c.fn.loops, &loop{keyword: keyword, start: start, scopes: len(c.scopes)})
+	c.statement(body)
+	c.emitLoop(start, keyword)
+}
+
+// patchBreaks makes the break statements of the innermost loop jump to the
+// next instruction and closes the loop.
+func (c *compiler) patchBreaks() {
+	l := c.fn.loops[len(c.fn.loops)-1]
+	c.fn.loops = c.fn.loops[:len(c.fn.loops)-1]
+	for _, jump := range l.breaks {
+		c.patchJump(jump)
+	}
+}
+
+// exitLoopScopes pops the locals declared inside the innermost loop,
+// before jumping out of its body.
+func (c *compiler) exitLoopScopes() *loop {
+	l := c.fn.loops[len(c.fn.loops)-1]
+	for i := len(c.scopes) - 1; i >= l.scopes; i-- {
+		c.popLocals(c.scopes[i])
+	}
+	return l
+}
+
+// VisitBreakStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitBreakStmt(stmt parser.Break) (any, error) {
+	l := c.exitLoopScopes()
+	l.breaks = append(l.breaks, c.emitJump(OpJump))
+	return nil, nil
+}
+
+// VisitContinueStmt implements [parser.StmtVisitor].
+func (c *compiler) VisitContinueStmt(stmt parser.Continue) (any, error) {
+	l := c.exitLoopScopes()
+	c.emitLoop(l.start, l.keyword)
+	return nil, nil
+}
+
+// VisitBreakpointStmt implements [parser.StmtVisitor].
+// The VM has no debugger, so breakpoints compile to nothing.
+func (c *compiler) VisitBreakpointStmt(stmt parser.Breakpoint) (any, error) {
+	return nil, nil
+}
+
+// VisitAssignmentExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitAssignmentExpr(expr parser.Assignment) (any, error) {
+	c.expression(expr.Value)
+	a := c.variable(expr, expr.Name.Lexeme)
+	c.emit(a.set, expr.Name)
+	c.emitShort(a.operand)
+	return nil, nil
+}
+
+// VisitBinaryExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitBinaryExpr(expr parser.Binary) (any, error) {
+	c.expression(expr.Left)
+	c.expression(expr.Right)
+
+	var op OpCode
+	switch expr.Operator.Type {
+	case token.PLUS:
+		op = OpAdd
+	case token.MINUS:
+		op = OpSubtract
+	case token.STAR:
+		op = OpMultiply
+	case token.SLASH:
+		op = OpDivide
+	case token.GREATER:
+		op = OpGreater
+	case token.GREATER_EQUAL:
+		op = OpGreaterEqual
+	case token.LESS:
+		op = OpLess
+	case token.LESS_EQUAL:
+		op = OpLessEqual
+	case token.EQUAL_EQUAL:
+		op = OpEqual
+	case token.BANG_EQUAL:
+		op = OpNotEqual
+	default:
+		panic(fmt.Sprintf("unexpected binary operator: %v", expr.Operator.Type))
+	}
+	c.emit(op, expr.Operator)
+	return nil, nil
+}
+
+// VisitCallExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitCallExpr(expr parser.Call) (any, error) {
+	c.expression(expr.Callee)
+	for _, arg := range expr.Arguments {
+		c.expression(arg)
+	}
+	// the parser allows at most 255 arguments
+	c.emit(OpCall, expr.Paren)
+	c.chunk().writeByte(byte(len(expr.Arguments)))
+	return nil, nil
+}
+
+// VisitGetExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitGetExpr(expr parser.Get) (any, error) {
+	c.expression(expr.Object)
+	c.emitConstant(OpGetProperty, expr.Name, expr.Name.Lexeme)
+	return nil, nil
+}
+
+// VisitSetExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitSetExpr(expr parser.Set) (any, error) {
+	c.expression(expr.Object)
+	c.expression(expr.Value)
+	c.emitConstant(OpSetProperty, expr.Name, expr.Name.Lexeme)
+	return nil, nil
+}
+
+// VisitSuperExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitSuperExpr(expr parser.Super) (any, error) {
+	depth, ok := c.locals[expr.Id()]
+	if !ok {
+		panic("unresolved super expression")
+	}
+
+	// this is declared in the scope right inside the one declaring super
+	c.load(c.scoped(c.scopes[len(c.scopes)-depth], "this"), expr.Keyword)
+	c.load(c.scoped(c.scopes[len(c.scopes)-1-depth], "super"), expr.Keyword)
+	c.emitConstant(OpGetSuper, expr.Method, expr.Method.Lexeme)
+	return nil, nil
+}
+
+// VisitThisExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitThisExpr(expr parser.This) (any, error) {
+	c.load(c.variable(expr, "this"), expr.Keyword)
+	return nil, nil
+}
+
+// VisitVariableExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitVariableExpr(expr parser.Variable) (any, error) {
+	c.load(c.variable(expr, expr.Name.Lexeme), expr.Name)
+	return nil, nil
+}
+
+// VisitGroupingExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitGroupingExpr(expr parser.Grouping) (any, error) {
+	c.expression(expr.Expression)
+	return nil, nil
+}
+
+// VisitLiteralExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitLiteralExpr(expr parser.Literal) (any, error) {
+	switch value := expr.Value.(type) {
+	case nil:
+		c.emitOp(OpNil)
+	case bool:
+		if value {
+			c.emitOp(OpTrue)
+		} else {
+			c.emitOp(OpFalse)
+		}
+	default:
+		// a full constant pool is reported at the literal
+		c.token = expr.Token
+		c.emitOp(OpConstant)
+		c.emitShort(c.constant(value))
+	}
+	return nil, nil
+}
+
+// VisitLogicalExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitLogicalExpr(expr parser.Logical) (any, error) {
+	c.expression(expr.Left)
+
+	switch expr.Operator.Type {
+	case token.AND:
+		endJump := c.emitJump(OpJumpIfFalse)
+		c.emitOp(OpPop)
+		c.expression(expr.Right)
+		c.patchJump(endJump)
+	case token.OR:
+		elseJump := c.emitJump(OpJumpIfFalse)
+		endJump := c.emitJump(OpJump)
+		c.patchJump(elseJump)
+		c.emitOp(OpPop)
+		c.expression(expr.Right)
+		c.patchJump(endJump)
+	default:
+		panic(fmt.Sprintf("unexpected logical operator: %v", expr.Operator.Type))
+	}
+	return nil, nil
+}
+
+// VisitUnaryExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitUnaryExpr(expr parser.Unary) (any, error) {
+	c.expression(expr.Right)
+
+	switch expr.Operator.Type {
+	case token.MINUS:
+		c.emit(OpNegate, expr.Operator)
+	case token.BANG:
+		c.emitOp(OpNot)
+	default:
+		panic(fmt.Sprintf("unexpected unary operator: %v", expr.Operator.Type))
+	}
+	return nil, nil
+}
+
+// VisitListExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitListExpr(expr parser.List) (any, error) {
+	for _, e := range expr.Elements {
+		c.expression(e)
+	}
+	c.emit(OpList, expr.Bracket)
+	if len(expr.Elements) > maxShort {
+		c.error("Too many elements in list literal.")
+	}
+	c.emitShort(len(expr.Elements))
+	return nil, nil
+}
+
+// VisitMapExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitMapExpr(expr parser.Map) (any, error) {
+	c.emitOp(OpMap)
+	for n, key := range expr.Keys {
+		c.expression(key)
+		c.expression(expr.Values[n])
+		c.emit(OpMapEntry, expr.Brace)
+	}
+	return nil, nil
+}
+
+// VisitIndexExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitIndexExpr(expr parser.Index) (any, error) {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+	c.emit(OpGetIndex, expr.Bracket)
+	return nil, nil
+}
+
+// VisitIndexSetExpr implements [parser.ExprVisitor].
+func (c *compiler) VisitIndexSetExpr(expr parser.IndexSet) (any, error) {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+	c.expression(expr.Value)
+	c.emit(OpSetIndex, expr.Bracket)
+	return nil, nil
+}
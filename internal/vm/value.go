@@ -0,0 +1,172 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// Object is a runtime value. Numbers, strings, booleans, nil, natives, lists
+// and maps are represented the same way as in the tree-walking interpreter,
+// so the two share their implementation.
+type Object = interpreter.Object
+
+// Function is a compiled function.
+type Function struct {
+	// Name is empty for the top-level code of a program or module.
+	name string
+	// Name of the class declaring this function as a method, empty otherwise.
+	class string
+	arity int
+	chunk Chunk
+	// Number of variables captured from enclosing functions.
+	upvalues int
+	// The module whose globals the function uses.
+	module *Module
+}
+
+func (f *Function) String() string {
+	if f.name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.name)
+}
+
+// Closure is a function along with the variables it captured.
+type Closure struct {
+	function *Function
+	upvalues []*Upvalue
+}
+
+func (c *Closure) String() string {
+	return c.function.String()
+}
+
+// Trace implements [interpreter.Traceable].
+func (c *Closure) Trace(t *interpreter.Tracer) {
+	if !t.Add(c, interpreter.ClosureSize) {
+		return
+	}
+	if c.function.module != nil {
+		t.Object(c.function.module)
+	}
+	for _, upvalue := range c.upvalues {
+		// open upvalues refer to the stack, which is traced anyway
+		if upvalue.closed {
+			t.Object(upvalue.value)
+		}
+	}
+}
+
+// Upvalue is a variable captured by a closure. It refers to a stack slot
+// while the variable is in scope and holds the value itself afterwards.
+type Upvalue struct {
+	slot   int
+	closed bool
+	value  Object
+}
+
+// Class is a Lox class. Inherited methods are copied into it when it is declared.
+type Class struct {
+	name    string
+	methods map[string]*Closure
+}
+
+func (c *Class) String() string {
+	return c.name
+}
+
+// Trace implements [interpreter.Traceable].
+func (c *Class) Trace(t *interpreter.Tracer) {
+	if !t.Add(c, 0) {
+		return
+	}
+	for _, method := range c.methods {
+		t.Object(method)
+	}
+}
+
+// Instance is an instance of a Lox class.
+type Instance struct {
+	class  *Class
+	fields map[string]Object
+}
+
+func (i *Instance) String() string {
+	return i.class.name + " instance"
+}
+
+// Trace implements [interpreter.Traceable].
+func (i *Instance) Trace(t *interpreter.Tracer) {
+	if !t.Add(i, interpreter.InstanceSize+len(i.fields)*interpreter.BindingSize) {
+		return
+	}
+	t.Object(i.class)
+	for _, value := range i.fields {
+		t.Object(value)
+	}
+}
+
+// BoundMethod is a method accessed on an instance, which becomes its this.
+type BoundMethod struct {
+	receiver *Instance
+	method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.method.String()
+}
+
+// Trace implements [interpreter.Traceable].
+func (b *BoundMethod) Trace(t *interpreter.Tracer) {
+	if t.Add(b, 0) {
+		t.Object(b.receiver)
+		t.Object(b.method)
+	}
+}
+
+// Module is a Lox file loaded by an import statement, with its own globals.
+type Module struct {
+	name    string
+	globals map[string]Object
+	// The module's top-level code, nil for the main program.
+	script *Closure
+	// Whether the script has started running. A module runs at most once,
+	// however many times it is imported.
+	loaded bool
+}
+
+func newModule(name string) *Module {
+	return &Module{name: name, globals: make(map[string]Object)}
+}
+
+// Get returns the top-level declaration of the module called name.
+func (m *Module) Get(name token.Token) (Object, error) {
+	if value, ok := m.globals[name.Lexeme]; ok {
+		return value, nil
+	}
+
+	return nil, errors.RuntimeErrorAtToken(
+		name,
+		"Undefined property '"+name.Lexeme+"'.",
+	)
+}
+
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
+
+// Trace implements [interpreter.Traceable].
+func (m *Module) Trace(t *interpreter.Tracer) {
+	if !t.Add(m, len(m.globals)*interpreter.BindingSize) {
+		return
+	}
+	for _, value := range m.globals {
+		t.Object(value)
+	}
+	if m.script != nil {
+		t.Object(m.script)
+	}
+}
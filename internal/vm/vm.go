@@ -0,0 +1,799 @@
+// Package vm runs Lox programs by compiling them to bytecode for a stack
+// machine. It is an alternative to the tree-walking interpreter and behaves
+// the same, down to the runtime errors it reports.
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+type VM struct {
+	// The values in use: the locals and temporaries of every active call.
+	stack []Object
+	// The calls in progress, outermost first.
+	frames []frame
+	// The upvalues still referring to a stack slot, by ascending slot.
+	openUpvalues []*Upvalue
+	// The natives and values defined by the host, shared by every module.
+	builtins map[string]Object
+	// The module programs run in, unless they are imported.
+	main *Module
+	// A map of variable usages (via node identity) to
+	// the number of scopes between them and their declaration.
+	locals map[parser.NodeID]int
+	// The module each import statement was linked to.
+	imports map[parser.NodeID]*Module
+	// Where print statements write their output.
+	stdout io.Writer
+	// The steps taken by the running program, and the limits it runs under.
+	// Programs have no statements left once compiled, so every call and
+	// every jump back to the start of a loop counts as a step instead.
+	budget interpreter.Budget
+	// The files programs may access.
+	sandbox interpreter.Sandbox
+}
+
+// frame is a function call in progress.
+type frame struct {
+	closure *Closure
+	// Offset of the next instruction in the function's code.
+	ip int
+	// Stack index of slot 0.
+	base int
+	// The closing parenthesis of the call.
+	call token.Token
+	// How the call appears in tracebacks: the function and class names.
+	// The function name is empty for the top-level code of a program or
+	// module and for calls made by the host, which don't appear.
+	function, class string
+}
+
+// New creates a VM with its own global environment,
+// so independent VMs never observe each other's variables.
+func New() *VM {
+	vm := &VM{
+		builtins: make(map[string]Object),
+		main:     newModule("main"),
+		locals:   make(map[parser.NodeID]int),
+		imports:  make(map[parser.NodeID]*Module),
+		stdout:   os.Stdout,
+		budget:   interpreter.NewBudget(),
+	}
+	for _, native := range interpreter.Natives() {
+		vm.builtins[native.Name()] = native
+	}
+	for name, value := range interpreter.Constants() {
+		vm.builtins[name] = value
+	}
+	return vm
+}
+
+// Resolve implements [resolver.Interpreter].
+func (vm *VM) Resolve(expr parser.Expr, depth int) {
+	vm.locals[expr.Id()] = depth
+}
+
+// SetStdout redirects the output of print statements to w.
+func (vm *VM) SetStdout(w io.Writer) {
+	vm.stdout = w
+}
+
+// Stdout implements [interpreter.Host].
+func (vm *VM) Stdout() io.Writer {
+	return vm.stdout
+}
+
+// SetLimits bounds the work done by the programs run from now on.
+func (vm *VM) SetLimits(l interpreter.Limits) {
+	vm.budget.SetLimits(l)
+}
+
+// Sandbox implements [interpreter.Host].
+func (vm *VM) Sandbox() *interpreter.Sandbox {
+	return &vm.sandbox
+}
+
+// Allocate implements [interpreter.Host].
+func (vm *VM) Allocate(n int) error {
+	return vm.budget.Allocate(n)
+}
+
+// Usage returns the work done by the last program run.
+func (vm *VM) Usage() interpreter.Usage {
+	return vm.budget.Usage()
+}
+
+// Define binds name to value in the environment shared by all modules,
+// redefining it if it already exists.
+func (vm *VM) Define(name string, value Object) {
+	vm.builtins[name] = value
+}
+
+// Global returns the value bound to name in the global environment,
+// or, failing that, in the environment shared by all modules.
+func (vm *VM) Global(name string) (Object, bool) {
+	if value, ok := vm.main.globals[name]; ok {
+		return value, true
+	}
+	value, ok := vm.builtins[name]
+	return value, ok
+}
+
+// NewModule compiles program, which must already be resolved, into a module
+// named name, after its file, that runs the first time it is imported.
+func (vm *VM) NewModule(name string, program []parser.Stmt) (*Module, error) {
+	module := newModule(name)
+	function, err := vm.compile(program, module, false)
+	if err != nil {
+		return nil, err
+	}
+	module.script = &Closure{function: function}
+	return module, nil
+}
+
+// Link binds an import statement to the module it loads, much like
+// Resolve binds a variable to its scope.
+func (vm *VM) Link(stmt parser.Import, module *Module) {
+	vm.imports[stmt.Id()] = module
+}
+
+// Interpret compiles and runs prog. Limits of the bytecode format,
+// such as the number of constants in a function, are reported as static
+// errors before anything runs.
+func (vm *VM) Interpret(prog []parser.Stmt) error {
+	return vm.interpret(context.Background(), prog, false)
+}
+
+// InterpretContext runs prog like Interpret, stopping it with a runtime
+// error if ctx is done before it ends. The error wraps the one of ctx.
+func (vm *VM) InterpretContext(ctx context.Context, prog []parser.Stmt) error {
+	return vm.interpret(ctx, prog, false)
+}
+
+// InterpretInteractive executes prog like Interpret and also prints the
+// value of every top-level expression statement, unless it is nil.
+func (vm *VM) InterpretInteractive(prog []parser.Stmt) error {
+	return vm.interpret(context.Background(), prog, true)
+}
+
+func (vm *VM) interpret(ctx context.Context, prog []parser.Stmt, interactive bool) error {
+	defer vm.budget.Start(ctx, vm.roots)()
+	function, err := vm.compile(prog, vm.main, interactive)
+	if err != nil {
+		return err
+	}
+
+	script := &Closure{function: function}
+	depth := len(vm.frames)
+	vm.push(script)
+	vm.pushFrame(script, 0, token.Token{}, "", "")
+	if err := vm.run(depth); err != nil {
+		return err
+	}
+	vm.pop()
+	return nil
+}
+
+// Callable returns the arity of value if it can be called.
+func (vm *VM) Callable(value Object) (interpreter.Arity, bool) {
+	switch callee := value.(type) {
+	case *Closure:
+		return interpreter.ExactArity(callee.function.arity), true
+	case *BoundMethod:
+		return interpreter.ExactArity(callee.method.function.arity), true
+	case *Class:
+		if init, ok := callee.methods["init"]; ok {
+			return interpreter.ExactArity(init.function.arity), true
+		}
+		return interpreter.ExactArity(0), true
+	case *interpreter.NativeFunction:
+		return callee.Arity(), true
+	}
+	return interpreter.Arity{}, false
+}
+
+// hostCall is the call site of calls made by the host, which have none.
+// The budget isn't checked there, as there is nothing to report an error
+// at, but at the loops and calls of the callee.
+var hostCall = token.Token{}
+
+// Call calls callee like CallContext, under a context that is never done.
+func (vm *VM) Call(callee Object, args []Object) (Object, error) {
+	return vm.CallContext(context.Background(), callee, args)
+}
+
+// CallContext calls callee, which must accept len(args) arguments, from Go.
+// The call is a run of its own, stopped once ctx is done. It gets the full
+// budget, unless made while a program runs, which it is then charged to.
+// The call itself doesn't appear in the traceback of runtime errors.
+func (vm *VM) CallContext(ctx context.Context, callee Object, args []Object) (Object, error) {
+	defer vm.budget.Start(ctx, vm.roots)()
+	// called like the tree-walking interpreter does, leaving its errors
+	// for the host to report
+	if native, ok := callee.(*interpreter.NativeFunction); ok {
+		return native.Invoke(vm, args)
+	}
+
+	depth, base := len(vm.frames), len(vm.stack)
+	vm.push(callee)
+	vm.stack = append(vm.stack, args...)
+
+	if err := vm.callValue(callee, len(args), hostCall); err != nil {
+		vm.stack = vm.stack[:base]
+		return nil, err
+	}
+	if len(vm.frames) > depth {
+		vm.frames[depth].function = ""
+		if err := vm.run(depth); err != nil {
+			return nil, err
+		}
+	}
+	return vm.pop(), nil
+}
+
+func (vm *VM) push(value Object) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() Object {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+// peek returns the value distance slots down from the top of the stack.
+func (vm *VM) peek(distance int) Object {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// run executes instructions until the frame count drops back to depth.
+// On error, the calls above depth are abandoned.
+func (vm *VM) run(depth int) error {
+	for {
+		f := &vm.frames[len(vm.frames)-1]
+		chunk := &f.closure.function.chunk
+		start := f.ip
+		op := OpCode(chunk.code[f.ip])
+		f.ip++
+
+		// readShort reads a 16-bit operand.
+		readShort := func() int {
+			n := chunk.readShort(f.ip)
+			f.ip += 2
+			return n
+		}
+		// fail abandons the running calls because of err, which occurred
+		// in the current instruction unless it is a runtime error already.
+		fail := func(err error) error {
+			if _, ok := err.(errors.RuntimeError); !ok {
+				err = errors.RuntimeErrorAtToken(chunk.tokenAt(start), err.Error())
+			}
+			err = vm.traced(err)
+			vm.unwind(depth)
+			return err
+		}
+		failf := func(format string, args ...any) error {
+			return fail(fmt.Errorf(format, args...))
+		}
+
+		switch op {
+		case OpConstant:
+			vm.push(chunk.constants[readShort()])
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+
+		case OpGetLocal:
+			vm.push(vm.stack[f.base+readShort()])
+		case OpSetLocal:
+			vm.stack[f.base+readShort()] = vm.peek(0)
+		case OpGetUpvalue:
+			upvalue := f.closure.upvalues[readShort()]
+			if upvalue.closed {
+				vm.push(upvalue.value)
+			} else {
+				vm.push(vm.stack[upvalue.slot])
+			}
+		case OpSetUpvalue:
+			upvalue := f.closure.upvalues[readShort()]
+			if upvalue.closed {
+				upvalue.value = vm.peek(0)
+			} else {
+				vm.stack[upvalue.slot] = vm.peek(0)
+			}
+
+		case OpGetGlobal:
+			name := chunk.constants[readShort()].(string)
+			value, ok := f.closure.function.module.globals[name]
+			if !ok {
+				value, ok = vm.builtins[name]
+			}
+			if !ok {
+				return failf("Undefined variable '%s'.", name)
+			}
+			vm.push(value)
+		case OpDefineGlobal:
+			name := chunk.constants[readShort()].(string)
+			globals := f.closure.function.module.globals
+			if _, ok := globals[name]; !ok {
+				vm.budget.Alloc(interpreter.BindingSize)
+			}
+			globals[name] = vm.pop()
+		case OpSetGlobal:
+			name := chunk.constants[readShort()].(string)
+			globals := f.closure.function.module.globals
+			if _, ok := globals[name]; ok {
+				globals[name] = vm.peek(0)
+			} else if _, ok := vm.builtins[name]; ok {
+				vm.builtins[name] = vm.peek(0)
+			} else {
+				return failf("Undefined variable '%s'.", name)
+			}
+
+		case OpGetProperty:
+			name := chunk.constants[readShort()].(string)
+			value, err := vm.property(vm.pop(), chunk.tokenAt(start), name)
+			if err != nil {
+				return fail(err)
+			}
+			vm.push(value)
+		case OpSetProperty:
+			name := chunk.constants[readShort()].(string)
+			value := vm.pop()
+			instance, ok := vm.pop().(*Instance)
+			if !ok {
+				return failf("Only instances have fields.")
+			}
+			if _, ok := instance.fields[name]; !ok {
+				vm.budget.Alloc(interpreter.BindingSize)
+				if err := vm.budget.Check(chunk.tokenAt(start)); err != nil {
+					return fail(err)
+				}
+			}
+			instance.fields[name] = value
+			vm.push(value)
+		case OpGetSuper:
+			name := chunk.constants[readShort()].(string)
+			superclass := vm.pop().(*Class)
+			this := vm.pop().(*Instance)
+			method, ok := superclass.methods[name]
+			if !ok {
+				return failf("Undefined property '%s'.", name)
+			}
+			vm.push(&BoundMethod{this, method})
+
+		case OpGetIndex:
+			index := vm.pop()
+			var value Object
+			var err error
+			switch collection := vm.pop().(type) {
+			case *interpreter.LoxList:
+				value, err = collection.At(chunk.tokenAt(start), index)
+			case *interpreter.LoxMap:
+				value, err = collection.At(chunk.tokenAt(start), index)
+			default:
+				err = fmt.Errorf("Only lists and maps can be indexed.")
+			}
+			if err != nil {
+				return fail(err)
+			}
+			vm.push(value)
+		case OpSetIndex:
+			value, index := vm.pop(), vm.pop()
+			var err error
+			switch collection := vm.pop().(type) {
+			case *interpreter.LoxList:
+				err = collection.SetAt(chunk.tokenAt(start), index, value)
+			case *interpreter.LoxMap:
+				err = vm.setEntry(collection, chunk.tokenAt(start), index, value)
+			default:
+				err = fmt.Errorf("Only lists and maps can be indexed.")
+			}
+			if err != nil {
+				return fail(err)
+			}
+			vm.push(value)
+
+		case OpEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(a == b)
+		case OpNotEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(a != b)
+		case OpAdd:
+			b, a := vm.pop(), vm.pop()
+			switch a := a.(type) {
+			case float64:
+				if b, ok := b.(float64); ok {
+					vm.push(a + b)
+					continue
+				}
+			case string:
+				if b, ok := b.(string); ok {
+					vm.budget.Alloc(interpreter.StringBytes(a) + len(b))
+					if err := vm.budget.Check(chunk.tokenAt(start)); err != nil {
+						return fail(err)
+					}
+					vm.push(a + b)
+					continue
+				}
+			}
+			return failf("Operands must be two numbers or two strings.")
+		case OpSubtract, OpMultiply, OpDivide, OpGreater, OpGreaterEqual, OpLess, OpLessEqual:
+			b, bok := vm.pop().(float64)
+			a, aok := vm.pop().(float64)
+			if !aok || !bok {
+				return failf("Operands must be numbers.")
+			}
+			switch op {
+			case OpSubtract:
+				vm.push(a - b)
+			case OpMultiply:
+				vm.push(a * b)
+			case OpDivide:
+				if b == 0 {
+					return failf("Division by zero.")
+				}
+				vm.push(a / b)
+			case OpGreater:
+				vm.push(a > b)
+			case OpGreaterEqual:
+				vm.push(a >= b)
+			case OpLess:
+				vm.push(a < b)
+			case OpLessEqual:
+				vm.push(a <= b)
+			}
+		case OpNot:
+			vm.push(!isTruthy(vm.pop()))
+		case OpNegate:
+			value, ok := vm.pop().(float64)
+			if !ok {
+				return failf("Operand must be a number.")
+			}
+			vm.push(-value)
+
+		case OpPrint:
+			fmt.Fprintln(vm.stdout, interpreter.Stringify(vm.pop()))
+		case OpEcho:
+			if value := vm.pop(); value != nil {
+				fmt.Fprintln(vm.stdout, interpreter.Stringify(value))
+			}
+
+		case OpJump:
+			offset := readShort()
+			f.ip += offset
+		case OpJumpIfFalse:
+			offset := readShort()
+			if !isTruthy(vm.peek(0)) {
+				f.ip += offset
+			}
+		case OpLoop:
+			offset := readShort()
+			vm.budget.Step()
+			if err := vm.budget.Check(chunk.tokenAt(start)); err != nil {
+				return fail(err)
+			}
+			f.ip -= offset
+
+		case OpCall:
+			argc := int(chunk.code[f.ip])
+			f.ip++
+			if err := vm.callValue(vm.peek(argc), argc, chunk.tokenAt(start)); err != nil {
+				return fail(err)
+			}
+		case OpClosure:
+			function := chunk.constants[readShort()].(*Function)
+			closure := &Closure{function, make([]*Upvalue, function.upvalues)}
+			vm.budget.Alloc(interpreter.ClosureSize)
+			for i := range closure.upvalues {
+				isLocal := chunk.code[f.ip] == 1
+				f.ip++
+				index := readShort()
+				if isLocal {
+					closure.upvalues[i] = vm.capture(f.base + index)
+				} else {
+					closure.upvalues[i] = f.closure.upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case OpCloseUpvalue:
+			vm.close(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.close(f.base)
+			vm.stack = vm.stack[:f.base]
+			vm.budget.Free(frameBytes(f.closure))
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.push(result)
+			if len(vm.frames) == depth {
+				return nil
+			}
+
+		case OpClass:
+			name := chunk.constants[readShort()].(string)
+			vm.push(&Class{name, make(map[string]*Closure)})
+		case OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				return failf("Superclass must be a class.")
+			}
+			subclass := vm.pop().(*Class)
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+		case OpMethod:
+			name := chunk.constants[readShort()].(string)
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*Class).methods[name] = method
+
+		case OpList:
+			count := readShort()
+			elements := slices.Clone(vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.budget.Alloc(interpreter.ListBytes(len(elements)))
+			if err := vm.budget.Check(chunk.tokenAt(start)); err != nil {
+				return fail(err)
+			}
+			vm.push(interpreter.NewLoxList(elements))
+		case OpMap:
+			vm.budget.Alloc(interpreter.MapSize)
+			vm.push(interpreter.NewLoxMap())
+		case OpMapEntry:
+			value, key := vm.pop(), vm.pop()
+			if err := vm.setEntry(vm.peek(0).(*interpreter.LoxMap), chunk.tokenAt(start), key, value); err != nil {
+				return fail(err)
+			}
+
+		case OpImport:
+			module := chunk.constants[readShort()].(*Module)
+			vm.push(module)
+			if module.loaded {
+				vm.push(nil)
+				continue
+			}
+			module.loaded = true
+			vm.push(module.script)
+			vm.pushFrame(module.script, 0, token.Token{}, "", "")
+
+		default:
+			panic(fmt.Sprintf("unknown opcode %d", op))
+		}
+	}
+}
+
+// property returns the property called name of obj, reporting errors at tok.
+func (vm *VM) property(obj Object, tok token.Token, name string) (Object, error) {
+	switch obj := obj.(type) {
+	case *Instance:
+		if value, ok := obj.fields[name]; ok {
+			return value, nil
+		}
+		if method, ok := obj.class.methods[name]; ok {
+			return &BoundMethod{obj, method}, nil
+		}
+		return nil, errors.RuntimeErrorAtToken(tok, "Undefined property '"+name+"'.")
+	case *interpreter.LoxList:
+		return obj.Get(tok)
+	case *interpreter.LoxMap:
+		return obj.Get(tok)
+	case *Module:
+		return obj.Get(tok)
+	}
+	return nil, errors.RuntimeErrorAtToken(tok, "Only instances have properties.")
+}
+
+// callValue calls callee with the argc arguments on top of the stack,
+// from a call whose closing parenthesis is paren. Lox functions get a new
+// frame, which starts running with the next instruction, while other
+// callees leave their result in place of the callee and arguments.
+func (vm *VM) callValue(callee Object, argc int, paren token.Token) error {
+	vm.budget.Step()
+	if err := vm.check(paren); err != nil {
+		return err
+	}
+	switch callee := callee.(type) {
+	case *Closure:
+		return vm.call(callee, argc, paren, callee.function.class)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argc-1] = callee.receiver
+		return vm.call(callee.method, argc, paren, callee.method.function.class)
+	case *Class:
+		vm.stack[len(vm.stack)-argc-1] = &Instance{callee, make(map[string]Object)}
+		vm.budget.Alloc(interpreter.InstanceSize)
+		if err := vm.check(paren); err != nil {
+			return err
+		}
+		if init, ok := callee.methods["init"]; ok {
+			// the constructor is reported under the class called
+			return vm.call(init, argc, paren, callee.name)
+		}
+		if argc != 0 {
+			return errors.RuntimeErrorAtToken(
+				paren,
+				fmt.Sprintf("Expected 0 arguments but got %d.", argc),
+			)
+		}
+		return nil
+	case *interpreter.NativeFunction:
+		if arity := callee.Arity(); !arity.Accepts(argc) {
+			return errors.RuntimeErrorAtToken(
+				paren,
+				fmt.Sprintf("Expected %s arguments but got %d.", arity, argc),
+			)
+		}
+		args := slices.Clone(vm.stack[len(vm.stack)-argc:])
+		result, err := callee.Invoke(vm, args)
+		if err != nil {
+			if _, ok := err.(errors.RuntimeError); !ok {
+				err = errors.RuntimeErrorAtToken(paren, err.Error())
+			}
+			return err
+		}
+		vm.stack = vm.stack[:len(vm.stack)-argc-1]
+		vm.push(result)
+		return vm.check(paren)
+	}
+
+	return errors.RuntimeErrorAtToken(paren, "Can only call functions and classes.")
+}
+
+// check returns a runtime error at paren if the program used up its budget.
+func (vm *VM) check(paren token.Token) error {
+	if paren == hostCall {
+		return nil
+	}
+	return vm.budget.Check(paren)
+}
+
+// call enters closure with the argc arguments on top of the stack.
+func (vm *VM) call(closure *Closure, argc int, paren token.Token, class string) error {
+	if arity := closure.function.arity; argc != arity {
+		return errors.RuntimeErrorAtToken(
+			paren,
+			fmt.Sprintf("Expected %d arguments but got %d.", arity, argc),
+		)
+	}
+	// the frame of the script doesn't count, as it isn't a call
+	if err := vm.budget.CheckDepth(paren, len(vm.frames)-1); err != nil {
+		return err
+	}
+	vm.pushFrame(closure, argc, paren, closure.function.name, class)
+	return nil
+}
+
+func (vm *VM) pushFrame(closure *Closure, argc int, paren token.Token, function, class string) {
+	vm.budget.Alloc(frameBytes(closure))
+	vm.frames = append(vm.frames, frame{
+		closure:  closure,
+		base:     len(vm.stack) - argc - 1,
+		call:     paren,
+		function: function,
+		class:    class,
+	})
+}
+
+// traced attaches the Lox calls in progress to err if it is a runtime
+// error that does not have a traceback yet.
+func (vm *VM) traced(err error) error {
+	runtimeErr, ok := err.(errors.RuntimeError)
+	if !ok || len(runtimeErr.Trace()) > 0 {
+		return err
+	}
+
+	var trace []errors.Frame
+	for _, f := range vm.frames {
+		if f.function != "" {
+			trace = append(trace, errors.Frame{Function: f.function, Class: f.class, Call: f.call})
+		}
+	}
+	if len(trace) == 0 {
+		return err
+	}
+	return runtimeErr.WithTrace(trace)
+}
+
+// frameBytes returns the size of a frame calling closure, which is charged
+// like an environment holding the parameters.
+func frameBytes(closure *Closure) int {
+	return interpreter.EnvironmentSize + closure.function.arity*interpreter.BindingSize
+}
+
+// roots adds the values the program can reach to t: those of the modules,
+// and the locals and temporaries of the calls in progress, which are
+// charged along with their frames.
+func (vm *VM) roots(t *interpreter.Tracer) {
+	for _, value := range vm.builtins {
+		t.Object(value)
+	}
+	t.Object(vm.main)
+	for _, module := range vm.imports {
+		t.Object(module)
+	}
+	for _, value := range vm.stack {
+		t.Object(value)
+	}
+	for n, f := range vm.frames {
+		t.Add(&vm.frames[n], frameBytes(f.closure))
+		t.Object(f.closure)
+	}
+}
+
+// setEntry stores value under key in m like [interpreter.LoxMap.SetAt],
+// charging new entries to the budget.
+func (vm *VM) setEntry(m *interpreter.LoxMap, bracket token.Token, key Object, value Object) error {
+	size := len(m.Keys())
+	if err := m.SetAt(bracket, key, value); err != nil {
+		return err
+	}
+	vm.budget.Alloc((len(m.Keys()) - size) * interpreter.BindingSize)
+	return vm.budget.Check(bracket)
+}
+
+// unwind abandons the calls above depth.
+func (vm *VM) unwind(depth int) {
+	for _, f := range vm.frames[depth:] {
+		vm.budget.Free(frameBytes(f.closure))
+	}
+	base := vm.frames[depth].base
+	vm.close(base)
+	vm.stack = vm.stack[:base]
+	vm.frames = vm.frames[:depth]
+}
+
+// capture returns the upvalue referring to the stack slot, creating it
+// unless another closure already captured the same variable.
+func (vm *VM) capture(slot int) *Upvalue {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= slot {
+		if vm.openUpvalues[i-1].slot == slot {
+			return vm.openUpvalues[i-1]
+		}
+		i--
+	}
+
+	upvalue := &Upvalue{slot: slot}
+	vm.openUpvalues = slices.Insert(vm.openUpvalues, i, upvalue)
+	return upvalue
+}
+
+// close moves the variables in the stack slots from slot up, which are
+// going out of scope, into the upvalues capturing them.
+func (vm *VM) close(slot int) {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= slot {
+		upvalue := vm.openUpvalues[i-1]
+		upvalue.value = vm.stack[upvalue.slot]
+		upvalue.closed = true
+		i--
+	}
+	vm.openUpvalues = vm.openUpvalues[:i]
+}
+
+// isTruthy returns whether obj should be considered true in a boolean context.
+// In Lox , false and nil are falsey, and everything else is truthy.
+func isTruthy(obj Object) bool {
+	if obj == nil {
+		return false
+	}
+	if boolean, ok := obj.(bool); ok {
+		return boolean
+	}
+
+	return true
+}
@@ -0,0 +1,328 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/resolver"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/stretchr/testify/require"
+)
+
+func compileProgramForTest(t *testing.T, vm *VM, source string) []parser.Stmt {
+	t.Helper()
+	r := require.New(t)
+
+	scanner := scanner.NewScanner(source)
+	tokens, err := scanner.ScanTokens()
+	r.NoError(err)
+
+	parser := parser.NewParser(tokens)
+	prog, err := parser.Parse()
+	r.NoError(err)
+
+	resolver := resolver.NewResolver(vm)
+	_, err = resolver.Resolve(prog)
+	r.NoError(err)
+	return prog
+}
+
+// runProgramForTest runs source on a new VM and returns what it printed.
+func runProgramForTest(t *testing.T, source string) (string, error) {
+	t.Helper()
+
+	var stdout bytes.Buffer
+	vm := New()
+	vm.SetStdout(&stdout)
+	err := vm.Interpret(compileProgramForTest(t, vm, source))
+	return stdout.String(), err
+}
+
+func TestVMProgramsSuccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "arithmetic and comparison",
+			source: `print 1 + 2 * 3; print (1 + 2) * 3; print 10 / 4; print -2 < 1; print 1 >= 1; print "a" + "b";`,
+			want:   "7\n9\n2.5\ntrue\ntrue\nab\n",
+		},
+		{
+			name:   "equality and truthiness",
+			source: `print nil == nil; print 1 == "1"; print 1 != 2; print !nil; print !0;`,
+			want:   "true\nfalse\ntrue\ntrue\nfalse\n",
+		},
+		{
+			name:   "logical operators return an operand",
+			source: `print nil or "yes"; print 1 and 2; print false and boom;`,
+			want:   "yes\n2\nfalse\n",
+		},
+		{
+			name:   "locals shadow globals",
+			source: `var a = "global"; { var a = "outer"; { var a = "inner"; print a; } print a; } print a;`,
+			want:   "inner\nouter\nglobal\n",
+		},
+		{
+			name: "closures share captured variables",
+			source: `
+fun counter() {
+  var i = 0;
+  fun inc() { i = i + 1; return i; }
+  fun get() { return i; }
+  inc();
+  return get;
+}
+print counter()();`,
+			want: "1\n",
+		},
+		{
+			name: "closures capture through intermediate functions",
+			source: `
+fun outer() {
+  var a = "a";
+  fun middle() {
+    fun inner() { return a; }
+    return inner;
+  }
+  return middle();
+}
+print outer()();`,
+			want: "a\n",
+		},
+		{
+			name: "each loop iteration gets fresh body variables",
+			source: `
+var fns = [];
+for (var i = 0; i < 3; i = i + 1) {
+  var j = i;
+  fun f() { return j; }
+  fns.push(f);
+}
+print fns[0]() + fns[1]() + fns[2]();`,
+			want: "3\n",
+		},
+		{
+			name: "break and continue pop the loop body",
+			source: `
+var sum = 0;
+for (var i = 0; i < 10; i = i + 1) {
+  var odd = i - (i / 2 - i / 2);
+  if (i == 2) continue;
+  if (i == 5) break;
+  sum = sum + i;
+}
+print sum;`,
+			want: "8\n",
+		},
+		{
+			name: "classes, initializers and inheritance",
+			source: `
+class A {
+  init(name) { this.name = name; }
+  greet() { return "hi " + this.name; }
+}
+class B < A {
+  greet() { return super.greet() + "!"; }
+}
+var b = B("lox");
+print b.greet();
+print b;
+print B;
+print b.init("again").name;`,
+			want: "hi lox!\nB instance\nB\nagain\n",
+		},
+		{
+			name: "bound methods remember their receiver",
+			source: `
+class Box { init(v) { this.v = v; } get() { return this.v; } }
+var get = Box(7).get;
+print get();
+print get;`,
+			want: "7\n<fn get>\n",
+		},
+		{
+			name:   "lists and maps",
+			source: `var l = [1, "two"]; l[0] = 3; print l; var m = {"k": l}; print m["k"][1]; print m.size();`,
+			want:   "[3, \"two\"]\ntwo\n1\n",
+		},
+		{
+			name:   "natives",
+			source: `print clock() > 0; print clock;`,
+			want:   "true\n<native fn clock>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			got, err := runProgramForTest(t, tt.source)
+
+			r.NoError(err)
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestVMRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		wantError string
+		wantTrace []string
+	}{
+		{
+			name:      "undefined variable",
+			source:    "print missing;",
+			wantError: "Undefined variable 'missing'.\n[line 1]",
+		},
+		{
+			name:      "mixed addition",
+			source:    "print 1 + \"a\";",
+			wantError: "Operands must be two numbers or two strings.\n[line 1]",
+		},
+		{
+			name:      "division by zero",
+			source:    "print 1 / 0;",
+			wantError: "Division by zero.\n[line 1]",
+		},
+		{
+			name:      "calling a non-callable",
+			source:    "var a = 1;\na();",
+			wantError: "Can only call functions and classes.\n[line 2]",
+		},
+		{
+			name:      "wrong argument count",
+			source:    "fun f(a) {}\nf();",
+			wantError: "Expected 1 arguments but got 0.\n[line 2]",
+		},
+		{
+			name:      "inheriting from a non-class",
+			source:    "var A = 1;\nclass B < A {}",
+			wantError: "Superclass must be a class.\n[line 2]",
+		},
+		{
+			name:      "errors in calls are traced",
+			source:    "class A { init() { f(); } }\nfun f() { return nil.x; }\nA();",
+			wantError: "Only instances have properties.\n[line 2]",
+			wantTrace: []string{"A.init()", "f()"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			_, err := runProgramForTest(t, tt.source)
+
+			var runtimeErr errors.RuntimeError
+			r.ErrorAs(err, &runtimeErr)
+			r.Equal(tt.wantError, fmt.Sprintf("%s\n[line %d]", runtimeErr.Message(), runtimeErr.Line()))
+			var trace []string
+			for _, frame := range runtimeErr.Trace() {
+				trace = append(trace, frame.String())
+			}
+			r.Equal(tt.wantTrace, trace)
+		})
+	}
+}
+
+func TestVMRecoversAfterRuntimeError(t *testing.T) {
+	r := require.New(t)
+
+	var stdout bytes.Buffer
+	vm := New()
+	vm.SetStdout(&stdout)
+
+	r.Error(vm.Interpret(compileProgramForTest(t, vm, `var a = 1; fun f() { var b = 2; return b + nil; } f();`)))
+	r.NoError(vm.Interpret(compileProgramForTest(t, vm, `print a;`)))
+
+	r.Equal("1\n", stdout.String())
+	r.Empty(vm.stack)
+	r.Empty(vm.frames)
+}
+
+func TestVMCompileErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "globals", line: "var v%d;\n", want: "[line 65537] Error at 'v65536': Too many constants in one chunk."},
+		{name: "literals", line: "print %d;\n", want: "[line 65537] Error at '65536': Too many constants in one chunk."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			var source strings.Builder
+			for i := range maxShort + 2 {
+				fmt.Fprintf(&source, tc.line, i)
+			}
+
+			vm := New()
+			err := vm.Interpret(compileProgramForTest(t, vm, source.String()))
+
+			var staticErr errors.StaticError
+			r.ErrorAs(err, &staticErr)
+			r.Equal(maxShort+2, staticErr.Line())
+			r.EqualError(staticErr, tc.want)
+		})
+	}
+}
+
+func TestVMInstancesHaveIsolatedGlobals(t *testing.T) {
+	r := require.New(t)
+
+	first, second := New(), New()
+	r.NoError(first.Interpret(compileProgramForTest(t, first, `var foo = "first";`)))
+	r.NoError(second.Interpret(compileProgramForTest(t, second, `var bar = "second";`)))
+
+	foo, ok := first.Global("foo")
+	r.True(ok)
+	r.Equal("first", foo)
+	_, ok = first.Global("bar")
+	r.False(ok)
+	_, ok = second.Global("foo")
+	r.False(ok)
+}
+
+func TestVMCallFromHost(t *testing.T) {
+	r := require.New(t)
+
+	vm := New()
+	vm.Define("base", float64(40))
+	r.NoError(vm.Interpret(compileProgramForTest(t, vm, `
+fun add(a) { return base + a; }
+class Point { init(x) { this.x = x; } }
+`)))
+
+	add, _ := vm.Global("add")
+	arity, ok := vm.Callable(add)
+	r.True(ok)
+	r.Equal(interpreter.ExactArity(1), arity)
+	result, err := vm.Call(add, []Object{float64(2)})
+	r.NoError(err)
+	r.Equal(float64(42), result)
+
+	point, _ := vm.Global("Point")
+	instance, err := vm.Call(point, []Object{float64(1)})
+	r.NoError(err)
+	r.Equal("Point instance", interpreter.Stringify(instance))
+
+	_, err = vm.Call(add, []Object{"a"})
+	r.EqualError(err, "Operands must be two numbers or two strings.\n[line 2]")
+	r.Empty(vm.stack)
+	r.Empty(vm.frames)
+
+	_, ok = vm.Callable(float64(1))
+	r.False(ok)
+}
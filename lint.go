@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nt54hamnghi/golox/pkg/lox"
+)
+
+const lintUsage = "Usage: glox lint [--json] [--strict] [file ...]"
+
+// runLint checks Lox files, or stdin when no file is given, for likely
+// mistakes and prints one warning per line as `file:line:column: message
+// [rule]`. With --json, it prints them as an array of objects instead.
+// It returns the process exit code: 65 when a file doesn't compile, 66 when
+// one can't be read and, with --strict, 1 when there are warnings.
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("golox lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the warnings as a JSON array")
+	strict := flags.Bool("strict", false, "exit with code 1 when there are warnings")
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, lintUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 64
+	}
+
+	code := 0
+	warnings := []lox.Warning{}
+	lint := func(name, source string) {
+		found, err := lox.Lint(name, source)
+		if err != nil {
+			fmt.Fprintln(stderr, render(err))
+			code = max(code, 65)
+			return
+		}
+		warnings = append(warnings, found...)
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		bytes, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 74
+		}
+		lint("<stdin>", string(bytes))
+	}
+	for _, path := range files {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = max(code, 66)
+			continue
+		}
+		lint(path, string(bytes))
+	}
+
+	if *asJSON {
+		bytes, err := json.MarshalIndent(warnings, "", "  ")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 70
+		}
+		fmt.Fprintln(stdout, string(bytes))
+	} else {
+		for _, w := range warnings {
+			fmt.Fprintf(stdout, "%s:%d:%d: %s [%s]\n", w.File, w.Line, w.Column, w.Message, w.Rule)
+		}
+	}
+
+	if *strict && len(warnings) > 0 {
+		code = max(code, 1)
+	}
+	return code
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLILintRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		wantStdout string
+	}{
+		{
+			name: "clean programs have no warnings",
+			source: `var total = 0;
+fun add(a, b) { return a + b; }
+fun sum(_unused) {
+  for (var i = 0; i < 3; i = i + 1) total = add(total, i);
+  return total;
+}
+print sum(nil);
+`,
+		},
+		{
+			name: "unused local variables and parameters",
+			source: `var global = 1;
+fun f(used, unused, _ignored) {
+  var local = used;
+  fun helper() {}
+  class Local {}
+  var _skipped;
+}
+`,
+			wantStdout: "<stdin>:2:13: Parameter 'unused' is never used. [unused-parameter]\n" +
+				"<stdin>:3:7: Local variable 'local' is never used. [unused-variable]\n" +
+				"<stdin>:4:7: Local function 'helper' is never used. [unused-variable]\n" +
+				"<stdin>:5:9: Local class 'Local' is never used. [unused-variable]\n",
+		},
+		{
+			name: "assigned but never read",
+			source: `{
+  var a = 1;
+  a = 2;
+}
+`,
+			wantStdout: "<stdin>:2:7: Local variable 'a' is never used. [unused-variable]\n",
+		},
+		{
+			name: "shadowing",
+			source: `var a = 1;
+fun f(a) {
+  {
+    var a = 2;
+    print a;
+  }
+  return a;
+}
+`,
+			wantStdout: "<stdin>:2:7: 'a' shadows the declaration on line 1. [shadowing]\n" +
+				"<stdin>:4:9: 'a' shadows the declaration on line 2. [shadowing]\n",
+		},
+		{
+			name: "unreachable code",
+			source: `fun f() {
+  return 1;
+  print "never";
+}
+while (true) {
+  break;
+  print "never";
+}
+`,
+			wantStdout: "<stdin>:2:3: Code after 'return' is unreachable. [unreachable-code]\n" +
+				"<stdin>:6:3: Code after 'break' is unreachable. [unreachable-code]\n",
+		},
+		{
+			name: "self-assignment",
+			source: `var a = 1;
+a = a;
+class P {
+  init(x) {
+    this.x = this.x;
+    this.x = x.x;
+  }
+}
+`,
+			wantStdout: "<stdin>:2:1: 'a' is assigned to itself. [self-assignment]\n" +
+				"<stdin>:5:10: Property 'x' is assigned to itself. [self-assignment]\n",
+		},
+		{
+			name: "this in a function nested in a method",
+			source: `class C {
+  m() {
+    fun f() { return this; }
+    return f() == this;
+  }
+}
+`,
+			wantStdout: "<stdin>:3:22: 'this' in a function refers to the instance of the enclosing method. [this-in-function]\n",
+		},
+		{
+			name: "argument count of known functions and classes",
+			source: `fun one(a) { return a; }
+class Point { init(_x, _y) {} }
+class Empty {}
+class Sub < Point {}
+one();
+Point(1);
+Empty(1);
+Sub(1);
+fun f() {
+  fun local() {}
+  local(1);
+}
+`,
+			wantStdout: "<stdin>:5:1: 'one' takes 1 argument but is called with 0. [argument-count]\n" +
+				"<stdin>:6:1: 'Point' takes 2 arguments but is called with 1. [argument-count]\n" +
+				"<stdin>:7:1: 'Empty' takes 0 arguments but is called with 1. [argument-count]\n" +
+				"<stdin>:11:3: 'local' takes 0 arguments but is called with 1. [argument-count]\n",
+		},
+		{
+			name: "calls to reassigned functions are not checked",
+			source: `fun f(a) { return a; }
+fun g() {}
+fun h() {}
+f = g;
+var h = f;
+f();
+g(1);
+h();
+`,
+			wantStdout: "<stdin>:7:1: 'g' takes 0 arguments but is called with 1. [argument-count]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			var stdout, stderr bytes.Buffer
+			exitCode := run([]string{"lint"}, strings.NewReader(tt.source), &stdout, &stderr)
+
+			r.Equal(0, exitCode)
+			r.Equal(tt.wantStdout, stdout.String())
+			r.Empty(stderr.String())
+		})
+	}
+}
+
+func TestCLILintJSONAndStrict(t *testing.T) {
+	r := require.New(t)
+	paths := writeFiles(t, "print 1;\n", "fun f(x) {}\n")
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run(append([]string{"lint", "--json", "--strict"}, paths...), strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(1, exitCode)
+	r.JSONEq(`[
+		{"rule": "unused-parameter", "file": "src/b.lox", "line": 1, "column": 7, "message": "Parameter 'x' is never used."}
+	]`, stdout.String())
+	r.Empty(stderr.String())
+
+	stdout.Reset()
+	exitCode = run([]string{"lint", "--json", "--strict", paths[0]}, strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Equal("[]\n", stdout.String())
+}
+
+func TestCLILintErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		files        []string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{
+			name:         "resolution errors are reported and the other files linted",
+			args:         []string{"lint"},
+			files:        []string{"return 1;", "{ var a; }"},
+			wantExitCode: 65,
+			wantStdout:   "src/b.lox:1:7: Local variable 'a' is never used. [unused-variable]\n",
+			wantStderr: "[line 1] Error at 'return': Can't return from top-level code.\n" +
+				" --> src/a.lox:1:1\n" +
+				"  |\n" +
+				"1 | return 1;\n" +
+				"  | ^~~~~~\n",
+		},
+		{
+			name:         "missing files are reported",
+			args:         []string{"lint", "--strict", "missing.lox"},
+			wantExitCode: 66,
+			wantStderr:   "open missing.lox: no such file or directory\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			paths := writeFiles(t, tt.files...)
+
+			var stdout, stderr bytes.Buffer
+			exitCode := run(append(tt.args, paths...), strings.NewReader(""), &stdout, &stderr)
+
+			r.Equal(tt.wantExitCode, exitCode)
+			r.Equal(tt.wantStdout, stdout.String())
+			r.Equal(tt.wantStderr, stderr.String())
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nt54hamnghi/golox/internal/lsp"
+)
+
+const lspUsage = "Usage: glox lsp"
+
+// runLSP serves the Language Server Protocol over stdin and stdout until
+// the editor exits. Problems with the connection are logged to stderr.
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("golox lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, lspUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 64
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintln(stdout, lspUsage)
+		return 64
+	}
+
+	return lsp.NewServer(stdin, stdout, stderr).Run()
+}
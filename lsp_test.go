@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLILSP(t *testing.T) {
+	r := require.New(t)
+
+	var stdin strings.Builder
+	for _, body := range []string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"capabilities": {}}}`,
+		`{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": {"textDocument": {"uri": "file:///a.lox", "version": 1, "text": "print 1"}}}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "shutdown"}`,
+		`{"jsonrpc": "2.0", "method": "exit"}`,
+	} {
+		fmt.Fprintf(&stdin, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"lsp"}, strings.NewReader(stdin.String()), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Contains(stdout.String(), `"method":"textDocument/publishDiagnostics"`)
+	r.Contains(stdout.String(), `"message":"Expect ';' after value."`)
+	r.Contains(stdout.String(), `{"jsonrpc":"2.0","id":2,"result":null}`)
+	r.Empty(stderr.String())
+}
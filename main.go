@@ -3,117 +3,295 @@ package main
 import (
 	"bufio"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
-	internalErrors "github.com/nt54hamnghi/golox/internal/errors"
-	"github.com/nt54hamnghi/golox/internal/interpreter"
-	"github.com/nt54hamnghi/golox/internal/parser"
-	"github.com/nt54hamnghi/golox/internal/resolver"
-	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/nt54hamnghi/golox/internal/debug"
+	"github.com/nt54hamnghi/golox/pkg/lox"
 )
 
-var in interpreter.Interpreter = interpreter.NewInterpreter()
-
 func main() {
 	args := os.Args
 	if len(args) < 1 {
 		panic("not enough arguments")
 	}
 
-	args = args[1:]
+	os.Exit(run(args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// cli holds the standard streams of one invocation of the program.
+type cli struct {
+	engine *lox.Engine
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+const usage = `Usage: glox [--vm] [--allow-read=dir ...] [--allow-write=dir ...] [script]
+       glox --debug [--break file:line ...] script
+       glox --dump-ast=sexpr|json [script]
+       glox fmt [--check | --write] [--indent n] [file ...]
+       glox tokenize [--json] [file]
+       glox lint [--json] [--strict] [file ...]
+       glox lsp
+       glox dap`
+
+// run executes the command line args against the given standard streams
+// and returns the process exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "fmt":
+			return runFmt(args[1:], stdin, stdout, stderr)
+		case "tokenize":
+			return runTokenize(args[1:], stdin, stdout, stderr)
+		case "lint":
+			return runLint(args[1:], stdin, stdout, stderr)
+		case "lsp":
+			return runLSP(args[1:], stdin, stdout, stderr)
+		case "dap":
+			return runDAP(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	flags := flag.NewFlagSet("golox", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	useVM := flags.Bool("vm", false, "run programs on the bytecode VM instead of the tree-walking interpreter")
+	dumpAST := flags.String("dump-ast", "", "print the syntax tree of the program as `sexpr` or json instead of running it")
+	debugging := flags.Bool("debug", false, "stop at breakpoints and read debugger commands from stdin")
+	var breakpoints []debug.Location
+	flags.Func("break", "stop whenever the program enters `file:line`, implies --debug", func(s string) error {
+		loc, err := debug.ParseLocation(s)
+		breakpoints = append(breakpoints, loc)
+		return err
+	})
+	var readable, writable []string
+	flags.Func("allow-read", "let the program read the files under `dir`, may be repeated", func(s string) error {
+		readable = append(readable, s)
+		return nil
+	})
+	flags.Func("allow-write", "let the program write the files under `dir`, may be repeated", func(s string) error {
+		writable = append(writable, s)
+		return nil
+	})
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 64
+	}
+	args = flags.Args()
 
 	if len(args) > 1 {
-		fmt.Println("Usage: glox [script]")
-		os.Exit(64)
-	} else if len(args) == 1 {
-		if err := runFile(args[0]); err != nil {
-			panic(err)
+		fmt.Fprintln(stdout, usage)
+		return 64
+	}
+
+	if *dumpAST != "" {
+		var format lox.ASTFormat
+		switch *dumpAST {
+		case "sexpr":
+			format = lox.SExpr
+		case "json":
+			format = lox.JSON
+		default:
+			fmt.Fprintln(stderr, "golox: --dump-ast must be sexpr or json")
+			return 64
+		}
+		return runDumpAST(args, format, stdin, stdout, stderr)
+	}
+
+	*debugging = *debugging || len(breakpoints) > 0
+	if *debugging && *useVM {
+		fmt.Fprintln(stderr, "golox: --debug can't be used with --vm")
+		return 64
+	}
+	if *debugging && len(args) == 0 {
+		fmt.Fprintln(stderr, "golox: --debug needs a script")
+		return 64
+	}
+
+	backend := lox.TreeWalker
+	if *useVM {
+		backend = lox.VM
+	}
+
+	engine := lox.NewEngineWithBackend(backend)
+	engine.SetStdout(stdout)
+	// modules not found next to the importing file are searched for in LOXPATH
+	if path := os.Getenv("LOXPATH"); path != "" {
+		engine.SetSearchPath(filepath.SplitList(path)...)
+	}
+	// programs can only access the files under the directories allowed
+	for _, dir := range readable {
+		if err := engine.AllowRead(dir); err != nil {
+			fmt.Fprintln(stderr, "golox: --allow-read:", err)
+			return 64
+		}
+	}
+	for _, dir := range writable {
+		if err := engine.AllowWrite(dir); err != nil {
+			fmt.Fprintln(stderr, "golox: --allow-write:", err)
+			return 64
 		}
+	}
+
+	// the debugger reads its commands from stdin, and writes to stderr
+	// to keep the output of the program apart
+	if *debugging {
+		d := debug.New(debug.NewConsole(stdin, stderr))
+		for _, loc := range breakpoints {
+			d.SetBreakpoint(loc)
+		}
+		if err := engine.Debug(d); err != nil {
+			fmt.Fprintln(stderr, "golox:", err)
+			return 64
+		}
+	}
+
+	c := cli{engine, stdin, stdout, stderr}
+
+	if len(args) == 1 {
+		return c.runFile(args[0])
 	} else {
-		runPrompt()
+		return c.runPrompt()
 	}
 }
 
 var hadError bool
 
+// runDumpAST prints the syntax tree of the script named by args, or of
+// stdin when there is none, without running it.
+func runDumpAST(args []string, format lox.ASTFormat, stdin io.Reader, stdout, stderr io.Writer) int {
+	name, source, code := readSource(args, stdin, stderr)
+	if code != 0 {
+		return code
+	}
+
+	ast, err := lox.DumpAST(name, source, format)
+	if err != nil {
+		fmt.Fprintln(stderr, render(err))
+		return 65
+	}
+	fmt.Fprint(stdout, ast)
+	return 0
+}
+
+// readSource reads the file named by args, or stdin when there is none,
+// and returns its name and content. A non-zero code is the exit code for
+// a failed read, which has already been reported to stderr.
+func readSource(args []string, stdin io.Reader, stderr io.Writer) (name string, source string, code int) {
+	if len(args) == 0 {
+		bytes, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return "", "", 74
+		}
+		return "<stdin>", string(bytes), 0
+	}
+
+	bytes, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return "", "", 66
+	}
+	return args[0], string(bytes), 0
+}
+
 // Reads the file path and executes its content.
-func runFile(path string) error {
+func (c cli) runFile(path string) int {
 
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return err
+		fmt.Fprintln(c.stderr, err)
+		return 66
 	}
 
 	bytes, err := os.ReadFile(absPath)
 	if err != nil {
-		return err
+		fmt.Fprintln(c.stderr, err)
+		return 66
 	}
 
-	err = run(string(bytes))
+	err = c.engine.RunScript(path, string(bytes))
+	if errors.Is(err, debug.ErrQuit) {
+		return 0
+	}
 	if err != nil {
-		exit(err)
+		return c.exit(err)
 	}
 
-	return nil
+	return 0
 }
 
-// Execute in interactive mode (REPL)
-func runPrompt() error {
-	scanner := bufio.NewScanner(os.Stdin)
+// Execute in interactive mode (REPL).
+// Lines are collected until they form complete statements, so declarations
+// can span several lines, and the value of a bare expression is printed.
+func (c cli) runPrompt() int {
+	scanner := bufio.NewScanner(c.stdin)
+	var input strings.Builder
 
 	for {
-		fmt.Print("> ")
+		if input.Len() == 0 {
+			fmt.Fprint(c.stdout, "> ")
+		} else {
+			fmt.Fprint(c.stdout, ". ")
+		}
 		if !scanner.Scan() {
 			break
 		}
-		line := scanner.Text()
-		if err := run(line); err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
+		input.WriteString(scanner.Text())
+		input.WriteString("\n")
+
+		err := c.engine.RunInteractive("<stdin>", input.String())
+		var loxErr *lox.Error
+		if errors.As(err, &loxErr) && loxErr.Incomplete() {
+			continue
 		}
+		if err != nil {
+			fmt.Fprintln(c.stderr, render(err))
+		}
+		input.Reset()
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
+	// the input ended in the middle of a statement
+	if input.Len() > 0 {
+		fmt.Fprintln(c.stdout)
+		fmt.Fprintln(c.stderr, render(c.engine.RunInteractive("<stdin>", input.String())))
 	}
-	return nil
-}
 
-func run(src string) error {
-	sc := scanner.NewScanner(src)
-	tokens, err := sc.ScanTokens()
-	if err != nil {
-		return err
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 74
 	}
+	return 0
+}
 
-	pa := parser.NewParser(tokens)
-	prog := pa.Parse()
-
-	re := resolver.NewResolver(&in)
-	if _, err := re.Resolve(prog); err != nil {
-		return err
-	}
+// exit reports err and returns the exit code for it:
+// 70 for runtime errors and 65 for any other failure.
+func (c cli) exit(err error) int {
+	fmt.Fprintln(c.stderr, render(err))
 
-	err = in.Interpret(prog)
-	if err != nil {
-		return err
+	var loxErr *lox.Error
+	if errors.As(err, &loxErr) && loxErr.Stage == lox.RuntimeStage {
+		return 70
 	}
 
-	// var printer AstPrinter
-	// repr := printer.String(expr)
-	// fmt.Println(repr)
-
-	return nil
+	return 65
 }
 
-func exit(err error) {
-	fmt.Fprintln(os.Stderr, err)
-
-	var runtimeErr internalErrors.RuntimeError
-	if errors.As(err, &runtimeErr) {
-		os.Exit(70)
+// render formats err for the user, pointing into the source when possible.
+func render(err error) string {
+	var loxErr *lox.Error
+	if errors.As(err, &loxErr) {
+		return loxErr.Render()
 	}
-
-	os.Exit(65)
+	return err.Error()
 }
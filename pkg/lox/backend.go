@@ -0,0 +1,108 @@
+package lox
+
+import (
+	"context"
+	"io"
+
+	internalErrors "github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/resolver"
+	"github.com/nt54hamnghi/golox/internal/vm"
+)
+
+// Backend selects how an [Engine] executes programs.
+// Both backends accept the same programs and report the same errors.
+type Backend int
+
+const (
+	// TreeWalker evaluates the syntax tree directly. It is the default.
+	TreeWalker Backend = iota
+	// VM compiles programs to bytecode and runs them on a stack machine.
+	VM
+)
+
+func (b Backend) String() string {
+	return [...]string{"tree-walker", "vm"}[b]
+}
+
+// backend executes resolved programs for an engine.
+type backend interface {
+	resolver.Interpreter
+	SetStdout(w io.Writer)
+	Define(name string, value interpreter.Object)
+	Global(name string) (interpreter.Object, bool)
+	SetLimits(l interpreter.Limits)
+	Usage() interpreter.Usage
+	Sandbox() *interpreter.Sandbox
+	InterpretContext(ctx context.Context, prog []parser.Stmt) error
+	InterpretInteractive(prog []parser.Stmt) error
+	// NewModule prepares a resolved program to run when it is first imported.
+	NewModule(name string, prog []parser.Stmt) (module, error)
+	// Link binds an import statement to a module returned by NewModule.
+	Link(stmt parser.Import, m module)
+	// Callable returns the arity of value if it can be called.
+	Callable(value interpreter.Object) (interpreter.Arity, bool)
+	// CallContext calls callee, which accepts len(args) arguments, as a
+	// run of its own, stopping once ctx is done.
+	CallContext(ctx context.Context, callee interpreter.Object, args []interpreter.Object) (interpreter.Object, error)
+}
+
+// module is a loaded module, in the representation of the backend that loaded it.
+type module any
+
+func newBackend(b Backend) backend {
+	if b == VM {
+		return bytecodeVM{vm.New()}
+	}
+	interp := interpreter.NewInterpreter()
+	return treeWalker{&interp}
+}
+
+// treeWalker adapts [interpreter.Interpreter] to backend.
+type treeWalker struct {
+	*interpreter.Interpreter
+}
+
+func (t treeWalker) NewModule(name string, prog []parser.Stmt) (module, error) {
+	return t.Interpreter.NewModule(name, prog), nil
+}
+
+func (t treeWalker) Link(stmt parser.Import, m module) {
+	t.Interpreter.Link(stmt, m.(*interpreter.LoxModule))
+}
+
+func (t treeWalker) Callable(value interpreter.Object) (interpreter.Arity, bool) {
+	fun, ok := value.(interpreter.Callable)
+	if !ok {
+		return interpreter.Arity{}, false
+	}
+	return fun.Arity(), true
+}
+
+func (t treeWalker) CallContext(ctx context.Context, callee interpreter.Object, args []interpreter.Object) (interpreter.Object, error) {
+	return t.Interpreter.CallContext(ctx, callee.(interpreter.Callable), args)
+}
+
+// bytecodeVM adapts [vm.VM] to backend.
+type bytecodeVM struct {
+	*vm.VM
+}
+
+func (b bytecodeVM) NewModule(name string, prog []parser.Stmt) (module, error) {
+	return b.VM.NewModule(name, prog)
+}
+
+func (b bytecodeVM) Link(stmt parser.Import, m module) {
+	b.VM.Link(stmt, m.(*vm.Module))
+}
+
+// runStage returns the stage to report an error returned by a backend in.
+// The VM rejects programs exceeding the limits of its bytecode with static
+// errors before running them.
+func runStage(err error) Stage {
+	if _, ok := err.(internalErrors.StaticError); ok {
+		return ResolveStage
+	}
+	return RuntimeStage
+}
@@ -0,0 +1,45 @@
+package lox
+
+import (
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+)
+
+// ASTFormat selects how [DumpAST] prints a syntax tree.
+type ASTFormat int
+
+const (
+	// SExpr prints one S-expression per top-level statement.
+	SExpr ASTFormat = iota
+	// JSON prints an array with one object per top-level statement.
+	JSON
+)
+
+// String returns the name of the format, as accepted by `--dump-ast`.
+func (f ASTFormat) String() string {
+	return [...]string{"sexpr", "json"}[f]
+}
+
+// DumpAST parses source, read from the file called name, and returns its
+// syntax tree printed in the given format. The program is not resolved or run.
+// A program that fails to scan or parse is reported as an [*Error].
+func DumpAST(name string, source string, format ASTFormat) (string, error) {
+	sources := map[string]string{name: source}
+
+	sc := scanner.NewFileScanner(name, source)
+	tokens, err := sc.ScanTokens()
+	if err != nil {
+		return "", newError(ScanStage, err, sources)
+	}
+
+	pa := parser.NewParser(tokens)
+	prog, err := pa.Parse()
+	if err != nil {
+		return "", newError(ParseStage, err, sources)
+	}
+
+	if format == JSON {
+		return parser.JSONPrinter{}.Program(prog)
+	}
+	return parser.AstPrinter{}.Program(prog), nil
+}
@@ -0,0 +1,127 @@
+package lox
+
+import (
+	"maps"
+
+	internalErrors "github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// Stage identifies the pipeline stage that rejected a program.
+type Stage int
+
+const (
+	ScanStage Stage = iota
+	ParseStage
+	ResolveStage
+	RuntimeStage
+)
+
+func (s Stage) String() string {
+	return [...]string{"scan", "parse", "resolve", "runtime"}[s]
+}
+
+// Diagnostic is a single problem found in a program.
+type Diagnostic struct {
+	// File is the name the program was run under, empty if it had none.
+	File string
+	// Line is the 1-based source line, or 0 when the location is unknown.
+	Line int
+	// Column is the 1-based column, in characters, or 0 when unknown.
+	Column  int
+	Message string
+}
+
+// Frame is a Lox function call that was in progress when a runtime error occurred.
+type Frame struct {
+	// Function is the name of the called function, "init" for class constructors.
+	Function string
+	// Class is the name of the class declaring the function, empty for plain functions.
+	Class string
+	// File, Line and Column locate the call site.
+	File   string
+	Line   int
+	Column int
+}
+
+// Error is returned when a program fails to scan, parse, resolve or run.
+// Its message is the same report the golox CLI prints.
+type Error struct {
+	Stage Stage
+	// Diagnostics lists every problem reported by the stage, in source order.
+	Diagnostics []Diagnostic
+	// Trace lists the calls in progress when a runtime error occurred,
+	// outermost first. It is empty for errors raised in top-level code.
+	Trace []Frame
+	err   error
+	// The content of the files the diagnostics may point into, by name.
+	sources map[string]string
+	// Whether the program failed only because its source ended too early.
+	incomplete bool
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying internal error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Render formats the error the way the golox CLI reports it, with the
+// offending source line and a caret under the exact lexeme of each diagnostic.
+func (e *Error) Render() string {
+	return internalErrors.Render(e.err, e.sources)
+}
+
+// Incomplete reports whether the error was caused by the source ending
+// in the middle of a declaration or statement, in which case appending
+// more input may make it valid. Only errors returned by
+// [Engine.RunInteractive] can be incomplete.
+func (e *Error) Incomplete() bool {
+	return e.incomplete
+}
+
+// located is implemented by errors that know their source position.
+type located interface {
+	Position() token.Position
+	Message() string
+}
+
+// newError wraps err, which was reported by stage, along with a snapshot
+// of the sources it may point into.
+func (e *Engine) newError(stage Stage, err error) *Error {
+	return newError(stage, err, maps.Clone(e.sources))
+}
+
+func newError(stage Stage, err error, sources map[string]string) *Error {
+	var errs []error
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = multi.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+
+	diagnostics := make([]Diagnostic, 0, len(errs))
+	for _, e := range errs {
+		if e == nil {
+			continue
+		}
+		if l, ok := e.(located); ok {
+			pos := l.Position()
+			diagnostics = append(diagnostics, Diagnostic{pos.File, pos.Line, pos.Column, l.Message()})
+		} else {
+			diagnostics = append(diagnostics, Diagnostic{Message: e.Error()})
+		}
+	}
+
+	var trace []Frame
+	if runtimeErr, ok := err.(internalErrors.RuntimeError); ok {
+		for _, f := range runtimeErr.Trace() {
+			trace = append(trace, Frame{f.Function, f.Class, f.Call.File, f.Call.Line, f.Call.Column})
+		}
+	}
+
+	return &Error{stage, diagnostics, trace, err, sources, false}
+}
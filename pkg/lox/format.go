@@ -0,0 +1,25 @@
+package lox
+
+import (
+	"github.com/nt54hamnghi/golox/internal/format"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+)
+
+// DefaultIndent is the indent width used by `golox fmt` unless told otherwise.
+const DefaultIndent = format.DefaultIndent
+
+// Format reprints source, read from the file called name, in the canonical
+// style of `golox fmt`, indenting each nesting level by indent spaces.
+// Comments and single blank lines between statements are kept.
+// A program that fails to scan or parse is reported as an [*Error].
+func Format(name string, source string, indent int) (string, error) {
+	formatted, err := format.Source(name, source, format.Options{Indent: indent})
+	if err != nil {
+		stage := ParseStage
+		if _, ok := err.(scanner.ScannerError); ok {
+			stage = ScanStage
+		}
+		return "", newError(stage, err, map[string]string{name: source})
+	}
+	return formatted, nil
+}
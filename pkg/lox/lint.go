@@ -0,0 +1,59 @@
+package lox
+
+import (
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/resolver"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+)
+
+// Warning is a problem found by [Lint]: code that is valid Lox,
+// but probably not what was meant.
+type Warning struct {
+	// Rule names the check that found the problem, such as "unused-variable".
+	Rule string `json:"rule"`
+	// File is the name the program was linted under, empty if it had none.
+	File string `json:"file"`
+	// Line and Column locate the offending token, both 1-based.
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// Lint checks source, read from the file called name, for likely mistakes:
+// unused local variables and parameters, declarations shadowing an outer
+// one, unreachable code, self-assignments, this in a function nested in a
+// method, and calls to known functions or classes with the wrong number
+// of arguments. Imported modules are not checked.
+// A program that fails to scan, parse or resolve is reported as an [*Error].
+func Lint(name string, source string) ([]Warning, error) {
+	sources := map[string]string{name: source}
+
+	sc := scanner.NewFileScanner(name, source)
+	tokens, err := sc.ScanTokens()
+	if err != nil {
+		return nil, newError(ScanStage, err, sources)
+	}
+
+	pa := parser.NewParser(tokens)
+	prog, err := pa.Parse()
+	if err != nil {
+		return nil, newError(ParseStage, err, sources)
+	}
+
+	r := resolver.NewResolver(unresolved{})
+	found, err := r.Lint(prog)
+	if err != nil {
+		return nil, newError(ResolveStage, err, sources)
+	}
+
+	warnings := make([]Warning, len(found))
+	for n, w := range found {
+		warnings[n] = Warning{w.Rule, w.Token.File, w.Token.Line, w.Token.Column, w.Message}
+	}
+	return warnings, nil
+}
+
+// unresolved is a [resolver.Interpreter] for programs that are only checked.
+type unresolved struct{}
+
+func (unresolved) Resolve(expr parser.Expr, depth int) {}
@@ -0,0 +1,394 @@
+// Package lox embeds the golox interpreter in Go programs.
+//
+// An [Engine] wraps the whole scan, parse, resolve and interpret pipeline.
+// Programs run on the same engine share their global state, so a host can
+// define globals, register Go functions, run scripts and read the results back:
+//
+//	engine := lox.NewEngine()
+//	engine.SetStdout(&buf)
+//	engine.Register("double", 1, func(args []lox.Value) (lox.Value, error) {
+//		return args[0].(float64) * 2, nil
+//	})
+//	if err := engine.Run(`var answer = double(21);`); err != nil {
+//		// handle *lox.Error
+//	}
+//	answer, _ := engine.Get("answer") // float64(42)
+package lox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nt54hamnghi/golox/internal/debug"
+	"github.com/nt54hamnghi/golox/internal/interpreter"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/resolver"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// Value is a Lox runtime value as seen from Go.
+//
+// Numbers are float64, strings are string, booleans are bool and Lox nil is Go nil.
+// Functions, classes, instances, lists and maps are opaque values that can only be passed back
+// into the engine that produced them.
+type Value = any
+
+// Func is the signature of Go functions callable from Lox.
+// args always satisfies the arity the function was registered with.
+// A returned error becomes a runtime error reported at the call site.
+type Func func(args []Value) (Value, error)
+
+// Limits bounds the work done by the programs an engine runs, so that
+// programs that can't be trusted can't run forever, exhaust memory or
+// crash the process. All fields are optional:
+//
+//   - Steps is the number of statements and calls a program may execute,
+//     unlimited if 0. The [VM] backend counts calls and loop iterations
+//     instead, as statements don't survive compilation.
+//   - MaxDepth is the number of nested calls allowed, [DefaultMaxDepth]
+//     if 0.
+//   - Memory is the approximate number of bytes a program may hold,
+//     unlimited if 0, as accounted by [Usage].
+//
+// A program going past a limit fails with a runtime error: "Step limit
+// exceeded.", "Stack overflow." or "Memory limit exceeded.".
+// Each run, and each call from Go, starts with its full budget of steps,
+// except calls made by registered functions, which count against the
+// program calling them.
+type Limits = interpreter.Limits
+
+// Usage is the work done by a program, as reported by [Engine.Usage].
+//
+// Memory is accounted approximately: strings, instances, closures, lists,
+// maps, environments and, on the [VM] backend, call frames are charged an
+// estimate of their size when they are created. They are held as long as
+// the program can reach them from its variables, the values it is working
+// on or the calls in progress, which is measured now and then, so Peak may
+// include values that were no longer reachable.
+type Usage = interpreter.Usage
+
+// DefaultMaxDepth is the number of nested calls allowed unless [Limits]
+// says otherwise.
+const DefaultMaxDepth = interpreter.DefaultMaxDepth
+
+// Engine runs Lox programs against a global environment that persists across runs.
+// An Engine is not safe for concurrent use.
+type Engine struct {
+	backend  backend
+	resolver resolver.Resolver
+	// Number of lines consumed by RunInteractive so far.
+	lines int
+	// The content of every file run or imported, by the name used in diagnostics.
+	sources map[string]string
+	// Loaded modules by absolute path.
+	modules map[string]module
+	// Directories searched for modules not found next to the importing file.
+	searchPath []string
+	// Told about every file parsed, nil when not debugging.
+	debugger *debug.Debugger
+}
+
+// NewEngine creates an engine whose globals hold only the built-in natives.
+// Output goes to the process stdout until redirected.
+// Programs are run by the tree-walking interpreter.
+func NewEngine() *Engine {
+	return NewEngineWithBackend(TreeWalker)
+}
+
+// NewEngineWithBackend creates an engine like [NewEngine]
+// that runs programs on the given backend.
+func NewEngineWithBackend(b Backend) *Engine {
+	e := &Engine{
+		backend: newBackend(b),
+		sources: make(map[string]string),
+		modules: make(map[string]module),
+	}
+	e.resolver = resolver.NewResolver(e.backend)
+	return e
+}
+
+// SetStdout redirects the output of print statements to w.
+func (e *Engine) SetStdout(w io.Writer) {
+	e.backend.SetStdout(w)
+}
+
+// Define binds name to value in the global scope, replacing any existing binding.
+func (e *Engine) Define(name string, value Value) {
+	e.backend.Define(name, value)
+}
+
+// Register exposes fn to Lox code as a global function called name.
+// Calls with a number of arguments other than arity fail with a runtime error.
+func (e *Engine) Register(name string, arity int, fn Func) {
+	e.backend.Define(name, interpreter.NewNativeFunction(name, interpreter.ExactArity(arity), nil, native(fn)))
+}
+
+// RegisterVariadic exposes fn to Lox code as a global function called name
+// that accepts minArity or more arguments.
+func (e *Engine) RegisterVariadic(name string, minArity int, fn Func) {
+	e.backend.Define(name, interpreter.NewNativeFunction(name, interpreter.VariadicArity(minArity), nil, native(fn)))
+}
+
+// Get returns the value of the global variable name.
+func (e *Engine) Get(name string) (Value, bool) {
+	return e.backend.Global(name)
+}
+
+// SetLimits bounds the work done by every program run from now on.
+// Each run gets the full budget of steps.
+func (e *Engine) SetLimits(l Limits) {
+	e.backend.SetLimits(l)
+}
+
+// Usage returns the work done by the last program run, or call from Go,
+// including the peak of its memory use, whether it succeeded or not.
+func (e *Engine) Usage() Usage {
+	return e.backend.Usage()
+}
+
+// AllowRead lets programs read the files under dir with readFile,
+// listDir and exists. Programs can't access files unless the host allows
+// them, and paths leading out of the allowed directories, including
+// through symbolic links, fail with a runtime error.
+func (e *Engine) AllowRead(dir string) error {
+	return e.backend.Sandbox().AllowRead(dir)
+}
+
+// AllowWrite lets programs create, change and remove the files under dir
+// with writeFile, appendFile and removeFile. Like [Engine.AllowRead], it
+// grants no access outside of dir.
+func (e *Engine) AllowWrite(dir string) error {
+	return e.backend.Sandbox().AllowWrite(dir)
+}
+
+// Run scans, parses, resolves and executes source.
+// Global state left by the program stays visible to later calls.
+// A failure in any stage is reported as an [*Error], and a program
+// with syntax errors is never executed.
+func (e *Engine) Run(source string) error {
+	return e.RunScript("", source)
+}
+
+// RunScript runs source like [Engine.Run], reporting diagnostics
+// as coming from a file called name.
+//
+// Modules imported by source are looked up relative to the directory
+// of name, then in the search path.
+func (e *Engine) RunScript(name string, source string) error {
+	return e.RunScriptContext(context.Background(), name, source)
+}
+
+// RunContext runs source like [Engine.Run], stopping it if ctx is done
+// before it ends. The returned [*Error] then wraps the error of ctx.
+func (e *Engine) RunContext(ctx context.Context, source string) error {
+	return e.RunScriptContext(ctx, "", source)
+}
+
+// RunScriptContext runs source like [Engine.RunScript], stopping it if ctx
+// is done before it ends. The returned [*Error] then wraps the error of ctx.
+func (e *Engine) RunScriptContext(ctx context.Context, name string, source string) error {
+	e.sources[name] = source
+
+	sc := scanner.NewFileScanner(name, source)
+	tokens, err := sc.ScanTokens()
+	if err != nil {
+		return e.newError(ScanStage, err)
+	}
+
+	pa := parser.NewParser(tokens)
+	prog, err := pa.Parse()
+	if err != nil {
+		return e.newError(ParseStage, err)
+	}
+	e.parsed(name, source, &pa)
+
+	if err := e.link(name, prog); err != nil {
+		return err
+	}
+
+	if _, err := e.resolver.Resolve(prog); err != nil {
+		return e.newError(ResolveStage, err)
+	}
+
+	if err := e.backend.InterpretContext(ctx, prog); err != nil {
+		return e.newError(runStage(err), err)
+	}
+
+	return nil
+}
+
+// Debug attaches d to the engine, which then pauses the programs it runs
+// wherever d says. Only programs run by the [TreeWalker] backend can be
+// debugged.
+func (e *Engine) Debug(d *debug.Debugger) error {
+	tw, ok := e.backend.(treeWalker)
+	if !ok {
+		return fmt.Errorf("the %s backend can't be debugged", VM)
+	}
+	d.Attach(tw.Interpreter)
+	e.debugger = d
+	return nil
+}
+
+// parsed tells the debugger, if any, about a file that pa just parsed.
+func (e *Engine) parsed(name string, source string, pa *parser.Parser) {
+	if e.debugger != nil {
+		e.debugger.Load(name, source, pa.Spans())
+	}
+}
+
+// RunInteractive runs source as one input of an interactive session.
+// Unlike RunScript, the final expression statement may omit its semicolon
+// and the value of every top-level expression statement other than nil is
+// printed. Line numbers continue from the previous input, so errors in
+// functions defined earlier point at the line they were typed on.
+//
+// If source stops in the middle of a declaration or statement, the
+// returned [*Error] is [Error.Incomplete] and no input is consumed:
+// the caller is expected to read more and try again with the longer source.
+func (e *Engine) RunInteractive(name string, source string) error {
+	e.sources[name] = source
+
+	sc := scanner.NewFileScannerAtLine(name, source, e.lines+1)
+	tokens, err := sc.ScanTokens()
+	if err != nil {
+		loxErr := e.newError(ScanStage, err)
+		if loxErr.incomplete = incomplete(source, tokens, err); !loxErr.incomplete {
+			e.lines += countLines(source)
+		}
+		return loxErr
+	}
+
+	pa := parser.NewParser(tokens)
+	prog, err := pa.ParseInteractive()
+	if err != nil {
+		loxErr := e.newError(ParseStage, err)
+		if loxErr.incomplete = incomplete(source, tokens, err); !loxErr.incomplete {
+			e.lines += countLines(source)
+		}
+		return loxErr
+	}
+
+	e.lines += countLines(source)
+	e.parsed(name, source, &pa)
+
+	if err := e.link(name, prog); err != nil {
+		return err
+	}
+
+	if _, err := e.resolver.Resolve(prog); err != nil {
+		return e.newError(ResolveStage, err)
+	}
+
+	if err := e.backend.InterpretInteractive(prog); err != nil {
+		return e.newError(runStage(err), err)
+	}
+
+	return nil
+}
+
+// incomplete reports whether the scan or parse error err was caused by
+// source ending too early: a parenthesis, bracket or brace is still open,
+// a string literal runs to the end, or the parser ran out of tokens.
+func incomplete(source string, tokens []token.Token, err error) bool {
+	depth := 0
+	for _, t := range tokens {
+		switch t.Type {
+		case token.LEFT_PAREN, token.LEFT_BRACE, token.LEFT_BRACKET:
+			depth++
+		case token.RIGHT_PAREN, token.RIGHT_BRACE, token.RIGHT_BRACKET:
+			depth--
+		}
+	}
+	if depth > 0 {
+		return true
+	}
+
+	var errs []error
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = multi.Unwrap()
+	}
+	for _, e := range errs {
+		l, ok := e.(located)
+		if !ok {
+			continue
+		}
+		pos := l.Position()
+		atEOF := pos.Start == len(source)
+		unterminated := pos.Start < len(source) && source[pos.Start] == '"' && pos.End == len(source)
+		if atEOF || unterminated {
+			return true
+		}
+	}
+	return false
+}
+
+// countLines returns the number of lines in source,
+// counting a last line that has no line terminator.
+func countLines(source string) int {
+	n := strings.Count(source, "\n")
+	if source != "" && !strings.HasSuffix(source, "\n") {
+		n++
+	}
+	return n
+}
+
+// RunFile reads the file at path and runs its content.
+func (e *Engine) RunFile(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return e.RunScript(path, string(bytes))
+}
+
+// Call invokes the global function, class or registered Go function called name.
+// Like a run, each call gets the full budget set by [Engine.SetLimits],
+// unless it comes from a registered function while a program runs, in
+// which case it is charged to that program.
+func (e *Engine) Call(name string, args ...Value) (Value, error) {
+	return e.CallContext(context.Background(), name, args...)
+}
+
+// CallContext calls name like [Engine.Call], stopping the call if ctx is
+// done before it returns.
+func (e *Engine) CallContext(ctx context.Context, name string, args ...Value) (Value, error) {
+	value, ok := e.backend.Global(name)
+	if !ok {
+		return nil, fmt.Errorf("lox: undefined variable '%s'", name)
+	}
+
+	arity, ok := e.backend.Callable(value)
+	if !ok {
+		return nil, fmt.Errorf("lox: '%s' is not callable", name)
+	}
+	if !arity.Accepts(len(args)) {
+		return nil, fmt.Errorf("lox: '%s' expects %s arguments but got %d", name, arity, len(args))
+	}
+
+	objects := make([]interpreter.Object, len(args))
+	for i, arg := range args {
+		objects[i] = arg
+	}
+
+	result, err := e.backend.CallContext(ctx, value, objects)
+	if err != nil {
+		return nil, e.newError(RuntimeStage, err)
+	}
+	return result, nil
+}
+
+// native adapts a [Func] to [interpreter.NativeFn].
+func native(fn Func) interpreter.NativeFn {
+	return func(_ interpreter.Host, args []interpreter.Object) (interpreter.Object, error) {
+		values := make([]Value, len(args))
+		for i, arg := range args {
+			values[i] = arg
+		}
+		return fn(values)
+	}
+}
@@ -0,0 +1,601 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineRunWritesToStdout(t *testing.T) {
+	r := require.New(t)
+
+	var stdout bytes.Buffer
+	engine := NewEngine()
+	engine.SetStdout(&stdout)
+
+	err := engine.Run(`
+var greeting = "hello";
+print greeting + " world";
+print 1 + 2;
+`)
+
+	r.NoError(err)
+	r.Equal("hello world\n3\n", stdout.String())
+}
+
+func TestEngineKeepsGlobalsAcrossRuns(t *testing.T) {
+	r := require.New(t)
+
+	engine := NewEngine()
+	engine.Define("base", float64(40))
+
+	r.NoError(engine.Run(`var total = base + 1;`))
+	r.NoError(engine.Run(`total = total + 1;`))
+
+	total, ok := engine.Get("total")
+	r.True(ok)
+	r.Equal(float64(42), total)
+
+	_, ok = engine.Get("missing")
+	r.False(ok)
+}
+
+func TestEngineRegisteredFunctions(t *testing.T) {
+	r := require.New(t)
+
+	var stdout bytes.Buffer
+	engine := NewEngine()
+	engine.SetStdout(&stdout)
+	engine.Register("twice", 1, func(args []Value) (Value, error) {
+		return args[0].(float64) * 2, nil
+	})
+
+	r.NoError(engine.Run(`print twice(21); print twice;`))
+	r.Equal("42\n<native fn twice>\n", stdout.String())
+
+	err := engine.Run(`twice(1, 2);`)
+	r.Error(err)
+	r.Equal("Expected 1 arguments but got 2.\n[line 1]", err.Error())
+}
+
+func TestEngineCallLoxFunction(t *testing.T) {
+	r := require.New(t)
+
+	engine := NewEngine()
+	r.NoError(engine.Run(`
+fun greet(name) {
+  return "hi " + name;
+}
+var notCallable = 1;
+`))
+
+	got, err := engine.Call("greet", "lox")
+	r.NoError(err)
+	r.Equal("hi lox", got)
+
+	_, err = engine.Call("greet")
+	r.EqualError(err, "lox: 'greet' expects 1 arguments but got 0")
+
+	_, err = engine.Call("notCallable")
+	r.EqualError(err, "lox: 'notCallable' is not callable")
+
+	_, err = engine.Call("nowhere")
+	r.EqualError(err, "lox: undefined variable 'nowhere'")
+}
+
+func TestEngineErrors(t *testing.T) {
+	tests := []struct {
+		name            string
+		source          string
+		wantStage       Stage
+		wantDiagnostics []Diagnostic
+	}{
+		{
+			name:      "scan errors are collected",
+			source:    "var a = 1;\n@ $",
+			wantStage: ScanStage,
+			wantDiagnostics: []Diagnostic{
+				{"", 2, 1, "Unexpected character: @"},
+				{"", 2, 3, "Unexpected character: $"},
+			},
+		},
+		{
+			name:      "parse errors are collected",
+			source:    "print 1\nprint 2;\nvar = 3;",
+			wantStage: ParseStage,
+			wantDiagnostics: []Diagnostic{
+				{"", 2, 1, "Expect ';' after value."},
+				{"", 3, 5, "Expect variable name."},
+			},
+		},
+		{
+			name:            "resolve error",
+			source:          "return 1;",
+			wantStage:       ResolveStage,
+			wantDiagnostics: []Diagnostic{{"", 1, 1, "Can't return from top-level code."}},
+		},
+		{
+			name:            "runtime error",
+			source:          "var a = 1;\nprint -\"a\";",
+			wantStage:       RuntimeStage,
+			wantDiagnostics: []Diagnostic{{"", 2, 7, "Operand must be a number."}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			err := NewEngine().Run(tt.source)
+
+			var loxErr *Error
+			r.True(errors.As(err, &loxErr))
+			r.Equal(tt.wantStage, loxErr.Stage)
+			r.Equal(tt.wantDiagnostics, loxErr.Diagnostics)
+		})
+	}
+}
+
+func TestEngineErrorRender(t *testing.T) {
+	r := require.New(t)
+
+	engine := NewEngine()
+	err := engine.RunScript("main.lox", "var a = 1;\nprint a +\tmissing;")
+
+	var loxErr *Error
+	r.ErrorAs(err, &loxErr)
+	r.Equal([]Diagnostic{{"main.lox", 2, 11, "Undefined variable 'missing'."}}, loxErr.Diagnostics)
+	r.Equal(`Undefined variable 'missing'.
+[line 2]
+ --> main.lox:2:11
+  |
+2 | print a +	missing;
+  |          	^~~~~~~`, loxErr.Render())
+}
+
+func TestEngineRegisteredVariadicFunctions(t *testing.T) {
+	r := require.New(t)
+
+	var stdout bytes.Buffer
+	engine := NewEngine()
+	engine.SetStdout(&stdout)
+	engine.RegisterVariadic("count", 1, func(args []Value) (Value, error) {
+		return float64(len(args)), nil
+	})
+	engine.Register("fail", 0, func(args []Value) (Value, error) {
+		return nil, errors.New("Host failure.")
+	})
+
+	r.NoError(engine.Run(`print count(1); print count(1, 2, 3);`))
+	r.Equal("1\n3\n", stdout.String())
+
+	err := engine.Run(`count();`)
+	r.EqualError(err, "Expected at least 1 arguments but got 0.\n[line 1]")
+
+	err = engine.Run("\nfail();")
+	r.EqualError(err, "Host failure.\n[line 2]")
+}
+
+func TestEngineRuntimeErrorTrace(t *testing.T) {
+	r := require.New(t)
+
+	err := NewEngine().RunScript("main.lox", `class Shape {
+  area() {
+    return this.width * 2;
+  }
+}
+fun describe(shape) {
+  return shape.area();
+}
+describe(Shape());`)
+
+	var loxErr *Error
+	r.ErrorAs(err, &loxErr)
+	r.Equal(RuntimeStage, loxErr.Stage)
+	r.Equal([]Frame{
+		{"describe", "", "main.lox", 9, 17},
+		{"area", "Shape", "main.lox", 7, 21},
+	}, loxErr.Trace)
+	r.Equal(`Undefined property 'width'.
+[line 3]
+Traceback (most recent call last):
+  [line 9] in script
+  [line 7] in describe()
+  [line 3] in Shape.area()`, loxErr.Error())
+
+	err = NewEngine().Run(`print -"a";`)
+	r.ErrorAs(err, &loxErr)
+	r.Empty(loxErr.Trace)
+}
+
+func TestEngineRunInteractive(t *testing.T) {
+	r := require.New(t)
+
+	var stdout bytes.Buffer
+	engine := NewEngine()
+	engine.SetStdout(&stdout)
+
+	r.NoError(engine.RunInteractive("<stdin>", "var a = 40;\n"))
+	r.NoError(engine.RunInteractive("<stdin>", "a + 2\n"))
+	r.Equal("42\n", stdout.String())
+
+	for _, source := range []string{
+		"fun f() {\n",
+		"print (1 +\n",
+		"var s = \"open\n",
+		"print 1\n",
+	} {
+		err := engine.RunInteractive("<stdin>", source)
+		var loxErr *Error
+		r.ErrorAs(err, &loxErr, source)
+		r.True(loxErr.Incomplete(), source)
+	}
+
+	err := engine.RunInteractive("<stdin>", "print a b;\n")
+	var loxErr *Error
+	r.ErrorAs(err, &loxErr)
+	r.False(loxErr.Incomplete())
+	r.Equal([]Diagnostic{{"<stdin>", 3, 9, "Expect ';' after value."}}, loxErr.Diagnostics)
+}
+
+func TestEngineImportsFromSearchPath(t *testing.T) {
+	r := require.New(t)
+
+	lib := t.TempDir()
+	r.NoError(os.WriteFile(filepath.Join(lib, "greet.lox"), []byte(`fun hello(name) { return "hello, " + name; }`), 0o644))
+
+	var stdout bytes.Buffer
+	engine := NewEngine()
+	engine.SetStdout(&stdout)
+
+	err := engine.Run(`import "greet";`)
+	var loxErr *Error
+	r.ErrorAs(err, &loxErr)
+	r.Equal(ResolveStage, loxErr.Stage)
+	r.Equal([]Diagnostic{{"", 1, 8, `Can't find module "greet".`}}, loxErr.Diagnostics)
+
+	engine.SetSearchPath(lib)
+	r.NoError(engine.Run(`import "greet"; print greet.hello("lox");`))
+	r.Equal("hello, lox\n", stdout.String())
+}
+
+func TestEngineVMBackend(t *testing.T) {
+	r := require.New(t)
+
+	var stdout bytes.Buffer
+	engine := NewEngineWithBackend(VM)
+	engine.SetStdout(&stdout)
+	engine.Define("base", float64(40))
+	engine.Register("twice", 1, func(args []Value) (Value, error) {
+		return args[0].(float64) * 2, nil
+	})
+
+	r.NoError(engine.Run(`
+fun add(n) { return base + n; }
+print twice(add(1));
+`))
+	r.Equal("82\n", stdout.String())
+
+	got, err := engine.Call("add", float64(2))
+	r.NoError(err)
+	r.Equal(float64(42), got)
+
+	err = engine.RunScript("main.lox", "fun f() {\n  return nil.x;\n}\nf();")
+	var loxErr *Error
+	r.ErrorAs(err, &loxErr)
+	r.Equal(RuntimeStage, loxErr.Stage)
+	r.Equal([]Diagnostic{{"main.lox", 2, 14, "Only instances have properties."}}, loxErr.Diagnostics)
+	r.Equal([]Frame{{"f", "", "main.lox", 4, 3}}, loxErr.Trace)
+}
+
+func TestEngineLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   Limits
+		source   string
+		wantDiag Diagnostic
+	}{
+		{
+			name:     "infinite loop runs out of steps",
+			limits:   Limits{Steps: 1000},
+			source:   "var n = 0;\nwhile (true) {\n  n = n + 1;\n}",
+			wantDiag: Diagnostic{"", 2, 1, "Step limit exceeded."},
+		},
+		{
+			name:     "calls count as steps",
+			limits:   Limits{Steps: 1000},
+			source:   "fun f() {}\nfor (;;) f();",
+			wantDiag: Diagnostic{"", 2, 12, "Step limit exceeded."},
+		},
+		{
+			name:     "deep recursion overflows the stack",
+			limits:   Limits{MaxDepth: 50},
+			source:   "fun f(n) {\n  return f(n + 1);\n}\nf(0);",
+			wantDiag: Diagnostic{"", 2, 17, "Stack overflow."},
+		},
+		{
+			name:     "recursion overflows the default depth",
+			source:   "fun f(n) {\n  return f(n + 1);\n}\nf(0);",
+			wantDiag: Diagnostic{"", 2, 17, "Stack overflow."},
+		},
+	}
+
+	for _, b := range []Backend{TreeWalker, VM} {
+		for _, tt := range tests {
+			t.Run(b.String()+"/"+tt.name, func(t *testing.T) {
+				r := require.New(t)
+				engine := NewEngineWithBackend(b)
+				engine.SetLimits(tt.limits)
+
+				err := engine.Run(tt.source)
+
+				var loxErr *Error
+				r.ErrorAs(err, &loxErr)
+				r.Equal(RuntimeStage, loxErr.Stage)
+				r.Equal([]Diagnostic{tt.wantDiag}, loxErr.Diagnostics)
+			})
+		}
+	}
+}
+
+func TestEngineLimitsResetBetweenRuns(t *testing.T) {
+	r := require.New(t)
+
+	engine := NewEngine()
+	engine.SetLimits(Limits{Steps: 100})
+	source := "for (var i = 0; i < 30; i = i + 1) {}"
+
+	// each run gets the whole budget
+	r.NoError(engine.Run(source))
+	r.NoError(engine.Run(source))
+}
+
+func TestEngineRunContext(t *testing.T) {
+	for _, b := range []Backend{TreeWalker, VM} {
+		t.Run(b.String(), func(t *testing.T) {
+			r := require.New(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			err := NewEngineWithBackend(b).RunContext(ctx, "while (true) {}")
+
+			var loxErr *Error
+			r.ErrorAs(err, &loxErr)
+			r.Equal(RuntimeStage, loxErr.Stage)
+			r.Equal([]Diagnostic{{"", 1, 1, "Interrupted: context deadline exceeded."}}, loxErr.Diagnostics)
+			r.ErrorIs(err, context.DeadlineExceeded)
+		})
+	}
+}
+
+func TestEngineMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		wantLine int
+	}{
+		{
+			name:     "string concatenation",
+			source:   "var s = \"x\";\nwhile (true) s = s + s;",
+			wantLine: 2,
+		},
+		{
+			name:     "instances",
+			source:   "class A {}\nvar all = [];\nwhile (true) all.push(A());",
+			wantLine: 3,
+		},
+		{
+			name:     "map entries",
+			source:   "var m = {};\nvar i = 0;\nwhile (true) {\n  m[i] = i;\n  i = i + 1;\n}",
+			wantLine: 4,
+		},
+	}
+
+	for _, b := range []Backend{TreeWalker, VM} {
+		for _, tt := range tests {
+			t.Run(b.String()+"/"+tt.name, func(t *testing.T) {
+				r := require.New(t)
+				engine := NewEngineWithBackend(b)
+				engine.SetLimits(Limits{Memory: 64 << 10})
+
+				err := engine.Run(tt.source)
+
+				// the backends account for allocations differently, so they
+				// may not stop at the same point of the line
+				var loxErr *Error
+				r.ErrorAs(err, &loxErr)
+				r.Len(loxErr.Diagnostics, 1)
+				r.Equal(tt.wantLine, loxErr.Diagnostics[0].Line)
+				r.Equal("Memory limit exceeded.", loxErr.Diagnostics[0].Message)
+				r.Greater(engine.Usage().Peak, 64<<10)
+			})
+		}
+	}
+}
+
+func TestEngineUsage(t *testing.T) {
+	for _, b := range []Backend{TreeWalker, VM} {
+		t.Run(b.String(), func(t *testing.T) {
+			r := require.New(t)
+			engine := NewEngineWithBackend(b)
+
+			// the environment of every call is charged
+			r.NoError(engine.Run(`
+fun f(n) {
+  var twice = n * 2;
+  return twice;
+}
+for (var i = 0; i < 1000; i = i + 1) f(i);
+`))
+			usage := engine.Usage()
+			r.Greater(usage.Steps, 1000)
+			r.Greater(usage.Allocated, 1000*48)
+
+			// every string built counts, even once it is replaced
+			r.NoError(engine.Run(`var s = ""; for (var i = 0; i < 100; i = i + 1) s = s + "ab";`))
+			r.Greater(engine.Usage().Allocated, 100*100)
+
+			// values left by a run are held by the next ones until released
+			engine.SetLimits(Limits{Memory: 64 << 10})
+			r.NoError(engine.Run(`var t = repeat("ab", 20000);`))
+			r.Error(engine.Run(`var u = repeat("ab", 20000);`))
+			r.NoError(engine.Run(`t = nil; var u = repeat("ab", 20000);`))
+		})
+	}
+}
+
+func TestEngineMemoryLimitCountsReachableValues(t *testing.T) {
+	for _, b := range []Backend{TreeWalker, VM} {
+		t.Run(b.String(), func(t *testing.T) {
+			r := require.New(t)
+			engine := NewEngineWithBackend(b)
+			engine.SetLimits(Limits{Memory: 1 << 20})
+
+			// values are given back once the program can't reach them
+			r.NoError(engine.Run(`
+fun f(i) { var s = "abc" + str(i); }
+for (var i = 0; i < 20000; i = i + 1) f(i);
+`))
+			usage := engine.Usage()
+			r.Greater(usage.Allocated, 1<<20)
+			r.Less(usage.Peak, 1<<20)
+
+			// values only held by the expressions being evaluated count
+			err := engine.Run(`
+fun nest(n) {
+  if (n == 0) return nil;
+  return [repeat("x", 10000), nest(n - 1)];
+}
+nest(200);
+`)
+			var loxErr *Error
+			r.ErrorAs(err, &loxErr)
+			r.Equal("Memory limit exceeded.", loxErr.Diagnostics[0].Message)
+		})
+	}
+}
+
+func TestEngineFileAccess(t *testing.T) {
+	dir := t.TempDir()
+	r := require.New(t)
+	r.NoError(os.WriteFile(filepath.Join(dir, "in.txt"), []byte("42"), 0o644))
+
+	for _, b := range []Backend{TreeWalker, VM} {
+		t.Run(b.String(), func(t *testing.T) {
+			r := require.New(t)
+			engine := NewEngineWithBackend(b)
+
+			err := engine.Run(`readFile("in.txt");`)
+			var loxErr *Error
+			r.ErrorAs(err, &loxErr)
+			r.Equal(RuntimeStage, loxErr.Stage)
+			r.Equal([]Diagnostic{{"", 1, 18, "Not allowed to read files."}}, loxErr.Diagnostics)
+
+			r.NoError(engine.AllowRead(dir))
+			r.NoError(engine.AllowWrite(dir))
+			in, out := filepath.Join(dir, "in.txt"), filepath.Join(dir, b.String()+".txt")
+			r.NoError(engine.Run(`writeFile("` + out + `", str(num(readFile("` + in + `")) + 1));`))
+
+			got, err := os.ReadFile(out)
+			r.NoError(err)
+			r.Equal("43", string(got))
+		})
+	}
+}
+
+func TestEngineCallLimits(t *testing.T) {
+	for _, b := range []Backend{TreeWalker, VM} {
+		t.Run(b.String(), func(t *testing.T) {
+			r := require.New(t)
+			engine := NewEngineWithBackend(b)
+			engine.SetLimits(Limits{Steps: 50})
+
+			r.NoError(engine.Run(`
+fun f() { return 1; }
+fun spin() { while (true) {} }
+`))
+			// a run using up its steps doesn't leave the next call without any
+			r.Error(engine.Run(`while (true) {}`))
+			result, err := engine.Call("f")
+			r.NoError(err)
+			r.Equal(float64(1), result)
+
+			// calls are stopped where the callee loops
+			_, err = engine.Call("spin")
+			var loxErr *Error
+			r.ErrorAs(err, &loxErr)
+			r.Equal([]Diagnostic{{"", 3, 14, "Step limit exceeded."}}, loxErr.Diagnostics)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			engine.SetLimits(Limits{})
+			_, err = engine.CallContext(ctx, "spin")
+			r.ErrorIs(err, context.Canceled)
+			r.ErrorAs(err, &loxErr)
+			r.Equal(3, loxErr.Diagnostics[0].Line)
+
+			// calls made back from a registered function count against
+			// the program, which can't reset its budget through them
+			engine.Register("callback", 0, func(args []Value) (Value, error) {
+				return engine.Call("f")
+			})
+			engine.SetLimits(Limits{Steps: 1000})
+			err = engine.Run(`
+var i = 0;
+while (i < 100000) { i = i + 1; callback(); }
+`)
+			r.ErrorAs(err, &loxErr)
+			r.Equal("Step limit exceeded.", loxErr.Diagnostics[0].Message)
+			r.Greater(engine.Usage().Steps, 1000)
+			i, _ := engine.Get("i")
+			r.Less(i, float64(1000))
+
+			engine.SetLimits(Limits{Memory: 64 << 10})
+			engine.Register("callback", 0, func(args []Value) (Value, error) {
+				return engine.Call("grow")
+			})
+			err = engine.Run(`
+var s = "";
+fun grow() { s = s + "abcdefgh"; }
+while (true) callback();
+`)
+			r.ErrorAs(err, &loxErr)
+			// reported by the call from the callback, wrapped by the one to it
+			r.Contains(loxErr.Diagnostics[0].Message, "Memory limit exceeded.")
+		})
+	}
+}
+
+func TestEngineMemoryLimitAfterConstructorHasNoTrace(t *testing.T) {
+	for _, b := range []Backend{TreeWalker, VM} {
+		t.Run(b.String(), func(t *testing.T) {
+			r := require.New(t)
+			engine := NewEngineWithBackend(b)
+			engine.SetLimits(Limits{Memory: 64 << 10})
+
+			// the instance is charged once the call returns, so the call
+			// doesn't appear in the trace
+			err := engine.Run(`
+class A {}
+fun make() { return A(); }
+var all = [];
+for (var i = 0; i < 1000; i = i + 1) all.push(nil);
+for (var i = 0; i < 1000; i = i + 1) all[i] = make();
+`)
+
+			var loxErr *Error
+			r.ErrorAs(err, &loxErr)
+			r.Equal("Memory limit exceeded.", loxErr.Diagnostics[0].Message)
+			for _, f := range loxErr.Trace {
+				r.NotEqual("init", f.Function)
+				r.NotEqual("A", f.Function)
+			}
+		})
+	}
+}
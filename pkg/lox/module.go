@@ -0,0 +1,141 @@
+package lox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	internalErrors "github.com/nt54hamnghi/golox/internal/errors"
+	"github.com/nt54hamnghi/golox/internal/parser"
+	"github.com/nt54hamnghi/golox/internal/scanner"
+)
+
+// SetSearchPath sets the directories searched, in order, for imported
+// modules that are not found relative to the importing file.
+func (e *Engine) SetSearchPath(dirs ...string) {
+	e.searchPath = dirs
+}
+
+// link loads the modules imported by prog, which was read from the file
+// called name, and binds its import statements to them.
+func (e *Engine) link(name string, prog []parser.Stmt) error {
+	var chain []string
+	if name != "" {
+		if abs, err := filepath.Abs(name); err == nil {
+			chain = append(chain, abs)
+		}
+	}
+	return e.linkImports(name, prog, chain)
+}
+
+// linkImports links the top-level imports of prog, read from the file
+// called importer. chain holds the absolute paths of the files being
+// imported, outermost first, to detect circular imports.
+func (e *Engine) linkImports(importer string, prog []parser.Stmt, chain []string) error {
+	for _, stmt := range prog {
+		imp, ok := stmt.(parser.Import)
+		if !ok {
+			continue
+		}
+		module, err := e.loadModule(importer, imp, chain)
+		if err != nil {
+			return err
+		}
+		e.backend.Link(imp, module)
+	}
+	return nil
+}
+
+// loadModule scans, parses and resolves the module imported by stmt,
+// unless it was loaded before.
+func (e *Engine) loadModule(importer string, stmt parser.Import, chain []string) (module, error) {
+	name, abs, ok := e.findModule(importer, stmt.Path.Literal.(string))
+	if !ok {
+		return nil, e.newError(ResolveStage, internalErrors.StaticErrorAtToken(
+			stmt.Path,
+			fmt.Sprintf("Can't find module %s.", stmt.Path.Lexeme),
+		))
+	}
+	if slices.Contains(chain, abs) {
+		return nil, e.newError(ResolveStage, internalErrors.StaticErrorAtToken(
+			stmt.Path,
+			fmt.Sprintf("Circular import of %s.", stmt.Path.Lexeme),
+		))
+	}
+	if module, ok := e.modules[abs]; ok {
+		return module, nil
+	}
+
+	bytes, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, e.newError(ResolveStage, internalErrors.StaticErrorAtToken(
+			stmt.Path,
+			fmt.Sprintf("Can't read module %s.", stmt.Path.Lexeme),
+		))
+	}
+	source := string(bytes)
+	e.sources[name] = source
+
+	sc := scanner.NewFileScanner(name, source)
+	tokens, err := sc.ScanTokens()
+	if err != nil {
+		return nil, e.newError(ScanStage, err)
+	}
+
+	pa := parser.NewParser(tokens)
+	prog, err := pa.Parse()
+	if err != nil {
+		return nil, e.newError(ParseStage, err)
+	}
+	e.parsed(name, source, &pa)
+
+	if err := e.linkImports(name, prog, append(slices.Clip(chain), abs)); err != nil {
+		return nil, err
+	}
+
+	if _, err := e.resolver.Resolve(prog); err != nil {
+		return nil, e.newError(ResolveStage, err)
+	}
+
+	module, err := e.backend.NewModule(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)), prog)
+	if err != nil {
+		return nil, e.newError(ResolveStage, err)
+	}
+	e.modules[abs] = module
+	return module, nil
+}
+
+// findModule locates the file of the module imported as path from the
+// file called importer. It returns the name to report the module under,
+// which is relative when importer is, along with its absolute path.
+// A path without an extension also matches a file with the .lox extension.
+func (e *Engine) findModule(importer string, path string) (string, string, bool) {
+	dirs := []string{""}
+	if !filepath.IsAbs(path) {
+		dirs = append([]string{filepath.Dir(importer)}, e.searchPath...)
+	}
+
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		candidates := []string{candidate}
+		if filepath.Ext(candidate) == "" {
+			candidates = append(candidates, candidate+".lox")
+		}
+
+		for _, c := range candidates {
+			info, err := os.Stat(c)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			abs, err := filepath.Abs(c)
+			if err != nil {
+				continue
+			}
+			return c, abs, true
+		}
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,58 @@
+package lox
+
+import (
+	"github.com/nt54hamnghi/golox/internal/scanner"
+	"github.com/nt54hamnghi/golox/internal/scanner/token"
+)
+
+// Token is a lexical token of a Lox program.
+type Token struct {
+	// Name of the token type, such as IDENTIFIER or LEFT_PAREN.
+	Type string `json:"type"`
+	// Source text of the token, empty for EOF.
+	Lexeme string `json:"lexeme"`
+	// Value of a NUMBER (float64) or STRING (string) token, nil otherwise.
+	Literal any `json:"literal"`
+	Line    int `json:"line"`
+	// 1-based column of the first character, counted in runes.
+	Column int `json:"column"`
+	// Byte offsets of the first byte of the token and just past its last.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	tok token.Token
+}
+
+// String returns the type, lexeme and literal of the token,
+// with "null" standing for a missing literal.
+func (t Token) String() string {
+	return t.tok.String()
+}
+
+// Tokenize scans source, read from the file called name, into the tokens
+// the parser would see, ending with EOF. Comments are skipped.
+// The scanner recovers from lexical errors, so the tokens around them are
+// returned along with an [*Error] reporting every one of them.
+func Tokenize(name string, source string) ([]Token, error) {
+	sc := scanner.NewFileScanner(name, source)
+	tokens, err := sc.ScanTokens()
+
+	result := make([]Token, len(tokens))
+	for n, tok := range tokens {
+		result[n] = Token{
+			Type:    tok.Type.String(),
+			Lexeme:  tok.Lexeme,
+			Literal: tok.Literal,
+			Line:    tok.Line,
+			Column:  tok.Column,
+			Start:   tok.Start,
+			End:     tok.End,
+			tok:     tok,
+		}
+	}
+
+	if err != nil {
+		return result, newError(ScanStage, err, map[string]string{name: source})
+	}
+	return result, nil
+}
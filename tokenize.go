@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nt54hamnghi/golox/pkg/lox"
+)
+
+const tokenizeUsage = "Usage: glox tokenize [--json] [file]"
+
+// runTokenize prints the tokens of a Lox file, or of stdin when no file is
+// given, one per line as `line:column TYPE lexeme literal`. With --json,
+// it prints them as an array of objects instead. Tokens are printed even
+// when scanning fails, in which case the errors go to stderr and the
+// exit code is 65.
+func runTokenize(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("golox tokenize", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the tokens as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, tokenizeUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 64
+	}
+
+	args = flags.Args()
+	if len(args) > 1 {
+		fmt.Fprintln(stdout, tokenizeUsage)
+		return 64
+	}
+
+	name, source, code := readSource(args, stdin, stderr)
+	if code != 0 {
+		return code
+	}
+
+	tokens, scanErr := lox.Tokenize(name, source)
+	if *asJSON {
+		bytes, err := json.MarshalIndent(tokens, "", "  ")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 70
+		}
+		fmt.Fprintln(stdout, string(bytes))
+	} else {
+		for _, tok := range tokens {
+			fmt.Fprintf(stdout, "%d:%d %s\n", tok.Line, tok.Column, tok)
+		}
+	}
+
+	if scanErr != nil {
+		fmt.Fprintln(stderr, render(scanErr))
+		return 65
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLITokenize(t *testing.T) {
+	r := require.New(t)
+	paths := writeFiles(t, "var s = \"hi\"; // comment\nprint s + 1.5;\n")
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"tokenize", paths[0]}, strings.NewReader(""), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.Equal(`1:1 VAR var null
+1:5 IDENTIFIER s null
+1:7 EQUAL = null
+1:9 STRING "hi" hi
+1:13 SEMICOLON ; null
+2:1 PRINT print null
+2:7 IDENTIFIER s null
+2:9 PLUS + null
+2:11 NUMBER 1.5 1.5
+2:14 SEMICOLON ; null
+3:1 EOF  null
+`, stdout.String())
+	r.Empty(stderr.String())
+}
+
+func TestCLITokenizeJSON(t *testing.T) {
+	r := require.New(t)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run([]string{"tokenize", "--json"}, strings.NewReader("f(\"é\", 2)"), &stdout, &stderr)
+
+	r.Equal(0, exitCode)
+	r.JSONEq(`[
+		{"type": "IDENTIFIER", "lexeme": "f", "literal": null, "line": 1, "column": 1, "start": 0, "end": 1},
+		{"type": "LEFT_PAREN", "lexeme": "(", "literal": null, "line": 1, "column": 2, "start": 1, "end": 2},
+		{"type": "STRING", "lexeme": "\"é\"", "literal": "é", "line": 1, "column": 3, "start": 2, "end": 6},
+		{"type": "COMMA", "lexeme": ",", "literal": null, "line": 1, "column": 6, "start": 6, "end": 7},
+		{"type": "NUMBER", "lexeme": "2", "literal": 2, "line": 1, "column": 8, "start": 8, "end": 9},
+		{"type": "RIGHT_PAREN", "lexeme": ")", "literal": null, "line": 1, "column": 9, "start": 9, "end": 10},
+		{"type": "EOF", "lexeme": "", "literal": null, "line": 1, "column": 10, "start": 10, "end": 10}
+	]`, stdout.String())
+	r.Empty(stderr.String())
+}
+
+func TestCLITokenizeErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{
+			name:         "lexical errors are reported after the tokens around them",
+			args:         []string{"tokenize"},
+			stdin:        "a @ b",
+			wantExitCode: 65,
+			wantStdout:   "1:1 IDENTIFIER a null\n1:5 IDENTIFIER b null\n1:6 EOF  null\n",
+			wantStderr: "[line 1] Error: Unexpected character: @\n" +
+				" --> <stdin>:1:3\n" +
+				"  |\n" +
+				"1 | a @ b\n" +
+				"  |   ^\n",
+		},
+		{
+			name:         "missing files are reported",
+			args:         []string{"tokenize", "missing.lox"},
+			wantExitCode: 66,
+			wantStderr:   "open missing.lox: no such file or directory\n",
+		},
+		{
+			name:         "only one file is accepted",
+			args:         []string{"tokenize", "a.lox", "b.lox"},
+			wantExitCode: 64,
+			wantStdout:   tokenizeUsage + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			t.Chdir(t.TempDir())
+
+			var stdout, stderr bytes.Buffer
+			exitCode := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			r.Equal(tt.wantExitCode, exitCode)
+			r.Equal(tt.wantStdout, stdout.String())
+			r.Equal(tt.wantStderr, stderr.String())
+		})
+	}
+}
@@ -21,6 +21,7 @@ func main() {
 	err := defineAst(outputDir, "Expr", []typeDesc{
 		{"Literal", []field{
 			{"Value", "any"},
+			{"Token", "token.Token"},
 		}},
 		{"Call", []field{
 			{"Callee", "Expr"},
@@ -67,6 +68,26 @@ func main() {
 			{"Operator", "token.Token"},
 			{"Right", "Expr"},
 		}},
+		{"List", []field{
+			{"Elements", "[]Expr"},
+			{"Bracket", "token.Token"},
+		}},
+		{"Map", []field{
+			{"Keys", "[]Expr"},
+			{"Values", "[]Expr"},
+			{"Brace", "token.Token"},
+		}},
+		{"Index", []field{
+			{"Object", "Expr"},
+			{"Bracket", "token.Token"},
+			{"Index", "Expr"},
+		}},
+		{"IndexSet", []field{
+			{"Object", "Expr"},
+			{"Bracket", "token.Token"},
+			{"Index", "Expr"},
+			{"Value", "Expr"},
+		}},
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -99,9 +120,31 @@ func main() {
 			{"ElseBranch", "Stmt"},
 		}},
 		{"While", []field{
+			{"Keyword", "token.Token"},
+			{"Condition", "Expr"},
+			{"Body", "Stmt"},
+		}},
+		{"For", []field{
+			{"Keyword", "token.Token"},
+			{"Initializer", "Stmt"},
 			{"Condition", "Expr"},
+			{"Increment", "Expr"},
 			{"Body", "Stmt"},
 		}},
+		{"Break", []field{
+			{"Keyword", "token.Token"},
+		}},
+		{"Continue", []field{
+			{"Keyword", "token.Token"},
+		}},
+		{"Breakpoint", []field{
+			{"Keyword", "token.Token"},
+		}},
+		{"Import", []field{
+			{"Keyword", "token.Token"},
+			{"Path", "token.Token"},
+			{"Alias", "*token.Token"},
+		}},
 		{"Return", []field{
 			{"Keyword", "token.Token"},
 			{"Value", "Expr"},
@@ -137,6 +180,16 @@ type field struct {
 	typ  string
 }
 
+// param returns the name of the constructor parameter for the field,
+// which must not hide the token package.
+func (f field) param() string {
+	name := strings.ToLower(f.name)
+	if name == "token" {
+		return "tok"
+	}
+	return name
+}
+
 func defineNodeIdGo(outputDir string) error {
 	b := strings.Builder{}
 
@@ -275,14 +328,14 @@ func defineTypeConstructor(b *strings.Builder, t typeDesc) {
 		if i > 0 {
 			fmt.Fprint(b, ", ")
 		}
-		fmt.Fprintf(b, "%s %s", strings.ToLower(f.name), f.typ)
+		fmt.Fprintf(b, "%s %s", f.param(), f.typ)
 	}
 
 	fmt.Fprintf(b, ") %s {\n", t.name)
 	fmt.Fprintf(b, "\tnode := %s{\n", t.name)
 
 	for _, f := range t.fieldList {
-		fmt.Fprintf(b, "\t\t%s: %s,\n", f.name, strings.ToLower(f.name))
+		fmt.Fprintf(b, "\t\t%s: %s,\n", f.name, f.param())
 	}
 
 	fmt.Fprintln(b, "\t}")